@@ -1,34 +1,306 @@
 package app
 
 import (
-	"log"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/kcthack-auth/internal/config"
 	"github.com/kcthack-auth/internal/handler"
+	"github.com/kcthack-auth/internal/handler/rpc"
 	"github.com/kcthack-auth/internal/repository"
 	"github.com/kcthack-auth/internal/serser"
 	"github.com/kcthack-auth/internal/service"
 	"github.com/kcthack-auth/pkg/auth"
 	"github.com/kcthack-auth/pkg/database"
+	"github.com/kcthack-auth/pkg/hasher"
+	"github.com/kcthack-auth/pkg/logger"
+	"github.com/kcthack-auth/pkg/mail"
+	"github.com/kcthack-auth/pkg/middleware"
+	"github.com/kcthack-auth/pkg/oauth"
+	"github.com/kcthack-auth/pkg/passpolicy"
+	"github.com/kcthack-auth/pkg/ratelimit"
+	"github.com/kcthack-auth/pkg/rbac"
 )
 
+// shutdownTimeout is how long running requests get to finish on shutdown.
+const shutdownTimeout = 10 * time.Second
+
 func Run() {
 	cfg, err := config.Init()
 	if err != nil {
-		log.Fatalf("failed to init config: %e", err)
+		fatal("failed to init config", err)
+	}
+
+	defaultLogger, err := logger.New(logger.Config{Level: cfg.Log.Level, Format: cfg.Log.Format}, os.Stdout)
+	if err != nil {
+		fatal("failed to init logger", err)
+	}
+	slog.SetDefault(defaultLogger)
+
+	sender, err := newMailSender(cfg)
+	if err != nil {
+		fatal("failed to init mail sender", err)
 	}
 
 	db := database.ConnDB(cfg)
 	authRepo := repository.NewAuthRepo(db)
 	sessRepo := repository.NewSessionRepo(db)
-	tm := auth.NewManager(cfg.JWT.JWTSecret)
-	authService := service.NewAuthService(authRepo, sessRepo, tm, cfg.Auth.AccessTTL, cfg.Auth.RefreshTTL)
-	services := service.NewServices(*authService)
-	newHandler := handler.NewHandler(services)
-	r := newHandler.Init(cfg)
+	verificationRepo := repository.NewVerificationRepo(db)
+	passwordResetRepo := repository.NewPasswordResetRepo(db)
+	roleRepo := repository.NewRoleRepo(db)
+	oidcRepo := repository.NewOIDCRepo(db)
+	machineClientRepo := repository.NewMachineClientRepo(db)
+	signingKey, err := newSigningKey(cfg)
+	if err != nil {
+		fatal("failed to load jwt signing key", err)
+	}
+
+	keyCipher, err := newKeyCipher(cfg)
+	if err != nil {
+		fatal("failed to init signing key encryption", err)
+	}
+
+	// Background jobs run until shutdown.
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	keyRing := auth.NewKeyRing(signingKey)
+	keyService := service.NewKeyService(repository.NewKeyRepo(db, keyCipher), keyRing, cfg.JWT.Rotation.Algorithm, cfg.JWT.Rotation.PropagationDelay, cfg.Auth.AccessTTL)
+	if err := keyService.Init(ctx, signingKey); err != nil {
+		fatal("failed to init signing keys", err)
+	}
+	go keyService.RunReloader(ctx, cfg.JWT.Rotation.ReloadInterval)
+
+	tm := auth.NewManager(keyRing)
+	passwordHasher, err := hasher.New(hasher.Config{
+		Algorithm:  cfg.PasswordHashing.Algorithm,
+		BcryptCost: cfg.PasswordHashing.BcryptCost,
+		Argon2id: hasher.Argon2idParams{
+			Memory:      cfg.PasswordHashing.Argon2id.Memory,
+			Iterations:  cfg.PasswordHashing.Argon2id.Iterations,
+			Parallelism: cfg.PasswordHashing.Argon2id.Parallelism,
+			SaltLength:  cfg.PasswordHashing.Argon2id.SaltLength,
+			KeyLength:   cfg.PasswordHashing.Argon2id.KeyLength,
+		},
+	})
+	if err != nil {
+		fatal("failed to init password hasher", err)
+	}
+
+	policy := rbac.NewPolicy(cfg.RBAC.Roles)
+	roleService := service.NewRoleService(authRepo, roleRepo, policy)
+	verificationService := service.NewVerificationService(authRepo, verificationRepo, sender, cfg.Verification.TokenTTL, cfg.Verification.URL)
+	lockoutService := service.NewLockoutService(authRepo, newLoginAttemptRepo(cfg, db), service.NewMailLockoutNotifier(sender), service.LockoutConfig{
+		AccountThreshold: cfg.Lockout.AccountThreshold,
+		IPThreshold:      cfg.Lockout.IPThreshold,
+		Window:           cfg.Lockout.Window,
+		LockoutDuration:  cfg.Lockout.Duration,
+		BaseDelay:        cfg.Lockout.BaseDelay,
+		MaxDelay:         cfg.Lockout.MaxDelay,
+	})
+	go runCleanup(ctx, "login attempts", lockoutService.DeleteExpired)
+	mfaService := service.NewMFAService(authRepo, repository.NewMFARepo(db), roleService, lockoutService, passwordHasher, cfg.MFA.Issuer, cfg.MFA.ChallengeTTL, cfg.MFA.RequiredRoles)
+	breached, err := passpolicy.LoadBreachedList(cfg.PasswordPolicy.BreachedList, cfg.PasswordPolicy.BreachedFalsePositiveRate)
+	if err != nil {
+		fatal("failed to load breached password list", err)
+	}
+	passwords := passpolicy.New(passpolicy.Config{
+		MinLength:     cfg.PasswordPolicy.MinLength,
+		MaxLength:     cfg.PasswordPolicy.MaxLength,
+		RequireLower:  cfg.PasswordPolicy.RequireLower,
+		RequireUpper:  cfg.PasswordPolicy.RequireUpper,
+		RequireDigit:  cfg.PasswordPolicy.RequireDigit,
+		RequireSymbol: cfg.PasswordPolicy.RequireSymbol,
+		MinScore:      cfg.PasswordPolicy.MinScore,
+	}, breached)
+	authService := service.NewAuthService(authRepo, sessRepo, tm, roleService, mfaService, verificationService, lockoutService, passwords, passwordHasher, cfg.Auth.AccessTTL, cfg.Auth.RefreshTTL)
+	passwordService := service.NewPasswordService(authRepo, sessRepo, passwordResetRepo, sender, passwords, passwordHasher, cfg.PasswordReset.TokenTTL, cfg.PasswordReset.URL)
+	userService := service.NewUserService(authRepo)
+	adminService := service.NewAdminService(authRepo, sessRepo, roleService)
+	sessionService := service.NewSessionService(sessRepo)
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.WebAuthn.RPID,
+		RPDisplayName: cfg.WebAuthn.RPDisplayName,
+		RPOrigins:     cfg.WebAuthn.RPOrigins,
+	})
+	if err != nil {
+		fatal("failed to init webauthn", err)
+	}
+	passkeyService := service.NewPasskeyService(authRepo, repository.NewPasskeyRepo(db), authService, webAuthn, cfg.WebAuthn.CeremonyTTL)
+	telegramService := service.NewTelegramService(authRepo, repository.NewTelegramRepo(db), authService, cfg.Telegram.BotToken, cfg.Telegram.AuthMaxAge)
+	identityService := service.NewIdentityService(authRepo, repository.NewIdentityRepo(db), authService, newOAuthProviders(cfg), cfg.OAuth.StateTTL, cfg.OAuth.FrontendURL)
+	introspectionService := service.NewIntrospectionService(sessRepo, repository.NewDenylistRepo(db), oidcRepo, machineClientRepo, tm)
+	// Requests are authenticated with validator, which also rejects revoked and logged out tokens.
+	validator := auth.NewRevocationManager(tm, introspectionService)
+	oidcService := service.NewOIDCService(authRepo, oidcRepo, validator, service.OIDCConfig{
+		Issuer:     cfg.OIDC.Issuer,
+		LoginURL:   cfg.OIDC.LoginURL,
+		ConsentURL: cfg.OIDC.ConsentURL,
+		RequestTTL: cfg.OIDC.RequestTTL,
+		CodeTTL:    cfg.OIDC.CodeTTL,
+		AccessTTL:  cfg.Auth.AccessTTL,
+		IDTokenTTL: cfg.OIDC.IDTokenTTL,
+	})
+	machineClientService := service.NewMachineClientService(machineClientRepo, tm, cfg.MachineClients.TokenTTL, cfg.MachineClients.SecretGracePeriod)
+	services := service.NewServices(*authService, *verificationService, *passwordService, *roleService, *userService, *adminService, *sessionService, *keyService, *mfaService, *passkeyService, *telegramService, *identityService, *oidcService, *machineClientService, *introspectionService, *lockoutService)
+	limiter, err := newRateLimiter(ctx, cfg, db)
+	if err != nil {
+		fatal("failed to init rate limiter", err)
+	}
+	newHandler := handler.NewHandler(services, validator, policy, limiter)
+	r, err := newHandler.Init(cfg)
+	if err != nil {
+		fatal("failed to init http handler", err)
+	}
 
 	server := serser.NewServer(r, *cfg)
-	if err := server.Start(); err != nil {
-		log.Fatal("failed to start http server")
+	grpcServer := serser.NewGRPCServer(rpc.NewHandler(services, validator, limiter).Init(), *cfg)
+
+	go func() {
+		slog.Info("starting http server", slog.String("port", cfg.HTTP.Port))
+		if err := server.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("failed to start http server", err)
+		}
+	}()
+
+	go func() {
+		slog.Info("starting grpc server", slog.String("port", cfg.GRPC.Port))
+		if err := grpcServer.Start(); err != nil {
+			fatal("failed to start grpc server", err)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	slog.Info("shutting down")
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Stop(shutdownCtx); err != nil {
+		slog.Error("failed to stop http server", logger.Err(err))
+	}
+
+	if err := grpcServer.Stop(shutdownCtx); err != nil {
+		slog.Error("failed to stop grpc server", logger.Err(err))
+	}
+}
+
+// fatal logs the error and exits like log.Fatal.
+func fatal(msg string, err error) {
+	slog.Error(msg, logger.Err(err))
+	os.Exit(1)
+}
+
+// cleanupInterval is how often background jobs delete expired rows.
+const cleanupInterval = time.Hour
+
+// newRateLimiter returns nil if rate limiting is disabled, which limits nothing.
+// Expired rows of the postgres store are deleted until ctx is done.
+func newRateLimiter(ctx context.Context, cfg *config.Config, db *sql.DB) (*middleware.RateLimiter, error) {
+	if !cfg.RateLimit.Enabled {
+		return nil, nil
+	}
+
+	if cfg.RateLimit.Store != "postgres" {
+		return middleware.NewRateLimiter(ratelimit.NewMemoryStore(), cfg.RateLimit.Routes)
+	}
+
+	repo := repository.NewRateLimitRepo(db)
+	go runCleanup(ctx, "rate limits", repo.DeleteExpired)
+
+	return middleware.NewRateLimiter(repo, cfg.RateLimit.Routes)
+}
+
+// runCleanup calls deleteExpired every cleanupInterval until ctx is done.
+func runCleanup(ctx context.Context, what string, deleteExpired func(ctx context.Context) error) {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := deleteExpired(ctx); err != nil {
+				slog.ErrorContext(ctx, "failed to delete expired "+what, logger.Err(err))
+			}
+		}
+	}
+}
+
+func newLoginAttemptRepo(cfg *config.Config, db *sql.DB) repository.LoginAttemptRepository {
+	if cfg.Lockout.Store == "memory" {
+		return repository.NewMemoryLoginAttemptRepo()
+	}
+
+	return repository.NewLoginAttemptRepo(db)
+}
+
+func newMailSender(cfg *config.Config) (mail.Sender, error) {
+	switch cfg.Mail.Driver {
+	case "smtp":
+		return mail.NewSMTPSender(cfg.Mail.From, cfg.Mail.Host, cfg.Mail.Port, cfg.Mail.Username, cfg.Mail.Password), nil
+	case "file":
+		return mail.NewFileSender(cfg.Mail.From, cfg.Mail.Dir)
+	default:
+		return mail.NewLogSender(cfg.Mail.From), nil
+	}
+}
+
+// newOAuthProviders creates the enabled providers. A provider whose discovery fails is skipped,
+// so an outage of one provider does not prevent the service from starting.
+func newOAuthProviders(cfg *config.Config) map[string]*oauth.Provider {
+	providers := make(map[string]*oauth.Provider)
+	for name, providerCfg := range cfg.OAuth.Providers {
+		if providerCfg.ClientID == "" {
+			continue
+		}
+
+		provider, err := oauth.NewProvider(context.Background(), name, providerCfg, cfg.OAuth.CallbackURL+"/"+name+"/callback")
+		if err != nil {
+			slog.Error("failed to init oauth provider", slog.String("provider", name), logger.Err(err))
+			continue
+		}
+		providers[name] = provider
+	}
+
+	return providers
+}
+
+// newKeyCipher returns the AES-256-GCM cipher the stored signing keys are encrypted with.
+func newKeyCipher(cfg *config.Config) (cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(cfg.JWT.KeyEncryptionKey)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("JWT_KEY_ENCRYPTION_KEY must be base64 of 32 bytes")
 	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func newSigningKey(cfg *config.Config) (*auth.Key, error) {
+	if cfg.JWT.PrivateKeyPath == "" {
+		return auth.NewHMACKey(cfg.JWT.KeyID, cfg.JWT.JWTSecret), nil
+	}
+
+	return auth.LoadPrivateKeyFile(cfg.JWT.KeyID, cfg.JWT.PrivateKeyPath)
 }
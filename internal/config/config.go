@@ -4,15 +4,29 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/kcthack-auth/pkg/oauth"
+	"github.com/kcthack-auth/pkg/ratelimit"
 	"github.com/spf13/viper"
 )
 
 type Config struct {
+	Log struct {
+		Level  string
+		Format string
+	}
+
 	HTTP struct {
 		Port string
+		// TrustedProxies may set X-Forwarded-For, the client IP of other requests is their remote address.
+		TrustedProxies []string
+	}
+
+	GRPC struct {
+		Port string
 	}
 
 	Database struct {
@@ -21,12 +35,124 @@ type Config struct {
 
 	JWT struct {
 		JWTSecret string
+		// KeyEncryptionKey encrypts the signing keys stored in jwt_keys, base64 of 32 bytes.
+		KeyEncryptionKey string
+		KeyID            string
+		PrivateKeyPath   string
+		Rotation         struct {
+			Algorithm        string
+			PropagationDelay time.Duration
+			ReloadInterval   time.Duration
+		}
 	}
 
 	Auth struct {
 		AccessTTL  time.Duration
 		RefreshTTL time.Duration
 	}
+
+	Mail struct {
+		Driver   string
+		From     string
+		Dir      string
+		Host     string
+		Port     string
+		Username string
+		Password string
+	}
+
+	Verification struct {
+		TokenTTL time.Duration
+		URL      string
+	}
+
+	PasswordReset struct {
+		TokenTTL time.Duration
+		URL      string
+	}
+
+	PasswordHashing struct {
+		Algorithm  string
+		BcryptCost int
+		Argon2id   struct {
+			Memory      uint32
+			Iterations  uint32
+			Parallelism uint8
+			SaltLength  uint32
+			KeyLength   uint32
+		}
+	}
+
+	PasswordPolicy struct {
+		MinLength                 int
+		MaxLength                 int
+		RequireLower              bool
+		RequireUpper              bool
+		RequireDigit              bool
+		RequireSymbol             bool
+		MinScore                  int
+		BreachedList              string
+		BreachedFalsePositiveRate float64
+	}
+
+	RBAC struct {
+		Roles map[string][]string
+	}
+
+	MFA struct {
+		Issuer        string
+		ChallengeTTL  time.Duration
+		RequiredRoles []string
+	}
+
+	WebAuthn struct {
+		RPID          string
+		RPDisplayName string
+		RPOrigins     []string
+		CeremonyTTL   time.Duration
+	}
+
+	Telegram struct {
+		BotToken   string
+		AuthMaxAge time.Duration
+	}
+
+	OAuth struct {
+		CallbackURL string
+		FrontendURL string
+		StateTTL    time.Duration
+		Providers   map[string]oauth.Config
+	}
+
+	OIDC struct {
+		Issuer     string
+		LoginURL   string
+		ConsentURL string
+		RequestTTL time.Duration
+		CodeTTL    time.Duration
+		IDTokenTTL time.Duration
+	}
+
+	RateLimit struct {
+		Enabled bool
+		Store   string
+		Routes  map[string][]ratelimit.Rule
+	}
+
+	Lockout struct {
+		Store            string
+		AccountThreshold int
+		IPThreshold      int
+		Window           time.Duration
+		Duration         time.Duration
+		BaseDelay        time.Duration
+		MaxDelay         time.Duration
+	}
+
+	MachineClients struct {
+		TokenTTL          time.Duration
+		SecretGracePeriod time.Duration
+	}
 }
 
 func Init() (*Config, error) {
@@ -52,6 +178,17 @@ func Init() (*Config, error) {
 		return nil, fmt.Errorf("failed to set vars from .yml: %w", err)
 	}
 
+	// Client secrets are never kept in the yaml file.
+	for name, provider := range cfg.OAuth.Providers {
+		provider.ClientSecret = os.Getenv("OAUTH_" + strings.ToUpper(name) + "_CLIENT_SECRET")
+		cfg.OAuth.Providers[name] = provider
+	}
+
+	// The shared secret is only used when tokens are not signed with a private key.
+	if cfg.JWT.PrivateKeyPath == "" && cfg.JWT.JWTSecret == "" {
+		return nil, errors.New("JWT_SECRET env var is required when jwt.privateKeyPath is not set")
+	}
+
 	return &cfg, nil
 }
 
@@ -62,9 +199,15 @@ func loadFromEnv(cfg *Config) error {
 	}
 
 	cfg.JWT.JWTSecret = os.Getenv("JWT_SECRET")
-	if cfg.JWT.JWTSecret == "" {
-		return errors.New("JWT_SECRET env var is required")
+
+	cfg.JWT.KeyEncryptionKey = os.Getenv("JWT_KEY_ENCRYPTION_KEY")
+	if cfg.JWT.KeyEncryptionKey == "" {
+		return errors.New("JWT_KEY_ENCRYPTION_KEY env var is required")
 	}
 
+	cfg.Mail.Password = os.Getenv("SMTP_PASSWORD")
+
+	cfg.Telegram.BotToken = os.Getenv("TELEGRAM_BOT_TOKEN")
+
 	return nil
 }
@@ -0,0 +1,40 @@
+package domain
+
+import "errors"
+
+var (
+	ErrUserNotFound        = errors.New("user not found")
+	ErrUserAlreadyVerified = errors.New("user is already verified")
+	ErrInvalidToken        = errors.New("token is invalid or expired")
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrSessionExpired      = errors.New("session expired")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected, all sessions of this login were revoked")
+	ErrInvalidCredentials  = errors.New("invalid credentials")
+	ErrSamePassword        = errors.New("new password must differ from the current one")
+	ErrUnknownRole         = errors.New("unknown role")
+	ErrPrimaryRole         = errors.New("primary role cannot be revoked")
+	ErrValidation          = errors.New("validation failed")
+	ErrUserBlocked         = errors.New("user is blocked")
+	ErrSelfAction          = errors.New("this action cannot be applied to your own account")
+	ErrMFANotEnrolled      = errors.New("two-factor authentication is not enabled")
+	ErrMFAAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrMFAEnforced         = errors.New("two-factor authentication is required for your role")
+	ErrInvalidMFACode      = errors.New("invalid two-factor authentication code")
+	ErrPasskeyNotFound     = errors.New("passkey not found")
+	ErrPasskeyCloned       = errors.New("passkey signature counter mismatch, the authenticator may be cloned")
+	ErrPasskeyInvalid      = errors.New("passkey verification failed")
+	ErrTelegramAuth        = errors.New("invalid telegram login data")
+	ErrTelegramDisabled    = errors.New("telegram login is not configured")
+	ErrTelegramNotLinked   = errors.New("telegram account is not linked")
+	ErrTelegramLinked      = errors.New("telegram account is already linked to a user")
+	ErrLastLoginMethod     = errors.New("the last login method of an account cannot be removed")
+	ErrUnknownProvider     = errors.New("unknown identity provider")
+	ErrProviderAuth        = errors.New("identity provider authentication failed")
+	ErrIdentityNotFound    = errors.New("identity is not linked")
+	ErrIdentityLinked      = errors.New("external account is already linked to a user")
+	ErrIdentityEmailTaken  = errors.New("an account with this email already exists, log in and link the provider in your profile")
+	ErrClientNotFound      = errors.New("client not found")
+	ErrConsentNotFound     = errors.New("consent not found")
+	ErrClientRevoked       = errors.New("client is revoked")
+	ErrLoginLocked         = errors.New("too many failed login attempts, try again later")
+)
@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+// Identity links an account at an external OAuth2/OpenID Connect provider to a user.
+// Subject is the stable user id at the provider.
+type Identity struct {
+	ID        string
+	UserID    string
+	Provider  string
+	Subject   string
+	Email     string
+	CreatedAt time.Time
+}
+
+// OAuthState is the server side state of an authorization code flow. UserID is set
+// when an authenticated user links a provider and empty for logins.
+type OAuthState struct {
+	ID        string
+	Token     string
+	Provider  string
+	UserID    string
+	Verifier  string
+	ExpiresAt time.Time
+}
@@ -0,0 +1,13 @@
+package domain
+
+import "time"
+
+// SigningKey is a stored JWT signing key. Material is the HS256 secret or a PEM encoded private key.
+type SigningKey struct {
+	ID          string
+	Algorithm   string
+	Material    []byte
+	ActivatesAt time.Time
+	RetiresAt   *time.Time
+	CreatedAt   time.Time
+}
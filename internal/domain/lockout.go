@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+// LoginAttempts counts the recent login attempts of an account or an IP address, identified by Key.
+// Attempts are counted before the password is checked, so parallel guesses cannot slip past the limit.
+type LoginAttempts struct {
+	Key           string
+	Attempts      int
+	LastAttemptAt time.Time
+	LockedUntil   *time.Time
+}
+
+// LockoutError is returned while logins are locked. It matches ErrLoginLocked.
+type LockoutError struct {
+	Until time.Time
+}
+
+func (e *LockoutError) Error() string {
+	return ErrLoginLocked.Error()
+}
+
+func (e *LockoutError) Is(target error) bool {
+	return target == ErrLoginLocked
+}
@@ -0,0 +1,23 @@
+package domain
+
+import "time"
+
+// MachineClient is an internal service calling other services with tokens of the client credentials grant.
+// Scopes are the scopes the client may request.
+type MachineClient struct {
+	ID        string
+	Name      string
+	Scopes    []string
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// MachineClientSecret is a secret of a machine client. A client has several secrets while a rotated
+// one is still valid. Secret is only set when it is generated, it is stored hashed.
+type MachineClientSecret struct {
+	ID        string
+	ClientID  string
+	Secret    string
+	ExpiresAt *time.Time
+	CreatedAt time.Time
+}
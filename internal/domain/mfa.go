@@ -0,0 +1,28 @@
+package domain
+
+import "time"
+
+const (
+	// MFAChallengeVerify is issued at login to a user with TOTP enabled.
+	MFAChallengeVerify = "verify"
+	// MFAChallengeEnroll is issued at login to a user who must enable TOTP before getting a session.
+	MFAChallengeEnroll = "enroll"
+)
+
+type TOTP struct {
+	UserID      string
+	Secret      string
+	LastStep    int64
+	ConfirmedAt *time.Time
+	CreatedAt   time.Time
+}
+
+type MFAChallenge struct {
+	ID        string
+	UserID    string
+	Token     string
+	Kind      string
+	Attempts  int
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,70 @@
+package domain
+
+import "time"
+
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// OAuth error codes of RFC 6749.
+const (
+	OAuthInvalidRequest          = "invalid_request"
+	OAuthInvalidClient           = "invalid_client"
+	OAuthInvalidGrant            = "invalid_grant"
+	OAuthInvalidScope            = "invalid_scope"
+	OAuthUnauthorizedClient      = "unauthorized_client"
+	OAuthUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthUnsupportedResponseType = "unsupported_response_type"
+	OAuthAccessDenied            = "access_denied"
+	OAuthLoginRequired           = "login_required"
+	OAuthConsentRequired         = "consent_required"
+)
+
+// OAuthError is an error returned to OAuth clients in the RFC 6749 format.
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// OIDCClient is an application using this service as its OpenID Connect provider.
+// Public clients (single page and mobile apps) have no secret. Secret is only set when
+// the client is created, it is stored hashed.
+type OIDCClient struct {
+	ID           string
+	Name         string
+	Secret       string
+	RedirectURIs []string
+	Scopes       []string
+	Public       bool
+	CreatedAt    time.Time
+}
+
+// OIDCAuthorization is an authorization request. Until the user consents it has no code,
+// afterwards Code is the one-time authorization code handed to the client.
+type OIDCAuthorization struct {
+	ID            string
+	ClientID      string
+	UserID        string
+	RedirectURI   string
+	Scope         string
+	State         string
+	Nonce         string
+	CodeChallenge string
+	Code          string
+	AuthTime      time.Time
+	ExpiresAt     time.Time
+}
+
+// OIDCConsent records the scopes a user granted to a client.
+type OIDCConsent struct {
+	UserID    string
+	ClientID  string
+	Scope     string
+	CreatedAt time.Time
+}
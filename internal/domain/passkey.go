@@ -0,0 +1,31 @@
+package domain
+
+import "time"
+
+const (
+	CeremonyRegister = "register"
+	CeremonyLogin    = "login"
+)
+
+// Passkey is a WebAuthn credential of a user. Credential is the JSON encoded credential
+// as produced by the WebAuthn library, CredentialID is its base64url encoded id.
+type Passkey struct {
+	ID           string
+	UserID       string
+	CredentialID string
+	Name         string
+	Credential   []byte
+	SignCount    uint32
+	LastUsedAt   *time.Time
+	CreatedAt    time.Time
+}
+
+// WebAuthnCeremony stores the server side state between the begin and finish steps
+// of a registration or login ceremony. UserID is empty for passwordless logins.
+type WebAuthnCeremony struct {
+	ID        string
+	UserID    string
+	Kind      string
+	Session   []byte
+	ExpiresAt time.Time
+}
@@ -0,0 +1,12 @@
+package domain
+
+const (
+	PermUsersRead      = "users:read"
+	PermUsersManage    = "users:manage"
+	PermRolesManage    = "roles:manage"
+	PermKeysManage     = "keys:manage"
+	PermClientsManage  = "clients:manage"
+	PermTeamsJoin      = "teams:join"
+	PermTeamsManage    = "teams:manage"
+	PermPartnersInvite = "partners:invite"
+)
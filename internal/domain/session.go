@@ -3,9 +3,22 @@ package domain
 import "time"
 
 type Session struct {
-	ID        string
-	UserID    string
-	Token     string
-	ExpiresAt time.Time
-	CreatedAt time.Time
+	ID         string
+	UserID     string
+	FamilyID   string
+	Token      string
+	UserAgent  string
+	IP         string
+	Device     string
+	AuthTime   time.Time // login time of the family, kept by every rotated session
+	ExpiresAt  time.Time
+	RotatedAt  *time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// IsActive reports whether the session can still be used to refresh tokens.
+// Rotated sessions are kept only to detect refresh token reuse.
+func (s *Session) IsActive() bool {
+	return s.RotatedAt == nil && s.ExpiresAt.After(time.Now())
 }
@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+// TelegramAccount links a Telegram user to an account. Username is the Telegram
+// username at the time of the last login and may be empty.
+type TelegramAccount struct {
+	UserID     string
+	TelegramID int64
+	Username   string
+	CreatedAt  time.Time
+}
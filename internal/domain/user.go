@@ -4,6 +4,7 @@ import "time"
 
 const (
 	Participant = "participant"
+	Mentor      = "mentor"
 	Partner     = "partner"
 	Admin       = "admin"
 )
@@ -19,6 +20,17 @@ type User struct {
 	BIO        string
 	PassHash   string
 	IsVerified bool
+	IsBlocked  bool
 	UpdatedAt  time.Time
 	CreatedAt  time.Time
 }
+
+// UserFilter narrows down the admin user listing. Nil and empty fields are not applied.
+type UserFilter struct {
+	Role       string
+	IsVerified *bool
+	IsBlocked  *bool
+	Search     string
+	Limit      int
+	Offset     int
+}
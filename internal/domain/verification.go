@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+type VerificationToken struct {
+	ID        string
+	UserID    string
+	Token     string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
@@ -1,28 +1,59 @@
 package handler
 
 import (
+	"fmt"
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 	"github.com/kcthack-auth/internal/config"
 	v1 "github.com/kcthack-auth/internal/handler/v1"
 	"github.com/kcthack-auth/internal/service"
+	"github.com/kcthack-auth/pkg/auth"
+	"github.com/kcthack-auth/pkg/middleware"
+	"github.com/kcthack-auth/pkg/rbac"
 )
 
 type Handler struct {
-	services *service.Services
+	services     *service.Services
+	tokenManager auth.JWTManager
+	policy       *rbac.Policy
+	limiter      *middleware.RateLimiter
 }
 
-func NewHandler(services *service.Services) *Handler {
+func NewHandler(services *service.Services, tokenManager auth.JWTManager, policy *rbac.Policy, limiter *middleware.RateLimiter) *Handler {
 	return &Handler{
-		services: services,
+		services:     services,
+		tokenManager: tokenManager,
+		policy:       policy,
+		limiter:      limiter,
 	}
 }
 
-func (h *Handler) Init(cfg *config.Config) *gin.Engine {
-	r := gin.Default()
+func (h *Handler) Init(cfg *config.Config) (*gin.Engine, error) {
+	r := gin.New()
+	r.Use(middleware.Logger(), middleware.Recovery())
+
+	// Without trusted proxies X-Forwarded-For is ignored, otherwise any client could choose its IP.
+	if err := r.SetTrustedProxies(cfg.HTTP.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+
+	r.GET("/.well-known/jwks.json", h.jwks)
+	r.GET("/.well-known/openid-configuration", h.openIDConfiguration)
+
+	oauth := r.Group("/oauth")
+	{
+		oauth.GET("/authorize", h.limiter.Limit("oauth_authorize"), h.authorize)
+		oauth.POST("/token", h.limiter.Limit("oauth_token"), h.token)
+		oauth.POST("/introspect", h.limiter.Limit("oauth_introspect"), h.introspect)
+		oauth.POST("/revoke", h.limiter.Limit("oauth_introspect"), h.revoke)
+		oauth.GET("/userinfo", h.userInfo)
+		oauth.POST("/userinfo", h.userInfo)
+	}
 
 	h.initAPI(r)
 
-	return r
+	return r, nil
 }
 
 func (h *Handler) initAPI(r *gin.Engine) {
@@ -34,10 +65,15 @@ func (h *Handler) initAPI(r *gin.Engine) {
 
 		v1Group := api.Group("/v1")
 		{
-			handlerV1 := v1.NewHandler(*h.services)
+			handlerV1 := v1.NewHandler(*h.services, h.tokenManager, h.policy, h.limiter)
 			handlerV1.Init(v1Group)
 		}
 
 	}
 
 }
+
+func (h *Handler) jwks(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.tokenManager.JWKS())
+}
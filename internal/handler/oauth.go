@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/service"
+	"github.com/kcthack-auth/pkg/middleware"
+)
+
+func (h *Handler) openIDConfiguration(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.services.OIDCService.Discovery())
+}
+
+// authorize is the OpenID Connect authorization endpoint. The user is recognized by the
+// access token cookie of this service. Tokens without the login time are treated as no login,
+// so the user logs in again rather than getting an ID token with a wrong auth_time.
+func (h *Handler) authorize(c *gin.Context) {
+	var (
+		userID   string
+		authTime time.Time
+	)
+	if token := middleware.TokenFromRequest(c.Request); token != "" {
+		if claims, err := h.tokenManager.Validate(c.Request.Context(), token); err == nil && claims.ClientID == "" && !claims.AuthTime.IsZero() {
+			userID = claims.UserID
+			authTime = claims.AuthTime
+		}
+	}
+
+	redirect, err := h.services.OIDCService.Authorize(c.Request.Context(), service.AuthorizeReq{
+		UserID:              userID,
+		AuthTime:            authTime,
+		RequestURI:          c.Request.URL.RequestURI(),
+		ClientID:            c.Query("client_id"),
+		RedirectURI:         c.Query("redirect_uri"),
+		ResponseType:        c.Query("response_type"),
+		Scope:               c.Query("scope"),
+		State:               c.Query("state"),
+		Nonce:               c.Query("nonce"),
+		CodeChallenge:       c.Query("code_challenge"),
+		CodeChallengeMethod: c.Query("code_challenge_method"),
+		Prompt:              c.Query("prompt"),
+	})
+	if err != nil {
+		oauthError(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, redirect)
+}
+
+// token is the token endpoint of both OpenID Connect and machine clients.
+// Clients authenticate with HTTP Basic or with credentials in the form.
+func (h *Handler) token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	clientID, clientSecret := clientCredentials(c)
+
+	var (
+		resp *service.TokenResp
+		err  error
+	)
+	// Machine clients use the client credentials grant, OpenID Connect clients the authorization code.
+	if grantType := c.PostForm("grant_type"); grantType == "client_credentials" {
+		resp, err = h.services.MachineClientService.Token(c.Request.Context(), clientID, clientSecret, c.PostForm("scope"))
+	} else {
+		resp, err = h.services.OIDCService.Token(c.Request.Context(), service.TokenReq{
+			GrantType:    grantType,
+			Code:         c.PostForm("code"),
+			RedirectURI:  c.PostForm("redirect_uri"),
+			CodeVerifier: c.PostForm("code_verifier"),
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+		})
+	}
+	if err != nil {
+		oauthError(c, err)
+		return
+	}
+
+	body := gin.H{
+		"access_token": resp.AccessToken,
+		"token_type":   resp.TokenType,
+		"expires_in":   resp.ExpiresIn,
+		"scope":        resp.Scope,
+	}
+	if resp.IDToken != "" {
+		body["id_token"] = resp.IDToken
+	}
+
+	c.JSON(http.StatusOK, body)
+}
+
+// introspect is the RFC 7662 introspection endpoint. token_type_hint is ignored,
+// the type is detected from the token itself.
+func (h *Handler) introspect(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	clientID, clientSecret := clientCredentials(c)
+
+	resp, err := h.services.IntrospectionService.Introspect(c.Request.Context(), service.TokenIntrospectionReq{
+		Token:        c.PostForm("token"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+	})
+	if err != nil {
+		oauthError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// revoke is the RFC 7009 revocation endpoint. It answers 200 for unknown tokens as well.
+func (h *Handler) revoke(c *gin.Context) {
+	clientID, clientSecret := clientCredentials(c)
+
+	if err := h.services.IntrospectionService.Revoke(c.Request.Context(), service.TokenIntrospectionReq{
+		Token:        c.PostForm("token"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+	}); err != nil {
+		oauthError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) userInfo(c *gin.Context) {
+	claims, err := h.services.OIDCService.UserInfo(c.Request.Context(), middleware.TokenFromRequest(c.Request))
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidToken) || errors.Is(err, domain.ErrUserNotFound) {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "invalid_token",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, claims)
+}
+
+// clientCredentials returns the client credentials from HTTP Basic or from the form.
+func clientCredentials(c *gin.Context) (string, string) {
+	clientID, clientSecret, ok := c.Request.BasicAuth()
+	if !ok {
+		return c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+
+	// RFC 6749 2.3.1: the credentials are form encoded before they are put into the header.
+	clientID, _ = url.QueryUnescape(clientID)
+	clientSecret, _ = url.QueryUnescape(clientSecret)
+
+	return clientID, clientSecret
+}
+
+// oauthError writes an error in the RFC 6749 format.
+func oauthError(c *gin.Context, err error) {
+	var oauthErr *domain.OAuthError
+	if !errors.As(err, &oauthErr) {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":             "server_error",
+			"error_description": err.Error(),
+		})
+		return
+	}
+
+	status := http.StatusBadRequest
+	if oauthErr.Code == domain.OAuthInvalidClient {
+		status = http.StatusUnauthorized
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+
+	c.JSON(status, gin.H{
+		"error":             oauthErr.Code,
+		"error_description": oauthErr.Description,
+	})
+}
@@ -0,0 +1,321 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/service"
+	authv1 "github.com/kcthack-auth/pkg/api/auth/v1"
+	"github.com/kcthack-auth/pkg/auth"
+	"github.com/kcthack-auth/pkg/logger"
+	"github.com/kcthack-auth/pkg/middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// scopeUsersRead lets machine clients read any user with GetUser.
+const scopeUsersRead = "users:read"
+
+// Handler implements the gRPC API on top of the same services as the HTTP handlers.
+type Handler struct {
+	authv1.UnimplementedAuthServiceServer
+
+	services     *service.Services
+	tokenManager auth.TokenValidator
+	limiter      *middleware.RateLimiter
+}
+
+func NewHandler(services *service.Services, tokenManager auth.TokenValidator, limiter *middleware.RateLimiter) *Handler {
+	return &Handler{
+		services:     services,
+		tokenManager: tokenManager,
+		limiter:      limiter,
+	}
+}
+
+func (h *Handler) Init() *grpc.Server {
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(logCalls, h.limitCalls))
+	authv1.RegisterAuthServiceServer(s, h)
+
+	return s
+}
+
+func (h *Handler) Register(ctx context.Context, req *authv1.RegisterRequest) (*authv1.AuthResponse, error) {
+	resp, err := h.services.AuthService.Register(ctx, service.RegisterReq{
+		FirstName: req.GetFirstName(),
+		LastName:  req.GetLastName(),
+		Email:     req.GetEmail(),
+		Password:  req.GetPassword(),
+		Client:    clientInfo(ctx),
+	})
+	if err != nil {
+		return nil, statusError(err, codes.InvalidArgument)
+	}
+
+	return authResponse(resp), nil
+}
+
+func (h *Handler) Login(ctx context.Context, req *authv1.LoginRequest) (*authv1.AuthResponse, error) {
+	if req.GetEmail() == "" || req.GetPassword() == "" {
+		return nil, status.Error(codes.InvalidArgument, "email and password are required")
+	}
+
+	resp, err := h.services.AuthService.Login(ctx, &service.LoginReq{
+		Email:    req.GetEmail(),
+		Password: req.GetPassword(),
+		Client:   clientInfo(ctx),
+	})
+	if err != nil {
+		switch {
+		// Unknown emails and wrong passwords are not told apart.
+		case errors.Is(err, domain.ErrInvalidCredentials), errors.Is(err, domain.ErrUserNotFound):
+			return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+		case errors.Is(err, domain.ErrLoginLocked):
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		default:
+			return nil, statusError(err, codes.Internal)
+		}
+	}
+
+	return authResponse(resp), nil
+}
+
+func (h *Handler) RefreshToken(ctx context.Context, req *authv1.RefreshTokenRequest) (*authv1.AuthResponse, error) {
+	if req.GetRefreshToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "refresh token is required")
+	}
+
+	resp, err := h.services.AuthService.RefreshToken(ctx, req.GetRefreshToken(), clientInfo(ctx))
+	if err != nil {
+		return nil, statusError(err, codes.Internal)
+	}
+
+	return authResponse(resp), nil
+}
+
+func (h *Handler) Logout(ctx context.Context, req *authv1.LogoutRequest) (*authv1.LogoutResponse, error) {
+	if req.GetRefreshToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "refresh token is required")
+	}
+
+	if err := h.services.AuthService.Logout(ctx, req.GetRefreshToken()); err != nil {
+		return nil, statusError(err, codes.Internal)
+	}
+
+	return &authv1.LogoutResponse{}, nil
+}
+
+func (h *Handler) ValidateToken(ctx context.Context, req *authv1.ValidateTokenRequest) (*authv1.ValidateTokenResponse, error) {
+	claims, err := h.tokenManager.Validate(ctx, req.GetAccessToken())
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid access token")
+	}
+
+	return &authv1.ValidateTokenResponse{
+		SubjectType: claims.SubjectType,
+		UserId:      claims.UserID,
+		Email:       claims.Email,
+		Role:        claims.Role,
+		Roles:       claims.Roles,
+		Verified:    claims.Verified,
+		ClientId:    claims.ClientID,
+		Scope:       claims.Scope,
+		TokenId:     claims.ID,
+		ExpiresAt:   &timestamppb.Timestamp{Seconds: int64(claims.ExpAt)},
+	}, nil
+}
+
+func (h *Handler) GetUser(ctx context.Context, req *authv1.GetUserRequest) (*authv1.GetUserResponse, error) {
+	claims, err := h.tokenManager.Validate(ctx, tokenFromContext(ctx))
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid access token")
+	}
+
+	own := claims.SubjectType == auth.SubjectUser && claims.ClientID == "" && claims.UserID == req.GetUserId()
+	if !own && !slices.Contains(strings.Fields(claims.Scope), scopeUsersRead) {
+		return nil, status.Error(codes.PermissionDenied, "missing scope: "+scopeUsersRead)
+	}
+
+	user, err := h.services.UserService.Get(ctx, req.GetUserId())
+	if err != nil {
+		return nil, statusError(err, codes.Internal)
+	}
+
+	return &authv1.GetUserResponse{
+		User: &authv1.User{
+			Id:           user.ID,
+			FirstName:    user.FirstName,
+			LastName:     user.LastName,
+			Email:        user.Email,
+			Role:         user.Role,
+			TelegramName: user.TgName,
+			Verified:     user.IsVerified,
+			Blocked:      user.IsBlocked,
+			CreatedAt:    timestamppb.New(user.CreatedAt),
+		},
+	}, nil
+}
+
+func authResponse(resp *service.AuthResp) *authv1.AuthResponse {
+	if resp.MFA != nil {
+		return &authv1.AuthResponse{
+			Mfa: &authv1.MFAChallenge{
+				Kind:      resp.MFA.Kind,
+				Token:     resp.MFA.Token,
+				ExpiresAt: timestamppb.New(resp.MFA.ExpiresAt),
+			},
+		}
+	}
+
+	return &authv1.AuthResponse{
+		AccessToken:  resp.AccessToken,
+		RefreshToken: resp.RefreshToken,
+		ExpiresAt:    timestamppb.New(resp.ExpiresAt),
+	}
+}
+
+// clientInfo describes the caller for the sessions it creates, like clientInfo of the HTTP handlers.
+func clientInfo(ctx context.Context) service.ClientInfo {
+	var client service.ClientInfo
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ua := md.Get("user-agent"); len(ua) > 0 {
+			client.UserAgent = ua[0]
+		}
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		client.IP = host
+	}
+
+	return client
+}
+
+// requestIDKey is the metadata key of the request id, the same as the X-Request-Id header of the HTTP API.
+const requestIDKey = "x-request-id"
+
+// maxRequestIDLength limits request ids taken from the client.
+const maxRequestIDLength = 128
+
+// logCalls adds the request id and method to the log fields of the call context and logs every call.
+// The request id is taken from the metadata or generated and is sent back in the header.
+func logCalls(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+
+	var requestID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIDKey); len(values) > 0 && len(values[0]) <= maxRequestIDLength {
+			requestID = values[0]
+		}
+	}
+	if requestID == "" {
+		requestID = uuid.NewString()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, requestID))
+
+	ctx = logger.With(ctx, "request_id", requestID, "route", info.FullMethod)
+	resp, err := handler(ctx, req)
+
+	code := status.Code(err)
+	level := slog.LevelInfo
+	if code == codes.Internal || code == codes.Unknown {
+		level = slog.LevelError
+	}
+
+	slog.LogAttrs(ctx, level, "call",
+		slog.String("code", code.String()),
+		slog.Duration("latency", time.Since(start)),
+		slog.String("ip", clientInfo(ctx).IP),
+	)
+
+	return resp, err
+}
+
+// rateLimitRoutes are the rate limit routes of the limited methods. They are separate from the
+// routes of the HTTP API because the peer of a call is usually a backend, not the user.
+var rateLimitRoutes = map[string]string{
+	authv1.AuthService_Register_FullMethodName:     "grpc_register",
+	authv1.AuthService_Login_FullMethodName:        "grpc_login",
+	authv1.AuthService_RefreshToken_FullMethodName: "grpc_refresh",
+	authv1.AuthService_Logout_FullMethodName:       "grpc_logout",
+}
+
+// limitCalls applies the rate limits of the method, rules are keyed by the peer IP or the email of
+// the request. Calls over the limit fail with ResourceExhausted and a retry-after header in seconds.
+func (h *Handler) limitCalls(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	route, ok := rateLimitRoutes[info.FullMethod]
+	if !ok {
+		return handler(ctx, req)
+	}
+
+	result := h.limiter.Check(ctx, route, func(key string) string {
+		switch key {
+		case middleware.RateLimitByIP:
+			return clientInfo(ctx).IP
+		case middleware.RateLimitByEmail:
+			if r, ok := req.(interface{ GetEmail() string }); ok {
+				return strings.ToLower(strings.TrimSpace(r.GetEmail()))
+			}
+		}
+		return ""
+	})
+
+	if result != nil && !result.Allowed {
+		_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds())))))
+		return nil, status.Error(codes.ResourceExhausted, "too many requests")
+	}
+
+	return handler(ctx, req)
+}
+
+// tokenFromContext returns the bearer token of the authorization metadata.
+func tokenFromContext(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return ""
+	}
+
+	if scheme, token, ok := strings.Cut(values[0], " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+
+	return ""
+}
+
+// statusError converts the errors of the services to gRPC statuses, unknown errors get the fallback code.
+func statusError(err error, fallback codes.Code) error {
+	switch {
+	case errors.Is(err, domain.ErrValidation):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrUserNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, domain.ErrUserBlocked):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, domain.ErrSessionNotFound), errors.Is(err, domain.ErrSessionExpired), errors.Is(err, domain.ErrRefreshTokenReused):
+		return status.Error(codes.Unauthenticated, err.Error())
+	default:
+		return status.Error(fallback, err.Error())
+	}
+}
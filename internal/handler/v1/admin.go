@@ -0,0 +1,203 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/pkg/middleware"
+)
+
+type adminUserResp struct {
+	userProfileResp
+	IsBlocked bool     `json:"is_blocked"`
+	Roles     []string `json:"roles,omitempty"`
+}
+
+type adminUsersListReq struct {
+	Role     string `form:"role"`
+	Verified *bool  `form:"verified"`
+	Blocked  *bool  `form:"blocked"`
+	Search   string `form:"search" binding:"max=100"`
+	Limit    int    `form:"limit" binding:"min=0,max=100"`
+	Offset   int    `form:"offset" binding:"min=0"`
+}
+
+type adminUsersListResp struct {
+	Users  []adminUserResp `json:"users"`
+	Total  int             `json:"total"`
+	Limit  int             `json:"limit"`
+	Offset int             `json:"offset"`
+}
+
+type adminChangeRoleReq struct {
+	Role string `json:"role" binding:"required,max=50"`
+}
+
+func newAdminUserResp(user *domain.User, roles []string) adminUserResp {
+	return adminUserResp{
+		userProfileResp: newUserProfileResp(user),
+		IsBlocked:       user.IsBlocked,
+		Roles:           roles,
+	}
+}
+
+func (h *Handler) listUsers(c *gin.Context) {
+	var req adminUsersListReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	filter := domain.UserFilter{
+		Role:       req.Role,
+		IsVerified: req.Verified,
+		IsBlocked:  req.Blocked,
+		Search:     req.Search,
+		Limit:      req.Limit,
+		Offset:     req.Offset,
+	}
+
+	users, total, err := h.services.AdminService.ListUsers(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	resp := adminUsersListResp{
+		Users:  make([]adminUserResp, 0, len(users)),
+		Total:  total,
+		Limit:  req.Limit,
+		Offset: req.Offset,
+	}
+	for i := range users {
+		resp.Users = append(resp.Users, newAdminUserResp(&users[i], nil))
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) getUser(c *gin.Context) {
+	user, roles, err := h.services.AdminService.GetUser(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newAdminUserResp(user, roles))
+}
+
+func (h *Handler) changeUserRole(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	var req adminChangeRoleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := h.services.AdminService.ChangeRole(c.Request.Context(), claims.UserID, c.Param("id"), req.Role); err != nil {
+		h.adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "user role changed successfully",
+	})
+}
+
+func (h *Handler) verifyUser(c *gin.Context) {
+	if err := h.services.AdminService.Verify(c.Request.Context(), c.Param("id")); err != nil {
+		h.adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "user verified successfully",
+	})
+}
+
+func (h *Handler) blockUser(c *gin.Context) {
+	h.setUserBlocked(c, true)
+}
+
+func (h *Handler) unblockUser(c *gin.Context) {
+	h.setUserBlocked(c, false)
+}
+
+func (h *Handler) setUserBlocked(c *gin.Context, blocked bool) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	if err := h.services.AdminService.SetBlocked(c.Request.Context(), claims.UserID, c.Param("id"), blocked); err != nil {
+		h.adminError(c, err)
+		return
+	}
+
+	message := "user unblocked successfully"
+	if blocked {
+		message = "user blocked successfully"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+	})
+}
+
+func (h *Handler) logoutUser(c *gin.Context) {
+	if err := h.services.AdminService.Logout(c.Request.Context(), c.Param("id")); err != nil {
+		h.adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "user logged out of all sessions",
+	})
+}
+
+// unlockUser lifts the lockout after too many failed logins.
+func (h *Handler) unlockUser(c *gin.Context) {
+	if err := h.services.LockoutService.Unlock(c.Request.Context(), c.Param("id")); err != nil {
+		h.adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "user unlocked successfully",
+	})
+}
+
+func (h *Handler) adminError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrUnknownRole), errors.Is(err, domain.ErrUserAlreadyVerified), errors.Is(err, domain.ErrSelfAction):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+	}
+}
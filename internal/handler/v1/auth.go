@@ -1,10 +1,14 @@
 package v1
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kcthack-auth/internal/domain"
 	"github.com/kcthack-auth/internal/service"
 )
 
@@ -12,7 +16,7 @@ type userRegisterReq struct {
 	FirstName string `json:"first_name" binding:"required,max=32"`
 	LastName  string `json:"last_name" binding:"required,max=32"`
 	Email     string `json:"email" binding:"required,email,max=32"`
-	Password  string `json:"password" binding:"required,min=6"`
+	Password  string `json:"password" binding:"required,max=72"`
 }
 
 type userLoginReq struct {
@@ -40,6 +44,7 @@ func (h *Handler) register(c *gin.Context) {
 		LastName:  req.LastName,
 		Email:     req.Email,
 		Password:  req.Password,
+		Client:    clientInfo(c),
 	})
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -48,8 +53,7 @@ func (h *Handler) register(c *gin.Context) {
 		return
 	}
 
-	c.SetCookie("access_token", resp.AccessToken, int(resp.ExpiresAt.Unix()), "/", "", false, true)
-	c.SetCookie("refresh_token", resp.RefreshToken, int(resp.ExpiresAt.Unix()), "/", "", false, true)
+	setAuthCookies(c, resp)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "registration successful",
@@ -68,16 +72,30 @@ func (h *Handler) login(c *gin.Context) {
 	resp, err := h.services.AuthService.Login(c.Request.Context(), &service.LoginReq{
 		Email:    req.Email,
 		Password: req.Password,
+		Client:   clientInfo(c),
 	})
 	if err != nil {
+		if lockedOut(c, err) {
+			return
+		}
+
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 
-	c.SetCookie("access_token", resp.AccessToken, int(resp.ExpiresAt.Unix()), "/", "", false, true)
-	c.SetCookie("refresh_token", resp.RefreshToken, int(resp.ExpiresAt.Unix()), "/", "", false, true)
+	if resp.MFA != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required":            resp.MFA.Kind == domain.MFAChallengeVerify,
+			"mfa_enrollment_required": resp.MFA.Kind == domain.MFAChallengeEnroll,
+			"mfa_token":               resp.MFA.Token,
+			"expires_at":              resp.MFA.ExpiresAt,
+		})
+		return
+	}
+
+	setAuthCookies(c, resp)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "login successful",
@@ -100,8 +118,7 @@ func (h *Handler) logout(c *gin.Context) {
 		return
 	}
 
-	c.SetCookie("access_token", "", -1, "/", "", false, true)
-	c.SetCookie("refresh_token", "", -1, "/", "", false, true)
+	clearAuthCookies(c)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "logout successful",
@@ -117,18 +134,53 @@ func (h *Handler) refresh(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.services.AuthService.RefreshToken(c.Request.Context(), refreshToken)
+	resp, err := h.services.AuthService.RefreshToken(c.Request.Context(), refreshToken, clientInfo(c))
 	if err != nil {
+		if errors.Is(err, domain.ErrRefreshTokenReused) || errors.Is(err, domain.ErrSessionExpired) || errors.Is(err, domain.ErrSessionNotFound) {
+			clearAuthCookies(c)
+		}
+
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 
-	c.SetCookie("access_token", resp.AccessToken, int(resp.ExpiresAt.Unix()), "/", "", false, true)
-	c.SetCookie("refresh_token", resp.RefreshToken, int(resp.ExpiresAt.Unix()), "/", "", false, true)
+	setAuthCookies(c, resp)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "tokens refreshed successfully",
 	})
 }
+
+func clientInfo(c *gin.Context) service.ClientInfo {
+	return service.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+}
+
+func setAuthCookies(c *gin.Context, resp *service.AuthResp) {
+	c.SetCookie("access_token", resp.AccessToken, int(resp.ExpiresAt.Unix()), "/", "", false, true)
+	c.SetCookie("refresh_token", resp.RefreshToken, int(resp.ExpiresAt.Unix()), "/", "", false, true)
+}
+
+func clearAuthCookies(c *gin.Context) {
+	c.SetCookie("access_token", "", -1, "/", "", false, true)
+	c.SetCookie("refresh_token", "", -1, "/", "", false, true)
+}
+
+// lockedOut answers 429 with Retry-After if err is a *domain.LockoutError.
+func lockedOut(c *gin.Context, err error) bool {
+	var lockout *domain.LockoutError
+	if !errors.As(err, &lockout) {
+		return false
+	}
+
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(lockout.Until).Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error": err.Error(),
+	})
+
+	return true
+}
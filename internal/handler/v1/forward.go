@@ -0,0 +1,35 @@
+package v1
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kcthack-auth/pkg/middleware"
+)
+
+// verifyForwardAuth is the target of nginx auth_request and Traefik ForwardAuth. The proxy passes
+// the user on to the upstream with the X-User-* headers. An optional role query param requires
+// the user to have that role. X-User-Email is empty for users without an email.
+func (h *Handler) verifyForwardAuth(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	if role := c.Query("role"); role != "" && !slices.Contains(claims.Roles, role) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "role " + role + " required",
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-User-Id", claims.UserID)
+	c.Header("X-User-Role", claims.Role)
+	c.Header("X-User-Email", claims.Email)
+	c.Status(http.StatusOK)
+}
@@ -2,23 +2,136 @@ package v1
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/kcthack-auth/internal/domain"
 	"github.com/kcthack-auth/internal/service"
+	"github.com/kcthack-auth/pkg/auth"
+	"github.com/kcthack-auth/pkg/middleware"
+	"github.com/kcthack-auth/pkg/rbac"
 )
 
 type Handler struct {
-	services *service.Services
+	services     *service.Services
+	tokenManager auth.JWTManager
+	policy       *rbac.Policy
+	limiter      *middleware.RateLimiter
 }
 
-func NewHandler(services service.Services) *Handler {
-	return &Handler{services: &services}
+func NewHandler(services service.Services, tokenManager auth.JWTManager, policy *rbac.Policy, limiter *middleware.RateLimiter) *Handler {
+	return &Handler{
+		services:     &services,
+		tokenManager: tokenManager,
+		policy:       policy,
+		limiter:      limiter,
+	}
 }
 
 func (h *Handler) Init(a *gin.RouterGroup) {
 	user := a.Group("/user")
 	{
-		user.POST("/register", h.register)
-		user.POST("/login", h.login)
-		user.POST("/logout", h.logout)
-		user.POST("/refresh", h.refresh)
+		user.POST("/register", h.limiter.Limit("register"), h.register)
+		user.POST("/login", h.limiter.Limit("login"), h.login)
+		user.POST("/login/mfa", h.limiter.Limit("login_mfa"), h.loginMFA)
+		user.POST("/login/mfa/enroll", h.limiter.Limit("login_mfa"), h.loginMFAEnroll)
+		user.POST("/login/mfa/enroll/confirm", h.limiter.Limit("login_mfa"), h.loginMFAEnrollConfirm)
+		user.POST("/login/passkey/begin", h.limiter.Limit("login_passkey"), h.beginPasskeyLogin)
+		user.POST("/login/passkey/finish", h.limiter.Limit("login_passkey"), h.finishPasskeyLogin)
+		user.POST("/login/telegram", h.limiter.Limit("login_telegram"), h.loginTelegram)
+		user.GET("/oauth/providers", h.oauthProviders)
+		user.POST("/oauth/:provider/start", h.limiter.Limit("oauth_start"), h.startOAuthLogin)
+		user.GET("/oauth/:provider/callback", h.oauthCallback)
+		user.POST("/logout", h.limiter.Limit("logout"), h.logout)
+		user.POST("/refresh", h.limiter.Limit("refresh"), h.refresh)
+		user.POST("/verify", h.limiter.Limit("verify"), h.verify)
+		user.POST("/verify/resend", h.limiter.Limit("verify_resend"), h.resendVerification)
+		user.POST("/password/forgot", h.limiter.Limit("password_forgot"), h.forgotPassword)
+		user.POST("/password/reset", h.limiter.Limit("password_reset"), h.resetPassword)
+
+		authenticated := user.Group("", middleware.Auth(h.tokenManager))
+		{
+			authenticated.POST("/password/change", h.limiter.Limit("password_change"), h.changePassword)
+			authenticated.GET("/permissions", h.permissions)
+			authenticated.GET("/me", h.getMe)
+			authenticated.PATCH("/me", h.updateMe)
+			authenticated.GET("/sessions", h.listSessions)
+			authenticated.DELETE("/sessions", h.revokeOtherSessions)
+			authenticated.DELETE("/sessions/:id", h.revokeSession)
+			authenticated.GET("/mfa", h.mfaStatus)
+			authenticated.POST("/mfa/totp/enroll", h.enrollTOTP)
+			authenticated.POST("/mfa/totp/confirm", h.confirmTOTP)
+			authenticated.POST("/mfa/totp/disable", h.disableTOTP)
+			authenticated.POST("/mfa/recovery-codes", h.regenerateRecoveryCodes)
+			authenticated.GET("/passkeys", h.listPasskeys)
+			authenticated.POST("/passkeys/register/begin", h.beginPasskeyRegistration)
+			authenticated.POST("/passkeys/register/finish", h.finishPasskeyRegistration)
+			authenticated.DELETE("/passkeys/:id", h.deletePasskey)
+			authenticated.GET("/telegram", h.getTelegram)
+			authenticated.POST("/telegram/link", h.linkTelegram)
+			authenticated.DELETE("/telegram", h.unlinkTelegram)
+			authenticated.POST("/oauth/:provider/link", h.startOAuthLink)
+			authenticated.GET("/identities", h.listIdentities)
+			authenticated.DELETE("/identities/:provider", h.unlinkIdentity)
+		}
+	}
+
+	forwardAuth := a.Group("/auth", middleware.Auth(h.tokenManager))
+	{
+		forwardAuth.GET("/verify", h.verifyForwardAuth)
+	}
+
+	oauth := a.Group("/oauth", middleware.Auth(h.tokenManager))
+	{
+		oauth.GET("/consent/:id", h.getConsent)
+		oauth.POST("/consent/:id", h.decideConsent)
+	}
+
+	admin := a.Group("/admin", middleware.Auth(h.tokenManager))
+	{
+		users := admin.Group("/users")
+		{
+			read := users.Group("", middleware.RequirePermission(h.policy, domain.PermUsersRead))
+			{
+				read.GET("", h.listUsers)
+				read.GET("/:id", h.getUser)
+			}
+
+			manage := users.Group("/:id", middleware.RequirePermission(h.policy, domain.PermUsersManage))
+			{
+				manage.PUT("/role", h.changeUserRole)
+				manage.POST("/verify", h.verifyUser)
+				manage.POST("/block", h.blockUser)
+				manage.POST("/unblock", h.unblockUser)
+				manage.POST("/unlock", h.unlockUser)
+				manage.POST("/logout", h.logoutUser)
+			}
+		}
+
+		keys := admin.Group("/keys", middleware.RequirePermission(h.policy, domain.PermKeysManage))
+		{
+			keys.GET("", h.listKeys)
+			keys.POST("/rotate", h.rotateKey)
+		}
+
+		clients := admin.Group("/oidc/clients", middleware.RequirePermission(h.policy, domain.PermClientsManage))
+		{
+			clients.GET("", h.listClients)
+			clients.POST("", h.createClient)
+			clients.DELETE("/:id", h.deleteClient)
+		}
+
+		machineClients := admin.Group("/machine-clients", middleware.RequirePermission(h.policy, domain.PermClientsManage))
+		{
+			machineClients.GET("", h.listMachineClients)
+			machineClients.POST("", h.createMachineClient)
+			machineClients.GET("/:id", h.getMachineClient)
+			machineClients.POST("/:id/secrets", h.rotateMachineSecret)
+			machineClients.DELETE("/:id", h.revokeMachineClient)
+		}
+
+		roles := admin.Group("/users/:id/roles", middleware.RequirePermission(h.policy, domain.PermRolesManage))
+		{
+			roles.GET("", h.listUserRoles)
+			roles.POST("", h.assignUserRole)
+			roles.DELETE("/:role", h.revokeUserRole)
+		}
 	}
 }
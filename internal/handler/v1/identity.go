@@ -0,0 +1,222 @@
+package v1
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/service"
+	"github.com/kcthack-auth/pkg/logger"
+	"github.com/kcthack-auth/pkg/middleware"
+)
+
+const (
+	oauthStateCookie = "oauth_state"
+	oauthCookiePath  = "/api/v1/user/oauth"
+)
+
+// Error codes the callback passes to the frontend. The text of internal errors never leaves the server.
+const (
+	oauthErrorAccessDenied    = "access_denied"
+	oauthErrorProvider        = "provider_error"
+	oauthErrorInvalidState    = "invalid_state"
+	oauthErrorUnknownProvider = "unknown_provider"
+	oauthErrorUserBlocked     = "user_blocked"
+	oauthErrorIdentityLinked  = "identity_linked"
+	oauthErrorEmailTaken      = "email_taken"
+	oauthErrorServer          = "server_error"
+)
+
+type identityResp struct {
+	Provider  string    `json:"provider"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (h *Handler) oauthProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"providers": h.services.IdentityService.Providers(),
+	})
+}
+
+func (h *Handler) startOAuthLogin(c *gin.Context) {
+	h.startOAuth(c, "")
+}
+
+func (h *Handler) startOAuthLink(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	h.startOAuth(c, claims.UserID)
+}
+
+// startOAuth returns the provider authorization url. The state is also set as a cookie, so the
+// callback only succeeds in the browser that started the flow.
+func (h *Handler) startOAuth(c *gin.Context, userID string) {
+	start, err := h.services.IdentityService.Start(c.Request.Context(), c.Param("provider"), userID)
+	if err != nil {
+		h.identityError(c, err)
+		return
+	}
+
+	c.SetCookie(oauthStateCookie, start.State, int(time.Until(start.ExpiresAt).Seconds()), oauthCookiePath, "", false, true)
+
+	c.JSON(http.StatusOK, gin.H{
+		"authorization_url": start.URL,
+	})
+}
+
+// oauthCallback is where the provider redirects the browser. The result is passed on
+// to the frontend as query parameters of the configured result page.
+func (h *Handler) oauthCallback(c *gin.Context) {
+	state, _ := c.Cookie(oauthStateCookie)
+	c.SetCookie(oauthStateCookie, "", -1, oauthCookiePath, "", false, true)
+
+	result := url.Values{}
+	redirect := func() {
+		c.Redirect(http.StatusFound, h.services.IdentityService.ResultURL(result))
+	}
+
+	if providerErr := c.Query("error"); providerErr != "" {
+		result.Set("status", "error")
+		result.Set("error", oauthErrorProvider)
+		if providerErr == oauthErrorAccessDenied {
+			result.Set("error", oauthErrorAccessDenied)
+		}
+		redirect()
+		return
+	}
+
+	if state == "" || state != c.Query("state") || c.Query("code") == "" {
+		result.Set("status", "error")
+		result.Set("error", oauthErrorInvalidState)
+		redirect()
+		return
+	}
+
+	resp, err := h.services.IdentityService.Callback(c.Request.Context(), service.OAuthCallbackReq{
+		Provider: c.Param("provider"),
+		State:    state,
+		Code:     c.Query("code"),
+		Params:   c.Request.URL.Query(),
+		Client:   clientInfo(c),
+	})
+	switch {
+	case err != nil:
+		result.Set("status", "error")
+		result.Set("error", oauthErrorCode(c, err))
+	case resp.Identity != nil:
+		result.Set("status", "linked")
+		result.Set("provider", resp.Identity.Provider)
+	case resp.Auth.MFA != nil:
+		result.Set("status", "mfa_required")
+		if resp.Auth.MFA.Kind == domain.MFAChallengeEnroll {
+			result.Set("status", "mfa_enrollment_required")
+		}
+		result.Set("mfa_token", resp.Auth.MFA.Token)
+	default:
+		setAuthCookies(c, resp.Auth)
+		result.Set("status", "success")
+	}
+
+	redirect()
+}
+
+func (h *Handler) listIdentities(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	identities, err := h.services.IdentityService.List(c.Request.Context(), claims.UserID)
+	if err != nil {
+		h.identityError(c, err)
+		return
+	}
+
+	resp := make([]identityResp, 0, len(identities))
+	for _, identity := range identities {
+		resp = append(resp, identityResp{
+			Provider:  identity.Provider,
+			Email:     identity.Email,
+			CreatedAt: identity.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"identities": resp,
+	})
+}
+
+func (h *Handler) unlinkIdentity(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	if err := h.services.IdentityService.Unlink(c.Request.Context(), claims.UserID, c.Param("provider")); err != nil {
+		h.identityError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "identity unlinked",
+	})
+}
+
+func (h *Handler) identityError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidToken), errors.Is(err, domain.ErrProviderAuth), errors.Is(err, domain.ErrUserBlocked):
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrIdentityLinked), errors.Is(err, domain.ErrIdentityEmailTaken), errors.Is(err, domain.ErrLastLoginMethod):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrUnknownProvider), errors.Is(err, domain.ErrIdentityNotFound), errors.Is(err, domain.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+	}
+}
+
+// oauthErrorCode maps a callback error to its code. Unexpected errors are logged and reported as server_error.
+func oauthErrorCode(c *gin.Context, err error) string {
+	switch {
+	case errors.Is(err, domain.ErrInvalidToken):
+		return oauthErrorInvalidState
+	case errors.Is(err, domain.ErrProviderAuth):
+		return oauthErrorProvider
+	case errors.Is(err, domain.ErrUnknownProvider):
+		return oauthErrorUnknownProvider
+	case errors.Is(err, domain.ErrUserBlocked):
+		return oauthErrorUserBlocked
+	case errors.Is(err, domain.ErrIdentityLinked):
+		return oauthErrorIdentityLinked
+	case errors.Is(err, domain.ErrIdentityEmailTaken):
+		return oauthErrorEmailTaken
+	default:
+		slog.ErrorContext(c.Request.Context(), "failed to complete oauth callback", slog.String("provider", c.Param("provider")), logger.Err(err))
+		return oauthErrorServer
+	}
+}
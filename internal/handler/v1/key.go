@@ -0,0 +1,80 @@
+package v1
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kcthack-auth/internal/domain"
+)
+
+type keyResp struct {
+	ID          string     `json:"id"`
+	Algorithm   string     `json:"algorithm"`
+	ActivatesAt time.Time  `json:"activates_at"`
+	RetiresAt   *time.Time `json:"retires_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type keyRotateReq struct {
+	Algorithm string `json:"algorithm" binding:"omitempty,oneof=RS256 EdDSA"`
+}
+
+func newKeyResp(key *domain.SigningKey) keyResp {
+	return keyResp{
+		ID:          key.ID,
+		Algorithm:   key.Algorithm,
+		ActivatesAt: key.ActivatesAt,
+		RetiresAt:   key.RetiresAt,
+		CreatedAt:   key.CreatedAt,
+	}
+}
+
+func (h *Handler) listKeys(c *gin.Context) {
+	keys, err := h.services.KeyService.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	resp := make([]keyResp, 0, len(keys))
+	for i := range keys {
+		resp = append(resp, newKeyResp(&keys[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"keys": resp,
+	})
+}
+
+func (h *Handler) rotateKey(c *gin.Context) {
+	// The body is optional, without it the configured algorithm is used.
+	var req keyRotateReq
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	key, err := h.services.KeyService.Rotate(c.Request.Context(), req.Algorithm)
+	if err != nil {
+		if errors.Is(err, domain.ErrValidation) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, newKeyResp(key))
+}
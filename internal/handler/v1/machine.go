@@ -0,0 +1,162 @@
+package v1
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kcthack-auth/internal/domain"
+)
+
+type createMachineClientReq struct {
+	Name   string   `json:"name" binding:"required,max=100"`
+	Scopes []string `json:"scopes" binding:"required,min=1"`
+}
+
+type rotateMachineSecretReq struct {
+	RevokeOld bool `json:"revoke_old"`
+}
+
+type machineClientResp struct {
+	ClientID  string     `json:"client_id"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type machineSecretResp struct {
+	ID        string     `json:"id"`
+	Secret    string     `json:"client_secret,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func newMachineClientResp(client *domain.MachineClient) machineClientResp {
+	return machineClientResp{
+		ClientID:  client.ID,
+		Name:      client.Name,
+		Scopes:    client.Scopes,
+		RevokedAt: client.RevokedAt,
+		CreatedAt: client.CreatedAt,
+	}
+}
+
+func newMachineSecretResp(secret *domain.MachineClientSecret) machineSecretResp {
+	return machineSecretResp{
+		ID:        secret.ID,
+		Secret:    secret.Secret,
+		ExpiresAt: secret.ExpiresAt,
+		CreatedAt: secret.CreatedAt,
+	}
+}
+
+func (h *Handler) listMachineClients(c *gin.Context) {
+	clients, err := h.services.MachineClientService.List(c.Request.Context())
+	if err != nil {
+		h.machineClientError(c, err)
+		return
+	}
+
+	resp := make([]machineClientResp, 0, len(clients))
+	for i := range clients {
+		resp = append(resp, newMachineClientResp(&clients[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"clients": resp,
+	})
+}
+
+// createMachineClient registers a client. The secret is only returned here and on rotation.
+func (h *Handler) createMachineClient(c *gin.Context) {
+	var req createMachineClientReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	client, secret, err := h.services.MachineClientService.Create(c.Request.Context(), req.Name, req.Scopes)
+	if err != nil {
+		h.machineClientError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"client": newMachineClientResp(client),
+		"secret": newMachineSecretResp(secret),
+	})
+}
+
+func (h *Handler) getMachineClient(c *gin.Context) {
+	client, secrets, err := h.services.MachineClientService.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.machineClientError(c, err)
+		return
+	}
+
+	secretsResp := make([]machineSecretResp, 0, len(secrets))
+	for i := range secrets {
+		secretsResp = append(secretsResp, newMachineSecretResp(&secrets[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"client":  newMachineClientResp(client),
+		"secrets": secretsResp,
+	})
+}
+
+func (h *Handler) rotateMachineSecret(c *gin.Context) {
+	// The body is optional, by default old secrets stay valid for the grace period.
+	var req rotateMachineSecretReq
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	secret, err := h.services.MachineClientService.RotateSecret(c.Request.Context(), c.Param("id"), req.RevokeOld)
+	if err != nil {
+		h.machineClientError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newMachineSecretResp(secret))
+}
+
+func (h *Handler) revokeMachineClient(c *gin.Context) {
+	if err := h.services.MachineClientService.Revoke(c.Request.Context(), c.Param("id")); err != nil {
+		h.machineClientError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "client revoked",
+	})
+}
+
+func (h *Handler) machineClientError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrValidation):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrClientNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrClientRevoked):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+	}
+}
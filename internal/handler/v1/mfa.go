@@ -0,0 +1,263 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/service"
+	"github.com/kcthack-auth/pkg/middleware"
+)
+
+type mfaCodeReq struct {
+	Code string `json:"code" binding:"required,max=16"`
+}
+
+type mfaDisableReq struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required,max=16"`
+}
+
+type mfaLoginReq struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required,max=16"`
+}
+
+type mfaLoginEnrollReq struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+type totpEnrollmentResp struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+func (h *Handler) mfaStatus(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	status, err := h.services.MFAService.Status(c.Request.Context(), claims.UserID)
+	if err != nil {
+		h.mfaError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":             status.Enabled,
+		"enforced":            status.Enforced,
+		"recovery_codes_left": status.RecoveryCodesLeft,
+	})
+}
+
+func (h *Handler) enrollTOTP(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	enrollment, err := h.services.MFAService.Enroll(c.Request.Context(), claims.UserID)
+	if err != nil {
+		h.mfaError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, totpEnrollmentResp{
+		Secret: enrollment.Secret,
+		URI:    enrollment.URI,
+	})
+}
+
+func (h *Handler) confirmTOTP(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	var req mfaCodeReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	codes, err := h.services.MFAService.Confirm(c.Request.Context(), claims.UserID, req.Code)
+	if err != nil {
+		h.mfaError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recovery_codes": codes,
+	})
+}
+
+func (h *Handler) disableTOTP(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	var req mfaDisableReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := h.services.MFAService.Disable(c.Request.Context(), claims.UserID, req.Password, req.Code); err != nil {
+		h.mfaError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "two-factor authentication disabled",
+	})
+}
+
+func (h *Handler) regenerateRecoveryCodes(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	var req mfaCodeReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	codes, err := h.services.MFAService.RegenerateRecoveryCodes(c.Request.Context(), claims.UserID, req.Code)
+	if err != nil {
+		h.mfaError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recovery_codes": codes,
+	})
+}
+
+func (h *Handler) loginMFA(c *gin.Context) {
+	var req mfaLoginReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.services.AuthService.LoginMFA(c.Request.Context(), &service.LoginMFAReq{
+		Token:  req.MFAToken,
+		Code:   req.Code,
+		Client: clientInfo(c),
+	})
+	if err != nil {
+		h.mfaError(c, err)
+		return
+	}
+
+	setAuthCookies(c, resp)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "login successful",
+	})
+}
+
+func (h *Handler) loginMFAEnroll(c *gin.Context) {
+	var req mfaLoginEnrollReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	enrollment, err := h.services.AuthService.LoginMFAEnroll(c.Request.Context(), req.MFAToken)
+	if err != nil {
+		h.mfaError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, totpEnrollmentResp{
+		Secret: enrollment.Secret,
+		URI:    enrollment.URI,
+	})
+}
+
+func (h *Handler) loginMFAEnrollConfirm(c *gin.Context) {
+	var req mfaLoginReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.services.AuthService.LoginMFAEnrollConfirm(c.Request.Context(), &service.LoginMFAReq{
+		Token:  req.MFAToken,
+		Code:   req.Code,
+		Client: clientInfo(c),
+	})
+	if err != nil {
+		h.mfaError(c, err)
+		return
+	}
+
+	setAuthCookies(c, resp)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "login successful",
+		"recovery_codes": resp.RecoveryCodes,
+	})
+}
+
+func (h *Handler) mfaError(c *gin.Context, err error) {
+	if lockedOut(c, err) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, domain.ErrInvalidToken), errors.Is(err, domain.ErrInvalidMFACode), errors.Is(err, domain.ErrInvalidCredentials), errors.Is(err, domain.ErrUserBlocked):
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrMFANotEnrolled), errors.Is(err, domain.ErrMFAAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrMFAEnforced):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/service"
+	"github.com/kcthack-auth/pkg/middleware"
+)
+
+type consentDecisionReq struct {
+	Approve *bool `json:"approve" binding:"required"`
+}
+
+type consentResp struct {
+	ClientID     string    `json:"client_id"`
+	ClientName   string    `json:"client_name"`
+	RedirectHost string    `json:"redirect_host"`
+	Scopes       []string  `json:"scopes"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+type createClientReq struct {
+	Name         string   `json:"name" binding:"required,max=100"`
+	RedirectURIs []string `json:"redirect_uris" binding:"required,min=1,dive,url"`
+	Scopes       []string `json:"scopes"`
+	Public       bool     `json:"public"`
+}
+
+type clientResp struct {
+	ClientID     string    `json:"client_id"`
+	ClientSecret string    `json:"client_secret,omitempty"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Public       bool      `json:"public"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func newClientResp(client *domain.OIDCClient) clientResp {
+	return clientResp{
+		ClientID:     client.ID,
+		ClientSecret: client.Secret,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectURIs,
+		Scopes:       client.Scopes,
+		Public:       client.Public,
+		CreatedAt:    client.CreatedAt,
+	}
+}
+
+// getConsent returns the data of the consent screen for a pending authorization request.
+func (h *Handler) getConsent(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	consent, err := h.services.OIDCService.Consent(c.Request.Context(), claims.UserID, c.Param("id"))
+	if err != nil {
+		h.oidcError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, consentResp{
+		ClientID:     consent.ClientID,
+		ClientName:   consent.ClientName,
+		RedirectHost: consent.RedirectHost,
+		Scopes:       consent.Scopes,
+		ExpiresAt:    consent.ExpiresAt,
+	})
+}
+
+// decideConsent records the decision of the user. The frontend sends the browser to redirect_to.
+func (h *Handler) decideConsent(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	var req consentDecisionReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	redirect, err := h.services.OIDCService.Decide(c.Request.Context(), claims.UserID, c.Param("id"), *req.Approve)
+	if err != nil {
+		h.oidcError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"redirect_to": redirect,
+	})
+}
+
+func (h *Handler) listClients(c *gin.Context) {
+	clients, err := h.services.OIDCService.ListClients(c.Request.Context())
+	if err != nil {
+		h.oidcError(c, err)
+		return
+	}
+
+	resp := make([]clientResp, 0, len(clients))
+	for i := range clients {
+		resp = append(resp, newClientResp(&clients[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"clients": resp,
+	})
+}
+
+// createClient registers a client. The secret is only returned here.
+func (h *Handler) createClient(c *gin.Context) {
+	var req createClientReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	client, err := h.services.OIDCService.CreateClient(c.Request.Context(), service.CreateClientReq{
+		Name:         req.Name,
+		RedirectURIs: req.RedirectURIs,
+		Scopes:       req.Scopes,
+		Public:       req.Public,
+	})
+	if err != nil {
+		h.oidcError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newClientResp(client))
+}
+
+func (h *Handler) deleteClient(c *gin.Context) {
+	if err := h.services.OIDCService.DeleteClient(c.Request.Context(), c.Param("id")); err != nil {
+		h.oidcError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "client deleted",
+	})
+}
+
+func (h *Handler) oidcError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrValidation):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrInvalidToken), errors.Is(err, domain.ErrClientNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+	}
+}
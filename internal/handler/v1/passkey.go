@@ -0,0 +1,214 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/service"
+	"github.com/kcthack-auth/pkg/middleware"
+)
+
+type passkeyFinishReq struct {
+	CeremonyID string          `json:"ceremony_id" binding:"required"`
+	Name       string          `json:"name" binding:"max=64"`
+	Credential json.RawMessage `json:"credential" binding:"required"`
+}
+
+type passkeyCeremonyResp struct {
+	CeremonyID string `json:"ceremony_id"`
+	Options    any    `json:"options"`
+}
+
+type passkeyResp struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func newPasskeyResp(passkey domain.Passkey) passkeyResp {
+	return passkeyResp{
+		ID:         passkey.ID,
+		Name:       passkey.Name,
+		LastUsedAt: passkey.LastUsedAt,
+		CreatedAt:  passkey.CreatedAt,
+	}
+}
+
+func (h *Handler) beginPasskeyRegistration(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	ceremony, err := h.services.PasskeyService.BeginRegistration(c.Request.Context(), claims.UserID)
+	if err != nil {
+		h.passkeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, passkeyCeremonyResp{
+		CeremonyID: ceremony.ID,
+		Options:    ceremony.Options,
+	})
+}
+
+func (h *Handler) finishPasskeyRegistration(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	var req passkeyFinishReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	passkey, err := h.services.PasskeyService.FinishRegistration(c.Request.Context(), claims.UserID, service.FinishPasskeyReq{
+		CeremonyID: req.CeremonyID,
+		Name:       req.Name,
+		Credential: req.Credential,
+	})
+	if err != nil {
+		h.passkeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newPasskeyResp(*passkey))
+}
+
+func (h *Handler) listPasskeys(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	passkeys, err := h.services.PasskeyService.List(c.Request.Context(), claims.UserID)
+	if err != nil {
+		h.passkeyError(c, err)
+		return
+	}
+
+	resp := make([]passkeyResp, 0, len(passkeys))
+	for _, passkey := range passkeys {
+		resp = append(resp, newPasskeyResp(passkey))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"passkeys": resp,
+	})
+}
+
+func (h *Handler) deletePasskey(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	id := c.Param("id")
+	if err := uuid.Validate(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid passkey id",
+		})
+		return
+	}
+
+	if err := h.services.PasskeyService.Delete(c.Request.Context(), claims.UserID, id); err != nil {
+		h.passkeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "passkey deleted",
+	})
+}
+
+func (h *Handler) beginPasskeyLogin(c *gin.Context) {
+	ceremony, err := h.services.PasskeyService.BeginLogin(c.Request.Context())
+	if err != nil {
+		h.passkeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, passkeyCeremonyResp{
+		CeremonyID: ceremony.ID,
+		Options:    ceremony.Options,
+	})
+}
+
+func (h *Handler) finishPasskeyLogin(c *gin.Context) {
+	var req passkeyFinishReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.services.PasskeyService.FinishLogin(c.Request.Context(), service.FinishPasskeyReq{
+		CeremonyID: req.CeremonyID,
+		Credential: req.Credential,
+		Client:     clientInfo(c),
+	})
+	if err != nil {
+		h.passkeyError(c, err)
+		return
+	}
+
+	if resp.MFA != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required":            resp.MFA.Kind == domain.MFAChallengeVerify,
+			"mfa_enrollment_required": resp.MFA.Kind == domain.MFAChallengeEnroll,
+			"mfa_token":               resp.MFA.Token,
+			"expires_at":              resp.MFA.ExpiresAt,
+		})
+		return
+	}
+
+	setAuthCookies(c, resp)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "login successful",
+	})
+}
+
+func (h *Handler) passkeyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidToken), errors.Is(err, domain.ErrPasskeyInvalid), errors.Is(err, domain.ErrPasskeyCloned), errors.Is(err, domain.ErrUserBlocked):
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrLastLoginMethod):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrPasskeyNotFound), errors.Is(err, domain.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/service"
+	"github.com/kcthack-auth/pkg/middleware"
+)
+
+type passwordForgotReq struct {
+	Email string `json:"email" binding:"required,email,max=32"`
+}
+
+type passwordResetReq struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,max=72"`
+}
+
+type passwordChangeReq struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,max=72"`
+}
+
+func (h *Handler) forgotPassword(c *gin.Context) {
+	var req passwordForgotReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	h.services.PasswordService.Forgot(c.Request.Context(), req.Email)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "if the email is registered, a password reset link has been sent",
+	})
+}
+
+func (h *Handler) resetPassword(c *gin.Context) {
+	var req passwordResetReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := h.services.PasswordService.Reset(c.Request.Context(), service.ResetPasswordReq{
+		Token:    req.Token,
+		Password: req.Password,
+	}); err != nil {
+		if errors.Is(err, domain.ErrInvalidToken) || errors.Is(err, domain.ErrValidation) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	clearAuthCookies(c)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "password reset successful",
+	})
+}
+
+func (h *Handler) changePassword(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	refreshToken, err := c.Cookie("refresh_token")
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "refresh token not found in cookies",
+		})
+		return
+	}
+
+	var req passwordChangeReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := h.services.PasswordService.Change(c.Request.Context(), service.ChangePasswordReq{
+		UserID:          claims.UserID,
+		RefreshToken:    refreshToken,
+		CurrentPassword: req.CurrentPassword,
+		NewPassword:     req.NewPassword,
+	}); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrSessionNotFound):
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": err.Error(),
+			})
+		case errors.Is(err, domain.ErrInvalidCredentials), errors.Is(err, domain.ErrSamePassword), errors.Is(err, domain.ErrValidation):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "password changed successfully",
+	})
+}
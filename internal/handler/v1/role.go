@@ -0,0 +1,88 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/pkg/middleware"
+)
+
+type roleAssignReq struct {
+	Role string `json:"role" binding:"required,max=50"`
+}
+
+func (h *Handler) permissions(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"roles":       claims.Roles,
+		"permissions": h.services.RoleService.Permissions(claims.Roles),
+	})
+}
+
+func (h *Handler) listUserRoles(c *gin.Context) {
+	roles, err := h.services.RoleService.ListByUserID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.roleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"roles": roles,
+	})
+}
+
+func (h *Handler) assignUserRole(c *gin.Context) {
+	var req roleAssignReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := h.services.RoleService.Assign(c.Request.Context(), c.Param("id"), req.Role); err != nil {
+		h.roleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "role assigned successfully",
+	})
+}
+
+func (h *Handler) revokeUserRole(c *gin.Context) {
+	if err := h.services.RoleService.Revoke(c.Request.Context(), c.Param("id"), c.Param("role")); err != nil {
+		h.roleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "role revoked successfully",
+	})
+}
+
+func (h *Handler) roleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrUnknownRole), errors.Is(err, domain.ErrPrimaryRole):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/pkg/middleware"
+)
+
+type sessionResp struct {
+	ID         string     `json:"id"`
+	Device     string     `json:"device"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	Current    bool       `json:"current"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (h *Handler) listSessions(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	refreshToken, _ := c.Cookie("refresh_token")
+
+	sessions, currentID, err := h.services.SessionService.List(c.Request.Context(), claims.UserID, refreshToken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	resp := make([]sessionResp, 0, len(sessions))
+	for _, session := range sessions {
+		resp = append(resp, sessionResp{
+			ID:         session.FamilyID,
+			Device:     session.Device,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			Current:    session.FamilyID == currentID,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+			CreatedAt:  session.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": resp,
+	})
+}
+
+func (h *Handler) revokeSession(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	id := c.Param("id")
+	if err := uuid.Validate(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": domain.ErrSessionNotFound.Error(),
+		})
+		return
+	}
+
+	if err := h.services.SessionService.Revoke(c.Request.Context(), claims.UserID, id); err != nil {
+		if errors.Is(err, domain.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "session revoked successfully",
+	})
+}
+
+func (h *Handler) revokeOtherSessions(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	refreshToken, err := c.Cookie("refresh_token")
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "refresh token not found in cookies",
+		})
+		return
+	}
+
+	if err := h.services.SessionService.RevokeOthers(c.Request.Context(), claims.UserID, refreshToken); err != nil {
+		if errors.Is(err, domain.ErrSessionNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "all other sessions revoked successfully",
+	})
+}
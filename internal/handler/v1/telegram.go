@@ -0,0 +1,169 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/pkg/middleware"
+	"github.com/kcthack-auth/pkg/telegram"
+)
+
+// telegramLoginReq is the user object passed by the Telegram Login Widget as is.
+type telegramLoginReq struct {
+	ID        int64  `json:"id" binding:"required"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Username  string `json:"username"`
+	PhotoURL  string `json:"photo_url"`
+	AuthDate  int64  `json:"auth_date" binding:"required"`
+	Hash      string `json:"hash" binding:"required"`
+}
+
+func (r telegramLoginReq) loginData() telegram.LoginData {
+	return telegram.LoginData{
+		ID:        r.ID,
+		FirstName: r.FirstName,
+		LastName:  r.LastName,
+		Username:  r.Username,
+		PhotoURL:  r.PhotoURL,
+		AuthDate:  r.AuthDate,
+		Hash:      r.Hash,
+	}
+}
+
+type telegramAccountResp struct {
+	TelegramID int64     `json:"telegram_id"`
+	Username   string    `json:"username"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func newTelegramAccountResp(account *domain.TelegramAccount) telegramAccountResp {
+	return telegramAccountResp{
+		TelegramID: account.TelegramID,
+		Username:   account.Username,
+		CreatedAt:  account.CreatedAt,
+	}
+}
+
+func (h *Handler) loginTelegram(c *gin.Context) {
+	var req telegramLoginReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.services.TelegramService.Login(c.Request.Context(), req.loginData(), clientInfo(c))
+	if err != nil {
+		h.telegramError(c, err)
+		return
+	}
+
+	if resp.MFA != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required":            resp.MFA.Kind == domain.MFAChallengeVerify,
+			"mfa_enrollment_required": resp.MFA.Kind == domain.MFAChallengeEnroll,
+			"mfa_token":               resp.MFA.Token,
+			"expires_at":              resp.MFA.ExpiresAt,
+		})
+		return
+	}
+
+	setAuthCookies(c, resp)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "login successful",
+	})
+}
+
+func (h *Handler) getTelegram(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	account, err := h.services.TelegramService.Get(c.Request.Context(), claims.UserID)
+	if err != nil {
+		h.telegramError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newTelegramAccountResp(account))
+}
+
+func (h *Handler) linkTelegram(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	var req telegramLoginReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	account, err := h.services.TelegramService.Link(c.Request.Context(), claims.UserID, req.loginData())
+	if err != nil {
+		h.telegramError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newTelegramAccountResp(account))
+}
+
+func (h *Handler) unlinkTelegram(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	if err := h.services.TelegramService.Unlink(c.Request.Context(), claims.UserID); err != nil {
+		h.telegramError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "telegram account unlinked",
+	})
+}
+
+func (h *Handler) telegramError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrTelegramAuth), errors.Is(err, domain.ErrUserBlocked):
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrTelegramLinked), errors.Is(err, domain.ErrLastLoginMethod):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrTelegramNotLinked), errors.Is(err, domain.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, domain.ErrTelegramDisabled):
+		c.JSON(http.StatusNotImplemented, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+	}
+}
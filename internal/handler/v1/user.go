@@ -0,0 +1,143 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/service"
+	"github.com/kcthack-auth/pkg/middleware"
+)
+
+const birthDateLayout = "2006-01-02"
+
+type userProfileResp struct {
+	ID         string    `json:"id"`
+	FirstName  string    `json:"first_name"`
+	LastName   string    `json:"last_name"`
+	Email      string    `json:"email"`
+	Role       string    `json:"role"`
+	TgName     string    `json:"tg_name"`
+	BirthDate  *string   `json:"birth_date"`
+	BIO        string    `json:"bio"`
+	IsVerified bool      `json:"is_verified"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type userUpdateReq struct {
+	FirstName *string `json:"first_name"`
+	LastName  *string `json:"last_name"`
+	TgName    *string `json:"tg_name"`
+	BirthDate *string `json:"birth_date"`
+	BIO       *string `json:"bio"`
+}
+
+func newUserProfileResp(user *domain.User) userProfileResp {
+	resp := userProfileResp{
+		ID:         user.ID,
+		FirstName:  user.FirstName,
+		LastName:   user.LastName,
+		Email:      user.Email,
+		Role:       user.Role,
+		TgName:     user.TgName,
+		BIO:        user.BIO,
+		IsVerified: user.IsVerified,
+		UpdatedAt:  user.UpdatedAt,
+		CreatedAt:  user.CreatedAt,
+	}
+
+	// Users registered without a birth date have the zero date stored.
+	if user.BirthDate.Year() > 1 {
+		birthDate := user.BirthDate.Format(birthDateLayout)
+		resp.BirthDate = &birthDate
+	}
+
+	return resp
+}
+
+func (h *Handler) getMe(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	user, err := h.services.UserService.Get(c.Request.Context(), claims.UserID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserProfileResp(user))
+}
+
+func (h *Handler) updateMe(c *gin.Context) {
+	claims, ok := middleware.Claims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	var req userUpdateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	update := service.UpdateProfileReq{
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		TgName:    req.TgName,
+		BIO:       req.BIO,
+	}
+
+	if req.BirthDate != nil {
+		birthDate, err := time.Parse(birthDateLayout, *req.BirthDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "birth date must be in YYYY-MM-DD format",
+			})
+			return
+		}
+		update.BirthDate = &birthDate
+	}
+
+	user, err := h.services.UserService.UpdateProfile(c.Request.Context(), claims.UserID, update)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrValidation):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+		case errors.Is(err, domain.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserProfileResp(user))
+}
@@ -0,0 +1,61 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kcthack-auth/internal/domain"
+)
+
+type userVerifyReq struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type userVerifyResendReq struct {
+	Email string `json:"email" binding:"required,email,max=32"`
+}
+
+func (h *Handler) verify(c *gin.Context) {
+	var req userVerifyReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := h.services.VerificationService.Verify(c.Request.Context(), req.Token); err != nil {
+		if errors.Is(err, domain.ErrInvalidToken) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "email verified successfully",
+	})
+}
+
+func (h *Handler) resendVerification(c *gin.Context) {
+	var req userVerifyResendReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	h.services.VerificationService.Resend(c.Request.Context(), req.Email)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "if the email is registered and not verified yet, a verification link has been sent",
+	})
+}
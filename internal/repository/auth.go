@@ -4,6 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/kcthack-auth/internal/domain"
 )
@@ -16,20 +19,46 @@ func NewAuthRepo(db *sql.DB) *AuthPSQL {
 	return &AuthPSQL{db: db}
 }
 
+// Create stores the user together with the primary role in one transaction.
 func (a *AuthPSQL) Create(ctx context.Context, user *domain.User) error {
-	query := `INSERT INTO users (id, first_name, last_name, role, email, tg_name, birth_date, bio, pass_hash, is_verified, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	_, err := a.db.ExecContext(ctx, query, user.ID, user.FirstName, user.LastName, user.Role, user.Email, user.TgName, user.BirthDate, user.BIO, user.PassHash, user.IsVerified, user.UpdatedAt)
-	return err
+	if err := createUser(ctx, tx, user); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// createUser inserts the user and assigns the primary role within the transaction of the caller.
+// Accounts created via Telegram have no email, it is stored as NULL so the unique index allows any number of them.
+func createUser(ctx context.Context, db execer, user *domain.User) error {
+	query := `INSERT INTO users (id, first_name, last_name, role, email, tg_name, birth_date, bio, pass_hash, is_verified, updated_at) VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9, $10, $11)`
+
+	if _, err := db.ExecContext(ctx, query, user.ID, user.FirstName, user.LastName, user.Role, user.Email, user.TgName, user.BirthDate, user.BIO, user.PassHash, user.IsVerified, user.UpdatedAt); err != nil {
+		return err
+	}
+
+	query = `INSERT INTO users_roles (user_id, role) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+	if _, err := db.ExecContext(ctx, query, user.ID, user.Role); err != nil {
+		return fmt.Errorf("failed to assign role: %w", err)
+	}
+
+	return nil
 }
 
 func (a *AuthPSQL) FindByEmail(ctx context.Context, email string) (*domain.User, error) {
 	var user domain.User
-	query := `SELECT id, first_name, last_name, role, email, tg_name, birth_date, bio, pass_hash FROM users WHERE email=$1`
+	query := `SELECT id, first_name, last_name, role, COALESCE(email, ''), tg_name, birth_date, bio, pass_hash, is_verified, is_blocked, updated_at, created_at FROM users WHERE email=$1`
 
-	err := a.db.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Role, &user.Email, &user.TgName, &user.BirthDate, &user.BIO, &user.PassHash)
+	err := a.db.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Role, &user.Email, &user.TgName, &user.BirthDate, &user.BIO, &user.PassHash, &user.IsVerified, &user.IsBlocked, &user.UpdatedAt, &user.CreatedAt)
 	if errors.Is(err, sql.ErrNoRows) {
-		return nil, err
+		return nil, domain.ErrUserNotFound
 	}
 
 	if err != nil {
@@ -41,11 +70,11 @@ func (a *AuthPSQL) FindByEmail(ctx context.Context, email string) (*domain.User,
 
 func (a *AuthPSQL) FindByID(ctx context.Context, userID string) (*domain.User, error) {
 	var user domain.User
-	query := `SELECT id, first_name, last_name, role, email, tg_name, birth_date, bio, pass_hash FROM users WHERE id=$1`
+	query := `SELECT id, first_name, last_name, role, COALESCE(email, ''), tg_name, birth_date, bio, pass_hash, is_verified, is_blocked, updated_at, created_at FROM users WHERE id=$1`
 
-	err := a.db.QueryRowContext(ctx, query, userID).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Role, &user.Email, &user.TgName, &user.BirthDate, &user.BIO, &user.PassHash)
+	err := a.db.QueryRowContext(ctx, query, userID).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Role, &user.Email, &user.TgName, &user.BirthDate, &user.BIO, &user.PassHash, &user.IsVerified, &user.IsBlocked, &user.UpdatedAt, &user.CreatedAt)
 	if errors.Is(err, sql.ErrNoRows) {
-		return nil, err
+		return nil, domain.ErrUserNotFound
 	}
 
 	if err != nil {
@@ -62,13 +91,160 @@ func (a *AuthPSQL) UpdatePassword(ctx context.Context, userID string, passHash s
 	return err
 }
 
+// ReplacePasswordHash stores newHash only if the stored hash is still oldHash, so a rehash
+// cannot overwrite a password changed in the meantime. It reports whether the hash was replaced.
+func (a *AuthPSQL) ReplacePasswordHash(ctx context.Context, userID, oldHash, newHash string) (bool, error) {
+	query := `UPDATE users SET pass_hash=$1 WHERE id=$2 AND pass_hash=$3`
+
+	res, err := a.db.ExecContext(ctx, query, newHash, userID, oldHash)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// Update writes the profile fields of the user. The email and the verification are left alone,
+// so a MarkVerified between reading the user and this write is not reverted.
 func (a *AuthPSQL) Update(ctx context.Context, user *domain.User) error {
-	query := `UPDATE users SET first_name=$1, last_name=$2, email=$3, tg_name=$4, birth_date=$5, bio=$6, is_verified=$7, updated_at=$8 WHERE id=$9`
+	query := `UPDATE users SET first_name=$1, last_name=$2, tg_name=$3, birth_date=$4, bio=$5, updated_at=$6 WHERE id=$7`
+
+	_, err := a.db.ExecContext(ctx, query, user.FirstName, user.LastName, user.TgName, user.BirthDate, user.BIO, user.UpdatedAt, user.ID)
+	return err
+}
+
+func (a *AuthPSQL) MarkVerified(ctx context.Context, userID string) error {
+	query := `UPDATE users SET is_verified=true, updated_at=$1 WHERE id=$2`
+
+	_, err := a.db.ExecContext(ctx, query, time.Now(), userID)
+	return err
+}
+
+// ChangeRole replaces the primary role of the user in one transaction: the new role is assigned,
+// the previous one revoked and users.role updated. Additional roles are kept.
+func (a *AuthPSQL) ChangeRole(ctx context.Context, userID, role string) error {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var previous string
+	err = tx.QueryRowContext(ctx, `SELECT role FROM users WHERE id=$1 FOR UPDATE`, userID).Scan(&previous)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.ErrUserNotFound
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO users_roles (user_id, role) VALUES ($1, $2) ON CONFLICT DO NOTHING`, userID, role); err != nil {
+		return fmt.Errorf("failed to assign role: %w", err)
+	}
+
+	if previous != role {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM users_roles WHERE user_id=$1 AND role=$2`, userID, previous); err != nil {
+			return fmt.Errorf("failed to revoke previous role: %w", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET role=$1, updated_at=$2 WHERE id=$3`, role, time.Now(), userID); err != nil {
+		return fmt.Errorf("failed to update user role: %w", err)
+	}
+
+	return tx.Commit()
+}
 
-	_, err := a.db.ExecContext(ctx, query, user.FirstName, user.LastName, user.Email, user.TgName, user.BirthDate, user.BIO, user.IsVerified, user.UpdatedAt, user.ID)
+func (a *AuthPSQL) SetBlocked(ctx context.Context, userID string, blocked bool) error {
+	query := `UPDATE users SET is_blocked=$1, updated_at=$2 WHERE id=$3`
+
+	_, err := a.db.ExecContext(ctx, query, blocked, time.Now(), userID)
 	return err
 }
 
+// List returns a page of users matching the filter and the total number of matches.
+func (a *AuthPSQL) List(ctx context.Context, filter domain.UserFilter) ([]domain.User, int, error) {
+	var (
+		conds []string
+		args  []any
+	)
+
+	if filter.Role != "" {
+		args = append(args, filter.Role)
+		conds = append(conds, fmt.Sprintf("(role=$%d OR EXISTS(SELECT 1 FROM users_roles ur WHERE ur.user_id=users.id AND ur.role=$%d))", len(args), len(args)))
+	}
+
+	if filter.IsVerified != nil {
+		args = append(args, *filter.IsVerified)
+		conds = append(conds, fmt.Sprintf("is_verified=$%d", len(args)))
+	}
+
+	if filter.IsBlocked != nil {
+		args = append(args, *filter.IsBlocked)
+		conds = append(conds, fmt.Sprintf("is_blocked=$%d", len(args)))
+	}
+
+	if filter.Search != "" {
+		args = append(args, "%"+escapeLike(filter.Search)+"%")
+		conds = append(conds, fmt.Sprintf("(email ILIKE $%d OR first_name ILIKE $%d OR last_name ILIKE $%d OR (first_name || ' ' || last_name) ILIKE $%d)", len(args), len(args), len(args), len(args)))
+	}
+
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int
+	if err := a.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query := `SELECT id, first_name, last_name, role, COALESCE(email, ''), tg_name, birth_date, bio, pass_hash, is_verified, is_blocked, updated_at, created_at FROM users` + where +
+		fmt.Sprintf(" ORDER BY created_at DESC, id LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := a.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var users []domain.User
+	for rows.Next() {
+		var user domain.User
+		if err := rows.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Role, &user.Email, &user.TgName, &user.BirthDate, &user.BIO, &user.PassHash, &user.IsVerified, &user.IsBlocked, &user.UpdatedAt, &user.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+
+	return users, total, rows.Err()
+}
+
+// CountLoginMethods returns how many ways the user has to log in: a password, passkeys,
+// a linked Telegram account and external identities.
+func (a *AuthPSQL) CountLoginMethods(ctx context.Context, userID string) (int, error) {
+	var count int
+	query := `SELECT (CASE WHEN pass_hash <> '' THEN 1 ELSE 0 END)
+	+ (SELECT COUNT(*) FROM users_passkeys WHERE user_id=users.id)
+	+ (SELECT COUNT(*) FROM users_telegram WHERE user_id=users.id)
+	+ (SELECT COUNT(*) FROM user_identities WHERE user_id=users.id)
+FROM users WHERE id=$1`
+
+	err := a.db.QueryRowContext(ctx, query, userID).Scan(&count)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, domain.ErrUserNotFound
+	}
+
+	return count, err
+}
+
 func (a *AuthPSQL) ExistsByEmail(ctx context.Context, email string) (bool, error) {
 	var exists bool
 	query := `SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)`
@@ -80,3 +256,7 @@ func (a *AuthPSQL) ExistsByEmail(ctx context.Context, email string) (bool, error
 
 	return exists, nil
 }
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/kcthack-auth/pkg/logger"
+)
+
+// DenylistRepo stores the ids of revoked access tokens until the tokens expire.
+type DenylistRepo struct {
+	db *sql.DB
+}
+
+func NewDenylistRepo(db *sql.DB) *DenylistRepo {
+	return &DenylistRepo{db: db}
+}
+
+// Add denies the token until expiresAt. Entries of tokens that expired by now are dropped,
+// a failure to drop them is only logged.
+func (d *DenylistRepo) Add(ctx context.Context, jti string, expiresAt time.Time) error {
+	query := `INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING`
+
+	if _, err := d.db.ExecContext(ctx, query, jti, expiresAt); err != nil {
+		return err
+	}
+
+	// The token is denied already, a failed cleanup must not fail the logout.
+	if _, err := d.db.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at < $1`, time.Now()); err != nil {
+		slog.WarnContext(ctx, "failed to delete expired revoked tokens", logger.Err(err))
+	}
+
+	return nil
+}
+
+func (d *DenylistRepo) Contains(ctx context.Context, jti string) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM revoked_tokens WHERE jti=$1)`
+
+	err := d.db.QueryRowContext(ctx, query, jti).Scan(&exists)
+	return exists, err
+}
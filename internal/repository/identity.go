@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/kcthack-auth/internal/domain"
+)
+
+// execer is implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+type IdentityRepo struct {
+	db *sql.DB
+}
+
+func NewIdentityRepo(db *sql.DB) *IdentityRepo {
+	return &IdentityRepo{db: db}
+}
+
+func (i *IdentityRepo) FindBySubject(ctx context.Context, provider, subject string) (*domain.Identity, error) {
+	var identity domain.Identity
+	query := `SELECT id, user_id, provider, subject, COALESCE(email, ''), created_at FROM user_identities WHERE provider=$1 AND subject=$2`
+
+	err := i.db.QueryRowContext(ctx, query, provider, subject).Scan(&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject, &identity.Email, &identity.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrIdentityNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+func (i *IdentityRepo) ListByUserID(ctx context.Context, userID string) ([]domain.Identity, error) {
+	query := `SELECT id, user_id, provider, subject, COALESCE(email, ''), created_at FROM user_identities WHERE user_id=$1 ORDER BY created_at`
+
+	rows, err := i.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var identities []domain.Identity
+	for rows.Next() {
+		var identity domain.Identity
+		if err := rows.Scan(&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject, &identity.Email, &identity.CreatedAt); err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+
+	return identities, rows.Err()
+}
+
+// Link stores the identity. It fails with ErrIdentityLinked if the external account is linked
+// to any user or the user already has an identity at the provider.
+func (i *IdentityRepo) Link(ctx context.Context, identity *domain.Identity) error {
+	return i.link(ctx, i.db, identity)
+}
+
+// CreateUser creates a user with the primary role together with the identity in one transaction.
+func (i *IdentityRepo) CreateUser(ctx context.Context, user *domain.User, identity *domain.Identity) error {
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createUser(ctx, tx, user); err != nil {
+		return err
+	}
+
+	if err := i.link(ctx, tx, identity); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (i *IdentityRepo) link(ctx context.Context, db execer, identity *domain.Identity) error {
+	query := `INSERT INTO user_identities (id, user_id, provider, subject, email) VALUES ($1, $2, $3, $4, NULLIF($5, '')) ON CONFLICT DO NOTHING`
+
+	res, err := db.ExecContext(ctx, query, identity.ID, identity.UserID, identity.Provider, identity.Subject, identity.Email)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return domain.ErrIdentityLinked
+	}
+
+	return nil
+}
+
+func (i *IdentityRepo) Unlink(ctx context.Context, userID, provider string) error {
+	query := `DELETE FROM user_identities WHERE user_id=$1 AND provider=$2`
+
+	res, err := i.db.ExecContext(ctx, query, userID, provider)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return domain.ErrIdentityNotFound
+	}
+
+	return nil
+}
+
+func (i *IdentityRepo) SaveState(ctx context.Context, state *domain.OAuthState) error {
+	query := `INSERT INTO oauth_states (id, token_hash, provider, user_id, verifier, expires_at) VALUES ($1, $2, $3, $4, $5, $6)`
+
+	var userID *string
+	if state.UserID != "" {
+		userID = &state.UserID
+	}
+
+	_, err := i.db.ExecContext(ctx, query, state.ID, hashToken(state.Token), state.Provider, userID, state.Verifier, state.ExpiresAt)
+	return err
+}
+
+// ConsumeState deletes the state and returns it, so every authorization response is accepted only once.
+func (i *IdentityRepo) ConsumeState(ctx context.Context, token, provider string) (*domain.OAuthState, error) {
+	var (
+		state  domain.OAuthState
+		userID sql.NullString
+	)
+	query := `DELETE FROM oauth_states WHERE token_hash=$1 AND provider=$2 RETURNING id, provider, user_id, verifier, expires_at`
+
+	err := i.db.QueryRowContext(ctx, query, hashToken(token), provider).Scan(&state.ID, &state.Provider, &userID, &state.Verifier, &state.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrInvalidToken
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if state.ExpiresAt.Before(time.Now()) {
+		return nil, domain.ErrInvalidToken
+	}
+
+	state.UserID = userID.String
+
+	return &state, nil
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kcthack-auth/internal/domain"
+)
+
+// sealedPrefix marks encrypted key material. Rows stored before encryption was introduced have none.
+const sealedPrefix = "enc:v1:"
+
+// KeyRepo stores the key material encrypted with aead, whose key is never kept in the database.
+// The id of the key is authenticated along with the material, so rows cannot be swapped.
+type KeyRepo struct {
+	db   *sql.DB
+	aead cipher.AEAD
+}
+
+func NewKeyRepo(db *sql.DB, aead cipher.AEAD) *KeyRepo {
+	return &KeyRepo{db: db, aead: aead}
+}
+
+// ListValid returns keys that are not retired yet, oldest first.
+func (k *KeyRepo) ListValid(ctx context.Context) ([]domain.SigningKey, error) {
+	query := `SELECT id, algorithm, material, activates_at, retires_at, created_at FROM jwt_keys WHERE retires_at IS NULL OR retires_at > $1 ORDER BY activates_at`
+
+	rows, err := k.db.QueryContext(ctx, query, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []domain.SigningKey
+	for rows.Next() {
+		var (
+			key      domain.SigningKey
+			material string
+		)
+		if err := rows.Scan(&key.ID, &key.Algorithm, &material, &key.ActivatesAt, &key.RetiresAt, &key.CreatedAt); err != nil {
+			return nil, err
+		}
+		if key.Material, err = k.open(key.ID, material); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// Create stores the key unless a key with the same id already exists.
+func (k *KeyRepo) Create(ctx context.Context, key *domain.SigningKey) error {
+	query := `INSERT INTO jwt_keys (id, algorithm, material, activates_at, retires_at) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (id) DO NOTHING`
+
+	material, err := k.seal(key.ID, key.Material)
+	if err != nil {
+		return err
+	}
+
+	_, err = k.db.ExecContext(ctx, query, key.ID, key.Algorithm, material, key.ActivatesAt, key.RetiresAt)
+	return err
+}
+
+// Rotate stores the new key and schedules retirement of every key that has no retirement date yet.
+func (k *KeyRepo) Rotate(ctx context.Context, key *domain.SigningKey, retireAt time.Time) error {
+	material, err := k.seal(key.ID, key.Material)
+	if err != nil {
+		return err
+	}
+
+	tx, err := k.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE jwt_keys SET retires_at=$1 WHERE retires_at IS NULL`, retireAt); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO jwt_keys (id, algorithm, material, activates_at, retires_at) VALUES ($1, $2, $3, $4, $5)`
+	if _, err := tx.ExecContext(ctx, query, key.ID, key.Algorithm, material, key.ActivatesAt, key.RetiresAt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SealPlaintext encrypts the material of rows stored in plaintext and returns how many there were.
+func (k *KeyRepo) SealPlaintext(ctx context.Context) (int, error) {
+	tx, err := k.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, material FROM jwt_keys WHERE material NOT LIKE $1 FOR UPDATE`, sealedPrefix+"%")
+	if err != nil {
+		return 0, err
+	}
+
+	plaintext := make(map[string]string)
+	for rows.Next() {
+		var id, material string
+		if err := rows.Scan(&id, &material); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		plaintext[id] = material
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for id, material := range plaintext {
+		sealed, err := k.seal(id, []byte(material))
+		if err != nil {
+			return 0, err
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE jwt_keys SET material=$1 WHERE id=$2`, sealed, id); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(plaintext), tx.Commit()
+}
+
+func (k *KeyRepo) seal(id string, material []byte) (string, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := k.aead.Seal(nonce, nonce, material, []byte(id))
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts the material. Plaintext material is returned as is until SealPlaintext encrypts it.
+func (k *KeyRepo) open(id, material string) ([]byte, error) {
+	encoded, ok := strings.CutPrefix(material, sealedPrefix)
+	if !ok {
+		return []byte(material), nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < k.aead.NonceSize() {
+		return nil, fmt.Errorf("invalid material of signing key %s", id)
+	}
+
+	nonce, ciphertext := sealed[:k.aead.NonceSize()], sealed[k.aead.NonceSize():]
+	plaintext, err := k.aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt signing key %s: %w", id, err)
+	}
+
+	return plaintext, nil
+}
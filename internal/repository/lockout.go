@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/kcthack-auth/internal/domain"
+)
+
+// LoginAttemptRepo keeps the counters in Postgres, so they are shared by every replica.
+type LoginAttemptRepo struct {
+	db *sql.DB
+}
+
+func NewLoginAttemptRepo(db *sql.DB) *LoginAttemptRepo {
+	return &LoginAttemptRepo{db: db}
+}
+
+// Update runs fn on the attempts of the key with its row locked, so concurrent logins are counted
+// one after another. Keys without attempts start from zero.
+func (l *LoginAttemptRepo) Update(ctx context.Context, key string, fn func(attempts *domain.LoginAttempts)) error {
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The row is created first, so the row lock below always has a row to hold.
+	if _, err := tx.ExecContext(ctx, `INSERT INTO login_attempts (key, last_attempt_at) VALUES ($1, $2) ON CONFLICT (key) DO NOTHING`, key, time.Time{}); err != nil {
+		return err
+	}
+
+	attempts := domain.LoginAttempts{Key: key}
+	query := `SELECT attempts, last_attempt_at, locked_until FROM login_attempts WHERE key=$1 FOR UPDATE`
+	if err := tx.QueryRowContext(ctx, query, key).Scan(&attempts.Attempts, &attempts.LastAttemptAt, &attempts.LockedUntil); err != nil {
+		return err
+	}
+
+	fn(&attempts)
+
+	query = `UPDATE login_attempts SET attempts=$1, last_attempt_at=$2, locked_until=$3 WHERE key=$4`
+	if _, err := tx.ExecContext(ctx, query, attempts.Attempts, attempts.LastAttemptAt, attempts.LockedUntil, key); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (l *LoginAttemptRepo) Reset(ctx context.Context, key string) error {
+	query := `DELETE FROM login_attempts WHERE key=$1`
+
+	_, err := l.db.ExecContext(ctx, query, key)
+	return err
+}
+
+func (l *LoginAttemptRepo) DeleteExpired(ctx context.Context, before time.Time) error {
+	query := `DELETE FROM login_attempts WHERE last_attempt_at < $1 AND (locked_until IS NULL OR locked_until < $2)`
+
+	_, err := l.db.ExecContext(ctx, query, before, time.Now())
+	return err
+}
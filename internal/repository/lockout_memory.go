@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kcthack-auth/internal/domain"
+)
+
+// MemoryLoginAttemptRepo keeps the counters in process memory. It is meant for tests and
+// single instance setups, replicas do not see each other's counters.
+type MemoryLoginAttemptRepo struct {
+	mu       sync.Mutex
+	attempts map[string]domain.LoginAttempts
+}
+
+func NewMemoryLoginAttemptRepo() *MemoryLoginAttemptRepo {
+	return &MemoryLoginAttemptRepo{attempts: make(map[string]domain.LoginAttempts)}
+}
+
+// Update runs fn on the attempts of the key while holding the lock of the repository.
+func (m *MemoryLoginAttemptRepo) Update(ctx context.Context, key string, fn func(attempts *domain.LoginAttempts)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempts, ok := m.attempts[key]
+	if !ok {
+		attempts = domain.LoginAttempts{Key: key}
+	}
+
+	fn(&attempts)
+	m.attempts[key] = attempts
+
+	return nil
+}
+
+func (m *MemoryLoginAttemptRepo) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.attempts, key)
+
+	return nil
+}
+
+func (m *MemoryLoginAttemptRepo) DeleteExpired(ctx context.Context, before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for key, attempts := range m.attempts {
+		if attempts.LastAttemptAt.Before(before) && (attempts.LockedUntil == nil || attempts.LockedUntil.Before(now)) {
+			delete(m.attempts, key)
+		}
+	}
+
+	return nil
+}
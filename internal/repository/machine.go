@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/kcthack-auth/internal/domain"
+)
+
+type MachineClientRepo struct {
+	db *sql.DB
+}
+
+func NewMachineClientRepo(db *sql.DB) *MachineClientRepo {
+	return &MachineClientRepo{db: db}
+}
+
+// Create stores the client with its first secret. Scopes are stored space separated.
+func (m *MachineClientRepo) Create(ctx context.Context, client *domain.MachineClient, secret *domain.MachineClientSecret) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO machine_clients (id, name, scopes) VALUES ($1, $2, $3)`
+
+	if _, err := tx.ExecContext(ctx, query, client.ID, client.Name, strings.Join(client.Scopes, " ")); err != nil {
+		return err
+	}
+
+	if err := m.insertSecret(ctx, tx, secret); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *MachineClientRepo) Find(ctx context.Context, id string) (*domain.MachineClient, error) {
+	var (
+		client domain.MachineClient
+		scopes string
+	)
+	query := `SELECT id, name, scopes, revoked_at, created_at FROM machine_clients WHERE id=$1`
+
+	err := m.db.QueryRowContext(ctx, query, id).Scan(&client.ID, &client.Name, &scopes, &client.RevokedAt, &client.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrClientNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	client.Scopes = strings.Fields(scopes)
+
+	return &client, nil
+}
+
+func (m *MachineClientRepo) List(ctx context.Context) ([]domain.MachineClient, error) {
+	query := `SELECT id, name, scopes, revoked_at, created_at FROM machine_clients ORDER BY created_at`
+
+	rows, err := m.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clients []domain.MachineClient
+	for rows.Next() {
+		var (
+			client domain.MachineClient
+			scopes string
+		)
+		if err := rows.Scan(&client.ID, &client.Name, &scopes, &client.RevokedAt, &client.CreatedAt); err != nil {
+			return nil, err
+		}
+		client.Scopes = strings.Fields(scopes)
+		clients = append(clients, client)
+	}
+
+	return clients, rows.Err()
+}
+
+// ListSecrets returns the secrets of the client that are still valid, without the secret values.
+func (m *MachineClientRepo) ListSecrets(ctx context.Context, clientID string) ([]domain.MachineClientSecret, error) {
+	query := `SELECT id, client_id, expires_at, created_at FROM machine_client_secrets
+WHERE client_id=$1 AND (expires_at IS NULL OR expires_at > $2) ORDER BY created_at`
+
+	rows, err := m.db.QueryContext(ctx, query, clientID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var secrets []domain.MachineClientSecret
+	for rows.Next() {
+		var secret domain.MachineClientSecret
+		if err := rows.Scan(&secret.ID, &secret.ClientID, &secret.ExpiresAt, &secret.CreatedAt); err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, secret)
+	}
+
+	return secrets, rows.Err()
+}
+
+// RotateSecret adds a new secret. The current secrets stay valid until oldExpireAt, unless they expire earlier anyway.
+func (m *MachineClientRepo) RotateSecret(ctx context.Context, secret *domain.MachineClientSecret, oldExpireAt time.Time) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE machine_client_secrets SET expires_at=LEAST(COALESCE(expires_at, $1), $1) WHERE client_id=$2`
+
+	if _, err := tx.ExecContext(ctx, query, oldExpireAt, secret.ClientID); err != nil {
+		return err
+	}
+
+	if err := m.insertSecret(ctx, tx, secret); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Revoke disables the client and deletes all of its secrets. Revoked clients are reported as not found.
+func (m *MachineClientRepo) Revoke(ctx context.Context, id string) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE machine_clients SET revoked_at=$1 WHERE id=$2 AND revoked_at IS NULL`
+
+	res, err := tx.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return domain.ErrClientNotFound
+	}
+
+	query = `DELETE FROM machine_client_secrets WHERE client_id=$1`
+
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SecretMatches reports whether secret is a valid secret of an active client.
+func (m *MachineClientRepo) SecretMatches(ctx context.Context, clientID, secret string) (bool, error) {
+	var matches bool
+	query := `SELECT EXISTS(SELECT 1 FROM machine_client_secrets s JOIN machine_clients c ON c.id=s.client_id
+WHERE s.client_id=$1 AND s.secret_hash=$2 AND (s.expires_at IS NULL OR s.expires_at > $3) AND c.revoked_at IS NULL)`
+
+	err := m.db.QueryRowContext(ctx, query, clientID, hashToken(secret), time.Now()).Scan(&matches)
+	return matches, err
+}
+
+func (m *MachineClientRepo) insertSecret(ctx context.Context, db execer, secret *domain.MachineClientSecret) error {
+	query := `INSERT INTO machine_client_secrets (id, client_id, secret_hash, expires_at) VALUES ($1, $2, $3, $4)`
+
+	_, err := db.ExecContext(ctx, query, secret.ID, secret.ClientID, hashToken(secret.Secret), secret.ExpiresAt)
+	return err
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kcthack-auth/internal/domain"
+)
+
+type MFARepo struct {
+	db *sql.DB
+}
+
+func NewMFARepo(db *sql.DB) *MFARepo {
+	return &MFARepo{db: db}
+}
+
+func (m *MFARepo) GetTOTP(ctx context.Context, userID string) (*domain.TOTP, error) {
+	var totp domain.TOTP
+	query := `SELECT user_id, secret, last_step, confirmed_at, created_at FROM users_totp WHERE user_id=$1`
+
+	err := m.db.QueryRowContext(ctx, query, userID).Scan(&totp.UserID, &totp.Secret, &totp.LastStep, &totp.ConfirmedAt, &totp.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrMFANotEnrolled
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &totp, nil
+}
+
+// SaveTOTP stores a new unconfirmed secret, replacing a previous unconfirmed one.
+// A confirmed secret is never replaced.
+func (m *MFARepo) SaveTOTP(ctx context.Context, totp *domain.TOTP) error {
+	query := `INSERT INTO users_totp (user_id, secret) VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE SET secret=EXCLUDED.secret, last_step=0, created_at=NOW() WHERE users_totp.confirmed_at IS NULL`
+
+	res, err := m.db.ExecContext(ctx, query, totp.UserID, totp.Secret)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return domain.ErrMFAAlreadyEnabled
+	}
+
+	return nil
+}
+
+func (m *MFARepo) ConfirmTOTP(ctx context.Context, userID string, step int64) error {
+	query := `UPDATE users_totp SET confirmed_at=$1, last_step=$2 WHERE user_id=$3 AND confirmed_at IS NULL`
+
+	_, err := m.db.ExecContext(ctx, query, time.Now(), step, userID)
+	return err
+}
+
+// UseStep records that the code of the step was used. It returns false if the step
+// or a later one was already used, so every code is accepted only once.
+func (m *MFARepo) UseStep(ctx context.Context, userID string, step int64) (bool, error) {
+	query := `UPDATE users_totp SET last_step=$1 WHERE user_id=$2 AND last_step < $1`
+
+	res, err := m.db.ExecContext(ctx, query, step, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+func (m *MFARepo) DeleteTOTP(ctx context.Context, userID string) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM users_recovery_codes WHERE user_id=$1`, userID); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM users_totp WHERE user_id=$1`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes deletes all recovery codes of the user and stores the new ones.
+func (m *MFARepo) ReplaceRecoveryCodes(ctx context.Context, userID string, codes []string) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM users_recovery_codes WHERE user_id=$1`, userID); err != nil {
+		return err
+	}
+
+	for _, code := range codes {
+		query := `INSERT INTO users_recovery_codes (id, user_id, code_hash) VALUES ($1, $2, $3)`
+		if _, err := tx.ExecContext(ctx, query, uuid.NewString(), userID, hashToken(code)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m *MFARepo) ConsumeRecoveryCode(ctx context.Context, userID, code string) error {
+	query := `UPDATE users_recovery_codes SET used_at=$1 WHERE user_id=$2 AND code_hash=$3 AND used_at IS NULL`
+
+	res, err := m.db.ExecContext(ctx, query, time.Now(), userID, hashToken(code))
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return domain.ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+func (m *MFARepo) CountRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM users_recovery_codes WHERE user_id=$1 AND used_at IS NULL`
+
+	err := m.db.QueryRowContext(ctx, query, userID).Scan(&count)
+	return count, err
+}
+
+func (m *MFARepo) SaveChallenge(ctx context.Context, challenge *domain.MFAChallenge) error {
+	query := `INSERT INTO users_mfa_challenges (id, user_id, token_hash, kind, expires_at) VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := m.db.ExecContext(ctx, query, challenge.ID, challenge.UserID, hashToken(challenge.Token), challenge.Kind, challenge.ExpiresAt)
+	return err
+}
+
+// FindChallenge returns an unexpired challenge and counts the attempt to use it.
+func (m *MFARepo) FindChallenge(ctx context.Context, token string) (*domain.MFAChallenge, error) {
+	var challenge domain.MFAChallenge
+	query := `UPDATE users_mfa_challenges SET attempts=attempts+1 WHERE token_hash=$1 AND expires_at > $2
+RETURNING id, user_id, kind, attempts, expires_at, created_at`
+
+	err := m.db.QueryRowContext(ctx, query, hashToken(token), time.Now()).Scan(&challenge.ID, &challenge.UserID, &challenge.Kind, &challenge.Attempts, &challenge.ExpiresAt, &challenge.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrInvalidToken
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &challenge, nil
+}
+
+func (m *MFARepo) DeleteChallenge(ctx context.Context, id string) error {
+	query := `DELETE FROM users_mfa_challenges WHERE id=$1`
+
+	_, err := m.db.ExecContext(ctx, query, id)
+	return err
+}
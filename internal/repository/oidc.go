@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/kcthack-auth/internal/domain"
+)
+
+type OIDCRepo struct {
+	db *sql.DB
+}
+
+func NewOIDCRepo(db *sql.DB) *OIDCRepo {
+	return &OIDCRepo{db: db}
+}
+
+// CreateClient stores the client. Redirect uris and scopes cannot contain spaces, so both lists are stored space separated.
+func (o *OIDCRepo) CreateClient(ctx context.Context, client *domain.OIDCClient) error {
+	query := `INSERT INTO oidc_clients (id, name, secret_hash, redirect_uris, scopes, public) VALUES ($1, $2, $3, $4, $5, $6)`
+
+	var secretHash *string
+	if client.Secret != "" {
+		hash := hashToken(client.Secret)
+		secretHash = &hash
+	}
+
+	_, err := o.db.ExecContext(ctx, query, client.ID, client.Name, secretHash, strings.Join(client.RedirectURIs, " "), strings.Join(client.Scopes, " "), client.Public)
+	return err
+}
+
+// ClientSecretMatches reports whether secret is the secret of the client.
+func (o *OIDCRepo) ClientSecretMatches(ctx context.Context, id, secret string) (bool, error) {
+	var matches bool
+	query := `SELECT COALESCE(secret_hash=$2, false) FROM oidc_clients WHERE id=$1`
+
+	err := o.db.QueryRowContext(ctx, query, id, hashToken(secret)).Scan(&matches)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, domain.ErrClientNotFound
+	}
+
+	return matches, err
+}
+
+func (o *OIDCRepo) FindClient(ctx context.Context, id string) (*domain.OIDCClient, error) {
+	var (
+		client       domain.OIDCClient
+		redirectURIs string
+		scopes       string
+	)
+	query := `SELECT id, name, redirect_uris, scopes, public, created_at FROM oidc_clients WHERE id=$1`
+
+	err := o.db.QueryRowContext(ctx, query, id).Scan(&client.ID, &client.Name, &redirectURIs, &scopes, &client.Public, &client.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrClientNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	client.RedirectURIs = strings.Fields(redirectURIs)
+	client.Scopes = strings.Fields(scopes)
+
+	return &client, nil
+}
+
+func (o *OIDCRepo) ListClients(ctx context.Context) ([]domain.OIDCClient, error) {
+	query := `SELECT id, name, redirect_uris, scopes, public, created_at FROM oidc_clients ORDER BY created_at`
+
+	rows, err := o.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clients []domain.OIDCClient
+	for rows.Next() {
+		var (
+			client       domain.OIDCClient
+			redirectURIs string
+			scopes       string
+		)
+		if err := rows.Scan(&client.ID, &client.Name, &redirectURIs, &scopes, &client.Public, &client.CreatedAt); err != nil {
+			return nil, err
+		}
+		client.RedirectURIs = strings.Fields(redirectURIs)
+		client.Scopes = strings.Fields(scopes)
+		clients = append(clients, client)
+	}
+
+	return clients, rows.Err()
+}
+
+func (o *OIDCRepo) DeleteClient(ctx context.Context, id string) error {
+	query := `DELETE FROM oidc_clients WHERE id=$1`
+
+	res, err := o.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return domain.ErrClientNotFound
+	}
+
+	return nil
+}
+
+// SaveAuthorization stores the request. If Code is set the code hash is stored with it.
+func (o *OIDCRepo) SaveAuthorization(ctx context.Context, authz *domain.OIDCAuthorization) error {
+	query := `INSERT INTO oidc_authorizations (id, client_id, user_id, redirect_uri, scope, state, nonce, code_challenge, code_hash, auth_time, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+
+	var codeHash *string
+	if authz.Code != "" {
+		hash := hashToken(authz.Code)
+		codeHash = &hash
+	}
+
+	_, err := o.db.ExecContext(ctx, query, authz.ID, authz.ClientID, authz.UserID, authz.RedirectURI, authz.Scope, authz.State, authz.Nonce, authz.CodeChallenge, codeHash, authz.AuthTime, authz.ExpiresAt)
+	return err
+}
+
+// FindPendingAuthorization returns an unexpired request of the user that still waits for consent.
+func (o *OIDCRepo) FindPendingAuthorization(ctx context.Context, id, userID string) (*domain.OIDCAuthorization, error) {
+	var authz domain.OIDCAuthorization
+	query := `SELECT id, client_id, user_id, redirect_uri, scope, state, nonce, code_challenge, auth_time, expires_at FROM oidc_authorizations
+WHERE id=$1 AND user_id=$2 AND code_hash IS NULL AND expires_at > $3`
+
+	err := o.db.QueryRowContext(ctx, query, id, userID, time.Now()).Scan(&authz.ID, &authz.ClientID, &authz.UserID, &authz.RedirectURI, &authz.Scope, &authz.State, &authz.Nonce, &authz.CodeChallenge, &authz.AuthTime, &authz.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrInvalidToken
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &authz, nil
+}
+
+// SetCode attaches a code to a pending request once the user consented.
+func (o *OIDCRepo) SetCode(ctx context.Context, id, code string, expiresAt time.Time) error {
+	query := `UPDATE oidc_authorizations SET code_hash=$1, expires_at=$2 WHERE id=$3 AND code_hash IS NULL`
+
+	res, err := o.db.ExecContext(ctx, query, hashToken(code), expiresAt, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return domain.ErrInvalidToken
+	}
+
+	return nil
+}
+
+// ConsumeCode deletes the authorization of the code and returns it, so every code can be redeemed only once.
+func (o *OIDCRepo) ConsumeCode(ctx context.Context, code string) (*domain.OIDCAuthorization, error) {
+	var authz domain.OIDCAuthorization
+	query := `DELETE FROM oidc_authorizations WHERE code_hash=$1
+RETURNING id, client_id, user_id, redirect_uri, scope, state, nonce, code_challenge, auth_time, expires_at`
+
+	err := o.db.QueryRowContext(ctx, query, hashToken(code)).Scan(&authz.ID, &authz.ClientID, &authz.UserID, &authz.RedirectURI, &authz.Scope, &authz.State, &authz.Nonce, &authz.CodeChallenge, &authz.AuthTime, &authz.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrInvalidToken
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if authz.ExpiresAt.Before(time.Now()) {
+		return nil, domain.ErrInvalidToken
+	}
+
+	return &authz, nil
+}
+
+func (o *OIDCRepo) DeleteAuthorization(ctx context.Context, id string) error {
+	query := `DELETE FROM oidc_authorizations WHERE id=$1`
+
+	_, err := o.db.ExecContext(ctx, query, id)
+	return err
+}
+
+func (o *OIDCRepo) FindConsent(ctx context.Context, userID, clientID string) (*domain.OIDCConsent, error) {
+	var consent domain.OIDCConsent
+	query := `SELECT user_id, client_id, scope, created_at FROM oidc_consents WHERE user_id=$1 AND client_id=$2`
+
+	err := o.db.QueryRowContext(ctx, query, userID, clientID).Scan(&consent.UserID, &consent.ClientID, &consent.Scope, &consent.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrConsentNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &consent, nil
+}
+
+// SaveConsent stores the granted scopes, replacing a previous consent of the user for the client.
+func (o *OIDCRepo) SaveConsent(ctx context.Context, consent *domain.OIDCConsent) error {
+	query := `INSERT INTO oidc_consents (user_id, client_id, scope, created_at) VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id, client_id) DO UPDATE SET scope=EXCLUDED.scope, created_at=EXCLUDED.created_at`
+
+	_, err := o.db.ExecContext(ctx, query, consent.UserID, consent.ClientID, consent.Scope, consent.CreatedAt)
+	return err
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/kcthack-auth/internal/domain"
+)
+
+type PasskeyRepo struct {
+	db *sql.DB
+}
+
+func NewPasskeyRepo(db *sql.DB) *PasskeyRepo {
+	return &PasskeyRepo{db: db}
+}
+
+func (p *PasskeyRepo) Create(ctx context.Context, passkey *domain.Passkey) error {
+	query := `INSERT INTO users_passkeys (id, user_id, credential_id, name, credential, sign_count) VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := p.db.ExecContext(ctx, query, passkey.ID, passkey.UserID, passkey.CredentialID, passkey.Name, string(passkey.Credential), int64(passkey.SignCount))
+	return err
+}
+
+func (p *PasskeyRepo) ListByUserID(ctx context.Context, userID string) ([]domain.Passkey, error) {
+	query := `SELECT id, user_id, credential_id, name, credential, sign_count, last_used_at, created_at FROM users_passkeys WHERE user_id=$1 ORDER BY created_at`
+
+	rows, err := p.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var passkeys []domain.Passkey
+	for rows.Next() {
+		var (
+			passkey    domain.Passkey
+			credential string
+			signCount  int64
+		)
+		if err := rows.Scan(&passkey.ID, &passkey.UserID, &passkey.CredentialID, &passkey.Name, &credential, &signCount, &passkey.LastUsedAt, &passkey.CreatedAt); err != nil {
+			return nil, err
+		}
+		passkey.Credential = []byte(credential)
+		passkey.SignCount = uint32(signCount)
+		passkeys = append(passkeys, passkey)
+	}
+
+	return passkeys, rows.Err()
+}
+
+// UpdateUsage stores the credential after a successful login. The update only happens if the
+// stored counter is still below the new one, so two concurrent logins cannot both succeed with the same counter.
+func (p *PasskeyRepo) UpdateUsage(ctx context.Context, passkey *domain.Passkey) error {
+	query := `UPDATE users_passkeys SET credential=$1, sign_count=$2, last_used_at=$3 WHERE id=$4 AND (sign_count < $2 OR $2 = 0)`
+
+	res, err := p.db.ExecContext(ctx, query, string(passkey.Credential), int64(passkey.SignCount), time.Now(), passkey.ID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return domain.ErrPasskeyCloned
+	}
+
+	return nil
+}
+
+func (p *PasskeyRepo) Delete(ctx context.Context, userID, id string) error {
+	query := `DELETE FROM users_passkeys WHERE user_id=$1 AND id=$2`
+
+	res, err := p.db.ExecContext(ctx, query, userID, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return domain.ErrPasskeyNotFound
+	}
+
+	return nil
+}
+
+func (p *PasskeyRepo) SaveCeremony(ctx context.Context, ceremony *domain.WebAuthnCeremony) error {
+	query := `INSERT INTO webauthn_ceremonies (id, user_id, kind, session, expires_at) VALUES ($1, $2, $3, $4, $5)`
+
+	var userID *string
+	if ceremony.UserID != "" {
+		userID = &ceremony.UserID
+	}
+
+	_, err := p.db.ExecContext(ctx, query, ceremony.ID, userID, ceremony.Kind, string(ceremony.Session), ceremony.ExpiresAt)
+	return err
+}
+
+// ConsumeCeremony deletes the ceremony and returns it, so every challenge can be answered only once.
+func (p *PasskeyRepo) ConsumeCeremony(ctx context.Context, id, kind string) (*domain.WebAuthnCeremony, error) {
+	var (
+		ceremony domain.WebAuthnCeremony
+		userID   sql.NullString
+		session  string
+	)
+	query := `DELETE FROM webauthn_ceremonies WHERE id=$1 AND kind=$2 RETURNING id, user_id, kind, session, expires_at`
+
+	err := p.db.QueryRowContext(ctx, query, id, kind).Scan(&ceremony.ID, &userID, &ceremony.Kind, &session, &ceremony.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrInvalidToken
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if ceremony.ExpiresAt.Before(time.Now()) {
+		return nil, domain.ErrInvalidToken
+	}
+
+	ceremony.UserID = userID.String
+	ceremony.Session = []byte(session)
+
+	return &ceremony, nil
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/kcthack-auth/internal/domain"
+)
+
+type PasswordResetRepo struct {
+	db *sql.DB
+}
+
+func NewPasswordResetRepo(db *sql.DB) *PasswordResetRepo {
+	return &PasswordResetRepo{db: db}
+}
+
+func (p *PasswordResetRepo) Save(ctx context.Context, token *domain.PasswordResetToken) error {
+	query := `INSERT INTO users_password_reset_tokens (id, user_id, token_hash, expires_at) VALUES ($1, $2, $3, $4)`
+
+	_, err := p.db.ExecContext(ctx, query, token.ID, token.UserID, hashToken(token.Token), token.ExpiresAt)
+	return err
+}
+
+// FindUserID returns the owner of an unused, unexpired token without using it up.
+func (p *PasswordResetRepo) FindUserID(ctx context.Context, token string) (string, error) {
+	var userID string
+	query := `SELECT user_id FROM users_password_reset_tokens WHERE token_hash=$1 AND used_at IS NULL AND expires_at > $2`
+
+	err := p.db.QueryRowContext(ctx, query, hashToken(token), time.Now()).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", domain.ErrInvalidToken
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return userID, nil
+}
+
+// Consume marks an unused, unexpired token as used and returns its owner.
+func (p *PasswordResetRepo) Consume(ctx context.Context, token string) (string, error) {
+	var userID string
+	query := `UPDATE users_password_reset_tokens SET used_at=$1 WHERE token_hash=$2 AND used_at IS NULL AND expires_at > $1 RETURNING user_id`
+
+	err := p.db.QueryRowContext(ctx, query, time.Now(), hashToken(token)).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", domain.ErrInvalidToken
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return userID, nil
+}
+
+func (p *PasswordResetRepo) DeleteAllByUserID(ctx context.Context, userID string) error {
+	query := `DELETE FROM users_password_reset_tokens WHERE user_id=$1`
+
+	_, err := p.db.ExecContext(ctx, query, userID)
+	return err
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/kcthack-auth/pkg/ratelimit"
+)
+
+// RateLimitRepo is the Postgres backend of the rate limiters, so replicas share the limits.
+type RateLimitRepo struct {
+	db *sql.DB
+}
+
+func NewRateLimitRepo(db *sql.DB) *RateLimitRepo {
+	return &RateLimitRepo{db: db}
+}
+
+// Update locks the row of the key for the duration of fn.
+func (r *RateLimitRepo) Update(ctx context.Context, key string, ttl time.Duration, fn func(state *ratelimit.State)) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+
+	// Expired rows are reset instead of deleted, so the row lock below always has a row to hold.
+	query := `INSERT INTO rate_limits (key, expires_at) VALUES ($1, $2)
+ON CONFLICT (key) DO UPDATE SET value=0, prev=0, start=NULL WHERE rate_limits.expires_at < $3`
+	if _, err := tx.ExecContext(ctx, query, key, now.Add(ttl), now); err != nil {
+		return err
+	}
+
+	var (
+		state ratelimit.State
+		start sql.NullTime
+	)
+	if err := tx.QueryRowContext(ctx, `SELECT value, prev, start FROM rate_limits WHERE key=$1 FOR UPDATE`, key).Scan(&state.Value, &state.Prev, &start); err != nil {
+		return err
+	}
+	state.Start = start.Time
+
+	fn(&state)
+
+	var newStart *time.Time
+	if !state.Start.IsZero() {
+		newStart = &state.Start
+	}
+
+	query = `UPDATE rate_limits SET value=$1, prev=$2, start=$3, expires_at=$4 WHERE key=$5`
+	if _, err := tx.ExecContext(ctx, query, state.Value, state.Prev, newStart, now.Add(ttl), key); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteExpired drops the rows of keys that were not used for their ttl.
+func (r *RateLimitRepo) DeleteExpired(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM rate_limits WHERE expires_at < $1`, time.Now())
+	return err
+}
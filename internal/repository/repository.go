@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/kcthack-auth/internal/domain"
 )
@@ -12,12 +13,132 @@ type AuthRepository interface {
 	FindByEmail(ctx context.Context, email string) (*domain.User, error)
 	Update(ctx context.Context, user *domain.User) error
 	UpdatePassword(ctx context.Context, userID string, passHash string) error
+	ReplacePasswordHash(ctx context.Context, userID, oldHash, newHash string) (bool, error)
+	MarkVerified(ctx context.Context, userID string) error
+	ChangeRole(ctx context.Context, userID, role string) error
+	SetBlocked(ctx context.Context, userID string, blocked bool) error
+	List(ctx context.Context, filter domain.UserFilter) ([]domain.User, int, error)
 	ExistsByEmail(ctx context.Context, email string) (bool, error)
+	CountLoginMethods(ctx context.Context, userID string) (int, error)
 }
 
 type SessionRepository interface {
 	SaveSession(ctx context.Context, session *domain.Session) error
 	FindByToken(ctx context.Context, token string) (*domain.Session, error)
+	Rotate(ctx context.Context, oldID string, next *domain.Session) error
+	ListActiveByUserID(ctx context.Context, userID string) ([]domain.Session, error)
+	FamilyActive(ctx context.Context, familyID string) (bool, error)
 	DeleteByToken(ctx context.Context, token string) error
+	DeleteFamily(ctx context.Context, familyID string) error
+	DeleteFamilyByUserID(ctx context.Context, userID, familyID string) error
 	DeleteAllByUserID(ctx context.Context, userID string) error
+	DeleteAllByUserIDExcept(ctx context.Context, userID, sessionID string) error
+}
+
+type VerificationRepository interface {
+	Save(ctx context.Context, token *domain.VerificationToken) error
+	Consume(ctx context.Context, token string) (string, error)
+	DeleteAllByUserID(ctx context.Context, userID string) error
+}
+
+type PasswordResetRepository interface {
+	Save(ctx context.Context, token *domain.PasswordResetToken) error
+	FindUserID(ctx context.Context, token string) (string, error)
+	Consume(ctx context.Context, token string) (string, error)
+	DeleteAllByUserID(ctx context.Context, userID string) error
+}
+
+type RoleRepository interface {
+	ListByUserID(ctx context.Context, userID string) ([]string, error)
+	Assign(ctx context.Context, userID, role string) error
+	Revoke(ctx context.Context, userID, role string) error
+}
+
+type KeyRepository interface {
+	ListValid(ctx context.Context) ([]domain.SigningKey, error)
+	Create(ctx context.Context, key *domain.SigningKey) error
+	Rotate(ctx context.Context, key *domain.SigningKey, retireAt time.Time) error
+	SealPlaintext(ctx context.Context) (int, error)
+}
+
+type MFARepository interface {
+	GetTOTP(ctx context.Context, userID string) (*domain.TOTP, error)
+	SaveTOTP(ctx context.Context, totp *domain.TOTP) error
+	ConfirmTOTP(ctx context.Context, userID string, step int64) error
+	UseStep(ctx context.Context, userID string, step int64) (bool, error)
+	DeleteTOTP(ctx context.Context, userID string) error
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codes []string) error
+	ConsumeRecoveryCode(ctx context.Context, userID, code string) error
+	CountRecoveryCodes(ctx context.Context, userID string) (int, error)
+	SaveChallenge(ctx context.Context, challenge *domain.MFAChallenge) error
+	FindChallenge(ctx context.Context, token string) (*domain.MFAChallenge, error)
+	DeleteChallenge(ctx context.Context, id string) error
+}
+
+type PasskeyRepository interface {
+	Create(ctx context.Context, passkey *domain.Passkey) error
+	ListByUserID(ctx context.Context, userID string) ([]domain.Passkey, error)
+	UpdateUsage(ctx context.Context, passkey *domain.Passkey) error
+	Delete(ctx context.Context, userID, id string) error
+	SaveCeremony(ctx context.Context, ceremony *domain.WebAuthnCeremony) error
+	ConsumeCeremony(ctx context.Context, id, kind string) (*domain.WebAuthnCeremony, error)
+}
+
+type TelegramRepository interface {
+	FindByTelegramID(ctx context.Context, telegramID int64) (*domain.TelegramAccount, error)
+	FindByUserID(ctx context.Context, userID string) (*domain.TelegramAccount, error)
+	Link(ctx context.Context, account *domain.TelegramAccount) error
+	CreateUser(ctx context.Context, user *domain.User, account *domain.TelegramAccount) error
+	UpdateUsername(ctx context.Context, telegramID int64, username string) error
+	Unlink(ctx context.Context, userID string) error
+	// ConsumeLogin records the hash of used login data until expiresAt and reports false
+	// if it was used before.
+	ConsumeLogin(ctx context.Context, hash string, expiresAt time.Time) (bool, error)
+}
+
+type IdentityRepository interface {
+	FindBySubject(ctx context.Context, provider, subject string) (*domain.Identity, error)
+	ListByUserID(ctx context.Context, userID string) ([]domain.Identity, error)
+	Link(ctx context.Context, identity *domain.Identity) error
+	CreateUser(ctx context.Context, user *domain.User, identity *domain.Identity) error
+	Unlink(ctx context.Context, userID, provider string) error
+	SaveState(ctx context.Context, state *domain.OAuthState) error
+	ConsumeState(ctx context.Context, token, provider string) (*domain.OAuthState, error)
+}
+
+type OIDCRepository interface {
+	CreateClient(ctx context.Context, client *domain.OIDCClient) error
+	FindClient(ctx context.Context, id string) (*domain.OIDCClient, error)
+	ClientSecretMatches(ctx context.Context, id, secret string) (bool, error)
+	ListClients(ctx context.Context) ([]domain.OIDCClient, error)
+	DeleteClient(ctx context.Context, id string) error
+	SaveAuthorization(ctx context.Context, authz *domain.OIDCAuthorization) error
+	FindPendingAuthorization(ctx context.Context, id, userID string) (*domain.OIDCAuthorization, error)
+	SetCode(ctx context.Context, id, code string, expiresAt time.Time) error
+	ConsumeCode(ctx context.Context, code string) (*domain.OIDCAuthorization, error)
+	DeleteAuthorization(ctx context.Context, id string) error
+	FindConsent(ctx context.Context, userID, clientID string) (*domain.OIDCConsent, error)
+	SaveConsent(ctx context.Context, consent *domain.OIDCConsent) error
+}
+
+type MachineClientRepository interface {
+	Create(ctx context.Context, client *domain.MachineClient, secret *domain.MachineClientSecret) error
+	Find(ctx context.Context, id string) (*domain.MachineClient, error)
+	List(ctx context.Context) ([]domain.MachineClient, error)
+	ListSecrets(ctx context.Context, clientID string) ([]domain.MachineClientSecret, error)
+	RotateSecret(ctx context.Context, secret *domain.MachineClientSecret, oldExpireAt time.Time) error
+	Revoke(ctx context.Context, id string) error
+	SecretMatches(ctx context.Context, clientID, secret string) (bool, error)
+}
+
+type DenylistRepository interface {
+	Add(ctx context.Context, jti string, expiresAt time.Time) error
+	Contains(ctx context.Context, jti string) (bool, error)
+}
+
+type LoginAttemptRepository interface {
+	Update(ctx context.Context, key string, fn func(attempts *domain.LoginAttempts)) error
+	Reset(ctx context.Context, key string) error
+	// DeleteExpired drops the counters whose last attempt was before the time and that are not locked.
+	DeleteExpired(ctx context.Context, before time.Time) error
 }
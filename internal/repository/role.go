@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+type RoleRepo struct {
+	db *sql.DB
+}
+
+func NewRoleRepo(db *sql.DB) *RoleRepo {
+	return &RoleRepo{db: db}
+}
+
+func (r *RoleRepo) ListByUserID(ctx context.Context, userID string) ([]string, error) {
+	query := `SELECT role FROM users_roles WHERE user_id=$1 ORDER BY created_at`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+func (r *RoleRepo) Assign(ctx context.Context, userID, role string) error {
+	query := `INSERT INTO users_roles (user_id, role) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+	_, err := r.db.ExecContext(ctx, query, userID, role)
+	return err
+}
+
+func (r *RoleRepo) Revoke(ctx context.Context, userID, role string) error {
+	query := `DELETE FROM users_roles WHERE user_id=$1 AND role=$2`
+
+	_, err := r.db.ExecContext(ctx, query, userID, role)
+	return err
+}
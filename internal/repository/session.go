@@ -2,10 +2,10 @@ package repository
 
 import (
 	"context"
-	"crypto/sha256"
 	"database/sql"
-	"encoding/hex"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/kcthack-auth/internal/domain"
 )
@@ -19,28 +19,22 @@ func NewSessionRepo(db *sql.DB) *SessionRepo {
 }
 
 func (t *SessionRepo) SaveSession(ctx context.Context, session *domain.Session) error {
-	tokenHash := sha256.Sum256([]byte(session.Token))
-	tokenHashHex := hex.EncodeToString(tokenHash[:])
+	query := `INSERT INTO users_sessions (id, user_id, family_id, token_hash, user_agent, ip, device, auth_time, expires_at, last_used_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
 
-	query := `INSERT INTO users_sessions (id, user_id, token_hash,expires_at) VALUES ($1, $2, $3, $4)`
-
-	_, err := t.db.ExecContext(ctx, query, session.ID, session.UserID, tokenHashHex, session.ExpiresAt)
+	_, err := t.db.ExecContext(ctx, query, session.ID, session.UserID, session.FamilyID, hashToken(session.Token), session.UserAgent, session.IP, session.Device, session.AuthTime, session.ExpiresAt, session.LastUsedAt)
 	return err
 }
 
 func (t *SessionRepo) FindByToken(ctx context.Context, token string) (*domain.Session, error) {
 	var session domain.Session
 
-	tokenHash := sha256.Sum256([]byte(token))
-	tokenHashHex := hex.EncodeToString(tokenHash[:])
-
-	query := `SELECT id, user_id, token_hash, expires_at, created_at FROM users_sessions WHERE token_hash=$1`
+	query := `SELECT id, user_id, family_id, token_hash, user_agent, ip, device, auth_time, expires_at, rotated_at, last_used_at, created_at FROM users_sessions WHERE token_hash=$1`
 
-	err := t.db.QueryRowContext(ctx, query, tokenHashHex).Scan(&session.ID, &session.UserID, &session.Token, &session.ExpiresAt, &session.CreatedAt)
+	err := t.db.QueryRowContext(ctx, query, hashToken(token)).Scan(&session.ID, &session.UserID, &session.FamilyID, &session.Token, &session.UserAgent, &session.IP, &session.Device, &session.AuthTime, &session.ExpiresAt, &session.RotatedAt, &session.LastUsedAt, &session.CreatedAt)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, err
+			return nil, domain.ErrSessionNotFound
 		}
 		return nil, err
 	}
@@ -48,19 +42,127 @@ func (t *SessionRepo) FindByToken(ctx context.Context, token string) (*domain.Se
 	return &session, nil
 }
 
+// Rotate marks the session oldID as rotated and stores next in its place.
+// It returns domain.ErrRefreshTokenReused if oldID has already been rotated,
+// which happens when the same refresh token is presented concurrently.
+func (t *SessionRepo) Rotate(ctx context.Context, oldID string, next *domain.Session) error {
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+
+	res, err := tx.ExecContext(ctx, `UPDATE users_sessions SET rotated_at=$1 WHERE id=$2 AND rotated_at IS NULL`, now, oldID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return domain.ErrRefreshTokenReused
+	}
+
+	query := `INSERT INTO users_sessions (id, user_id, family_id, token_hash, user_agent, ip, device, auth_time, expires_at, last_used_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	if _, err := tx.ExecContext(ctx, query, next.ID, next.UserID, next.FamilyID, hashToken(next.Token), next.UserAgent, next.IP, next.Device, next.AuthTime, next.ExpiresAt, next.LastUsedAt); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM users_sessions WHERE user_id=$1 AND expires_at < $2`, next.UserID, now); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListActiveByUserID returns the current session of every active family of the user.
+// CreatedAt of each session is the time its family was started, i.e. the time of login.
+func (t *SessionRepo) ListActiveByUserID(ctx context.Context, userID string) ([]domain.Session, error) {
+	query := `SELECT s.id, s.user_id, s.family_id, s.user_agent, s.ip, s.device, s.expires_at, s.last_used_at,
+       (SELECT MIN(f.created_at) FROM users_sessions f WHERE f.family_id = s.family_id)
+FROM users_sessions s
+WHERE s.user_id=$1 AND s.rotated_at IS NULL AND s.expires_at > $2
+ORDER BY s.last_used_at DESC NULLS LAST, s.created_at DESC`
+
+	rows, err := t.db.QueryContext(ctx, query, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []domain.Session
+	for rows.Next() {
+		var session domain.Session
+		if err := rows.Scan(&session.ID, &session.UserID, &session.FamilyID, &session.UserAgent, &session.IP, &session.Device, &session.ExpiresAt, &session.LastUsedAt, &session.CreatedAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// FamilyActive reports whether the family still has a current session, i.e. the user has not logged out.
+func (t *SessionRepo) FamilyActive(ctx context.Context, familyID string) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM users_sessions WHERE family_id=$1 AND rotated_at IS NULL AND expires_at > $2)`
+
+	err := t.db.QueryRowContext(ctx, query, familyID, time.Now()).Scan(&exists)
+	return exists, err
+}
+
+// DeleteByToken deletes the whole family of the session the token belongs to.
 func (t *SessionRepo) DeleteByToken(ctx context.Context, token string) error {
-	tokenHash := sha256.Sum256([]byte(token))
-	tokenHashHex := hex.EncodeToString(tokenHash[:])
+	query := `DELETE FROM users_sessions WHERE family_id=(SELECT family_id FROM users_sessions WHERE token_hash=$1)`
+
+	_, err := t.db.ExecContext(ctx, query, hashToken(token))
+	return err
+}
 
-	query := `DELETE FROM users_sessions WHERE token_hash=$1`
+func (t *SessionRepo) DeleteFamily(ctx context.Context, familyID string) error {
+	query := `DELETE FROM users_sessions WHERE family_id=$1`
 
-	_, err := t.db.ExecContext(ctx, query, tokenHashHex)
+	_, err := t.db.ExecContext(ctx, query, familyID)
 	return err
 }
 
+// DeleteFamilyByUserID deletes the family only if it belongs to the user.
+func (t *SessionRepo) DeleteFamilyByUserID(ctx context.Context, userID, familyID string) error {
+	query := `DELETE FROM users_sessions WHERE user_id=$1 AND family_id=$2`
+
+	res, err := t.db.ExecContext(ctx, query, userID, familyID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return domain.ErrSessionNotFound
+	}
+
+	return nil
+}
+
 func (t *SessionRepo) DeleteAllByUserID(ctx context.Context, userID string) error {
 	query := `DELETE FROM users_sessions WHERE user_id=$1`
 
 	_, err := t.db.ExecContext(ctx, query, userID)
 	return err
 }
+
+// DeleteAllByUserIDExcept deletes every session of the user except the family of sessionID.
+func (t *SessionRepo) DeleteAllByUserIDExcept(ctx context.Context, userID, sessionID string) error {
+	query := `DELETE FROM users_sessions WHERE user_id=$1 AND family_id<>(SELECT family_id FROM users_sessions WHERE id=$2)`
+
+	_, err := t.db.ExecContext(ctx, query, userID, sessionID)
+	return err
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/pkg/logger"
+)
+
+type TelegramRepo struct {
+	db *sql.DB
+}
+
+func NewTelegramRepo(db *sql.DB) *TelegramRepo {
+	return &TelegramRepo{db: db}
+}
+
+func (t *TelegramRepo) FindByTelegramID(ctx context.Context, telegramID int64) (*domain.TelegramAccount, error) {
+	query := `SELECT user_id, telegram_id, COALESCE(username, ''), created_at FROM users_telegram WHERE telegram_id=$1`
+
+	return t.find(ctx, query, telegramID)
+}
+
+func (t *TelegramRepo) FindByUserID(ctx context.Context, userID string) (*domain.TelegramAccount, error) {
+	query := `SELECT user_id, telegram_id, COALESCE(username, ''), created_at FROM users_telegram WHERE user_id=$1`
+
+	return t.find(ctx, query, userID)
+}
+
+func (t *TelegramRepo) find(ctx context.Context, query string, arg any) (*domain.TelegramAccount, error) {
+	var account domain.TelegramAccount
+
+	err := t.db.QueryRowContext(ctx, query, arg).Scan(&account.UserID, &account.TelegramID, &account.Username, &account.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrTelegramNotLinked
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+// Link stores the account. It fails with ErrTelegramLinked if the Telegram user or the user
+// already has a link.
+func (t *TelegramRepo) Link(ctx context.Context, account *domain.TelegramAccount) error {
+	query := `INSERT INTO users_telegram (user_id, telegram_id, username) VALUES ($1, $2, NULLIF($3, '')) ON CONFLICT DO NOTHING`
+
+	res, err := t.db.ExecContext(ctx, query, account.UserID, account.TelegramID, account.Username)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return domain.ErrTelegramLinked
+	}
+
+	return nil
+}
+
+// CreateUser creates a user with the primary role together with the Telegram link in one transaction, so two concurrent
+// first logins of the same Telegram user cannot create two accounts.
+func (t *TelegramRepo) CreateUser(ctx context.Context, user *domain.User, account *domain.TelegramAccount) error {
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createUser(ctx, tx, user); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO users_telegram (user_id, telegram_id, username) VALUES ($1, $2, NULLIF($3, '')) ON CONFLICT DO NOTHING`
+
+	res, err := tx.ExecContext(ctx, query, account.UserID, account.TelegramID, account.Username)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return domain.ErrTelegramLinked
+	}
+
+	return tx.Commit()
+}
+
+func (t *TelegramRepo) UpdateUsername(ctx context.Context, telegramID int64, username string) error {
+	query := `UPDATE users_telegram SET username=NULLIF($1, '') WHERE telegram_id=$2`
+
+	_, err := t.db.ExecContext(ctx, query, username, telegramID)
+	return err
+}
+
+func (t *TelegramRepo) Unlink(ctx context.Context, userID string) error {
+	query := `DELETE FROM users_telegram WHERE user_id=$1`
+
+	res, err := t.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return domain.ErrTelegramNotLinked
+	}
+
+	return nil
+}
+
+// ConsumeLogin stores the hash of the login data, so the same data cannot be used twice before
+// it expires. Hashes that expired by now are dropped, a failure to drop them is only logged.
+func (t *TelegramRepo) ConsumeLogin(ctx context.Context, hash string, expiresAt time.Time) (bool, error) {
+	query := `INSERT INTO telegram_logins (hash, expires_at) VALUES ($1, $2) ON CONFLICT (hash) DO NOTHING`
+
+	res, err := t.db.ExecContext(ctx, query, hash, expiresAt)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	// The hash is recorded already, a failed cleanup must not fail the login.
+	if _, err := t.db.ExecContext(ctx, `DELETE FROM telegram_logins WHERE expires_at < $1`, time.Now()); err != nil {
+		slog.WarnContext(ctx, "failed to delete expired telegram logins", logger.Err(err))
+	}
+
+	return rows > 0, nil
+}
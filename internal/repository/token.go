@@ -0,0 +1,11 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+func hashToken(token string) string {
+	tokenHash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(tokenHash[:])
+}
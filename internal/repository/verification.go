@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/kcthack-auth/internal/domain"
+)
+
+type VerificationRepo struct {
+	db *sql.DB
+}
+
+func NewVerificationRepo(db *sql.DB) *VerificationRepo {
+	return &VerificationRepo{db: db}
+}
+
+func (v *VerificationRepo) Save(ctx context.Context, token *domain.VerificationToken) error {
+	query := `INSERT INTO users_verification_tokens (id, user_id, token_hash, expires_at) VALUES ($1, $2, $3, $4)`
+
+	_, err := v.db.ExecContext(ctx, query, token.ID, token.UserID, hashToken(token.Token), token.ExpiresAt)
+	return err
+}
+
+// Consume marks an unused, unexpired token as used and returns its owner.
+// The check and the update happen in one statement, so a token can be consumed only once.
+func (v *VerificationRepo) Consume(ctx context.Context, token string) (string, error) {
+	var userID string
+	query := `UPDATE users_verification_tokens SET used_at=$1 WHERE token_hash=$2 AND used_at IS NULL AND expires_at > $1 RETURNING user_id`
+
+	err := v.db.QueryRowContext(ctx, query, time.Now(), hashToken(token)).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", domain.ErrInvalidToken
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return userID, nil
+}
+
+func (v *VerificationRepo) DeleteAllByUserID(ctx context.Context, userID string) error {
+	query := `DELETE FROM users_verification_tokens WHERE user_id=$1`
+
+	_, err := v.db.ExecContext(ctx, query, userID)
+	return err
+}
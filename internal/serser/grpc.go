@@ -0,0 +1,47 @@
+package serser
+
+import (
+	"context"
+	"net"
+
+	"github.com/kcthack-auth/internal/config"
+	"google.golang.org/grpc"
+)
+
+type GRPCServer struct {
+	server *grpc.Server
+	addr   string
+}
+
+func NewGRPCServer(server *grpc.Server, config config.Config) *GRPCServer {
+	return &GRPCServer{
+		server: server,
+		addr:   ":" + config.GRPC.Port,
+	}
+}
+
+func (s *GRPCServer) Start() error {
+	lis, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	return s.server.Serve(lis)
+}
+
+// Stop waits for running calls to finish. Calls still running when ctx is done are cancelled.
+func (s *GRPCServer) Stop(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/repository"
+)
+
+const (
+	defaultUsersLimit = 20
+	maxUsersLimit     = 100
+)
+
+type AdminService struct {
+	repo  repository.AuthRepository
+	srepo repository.SessionRepository
+	roles *RoleService
+}
+
+func NewAdminService(repo repository.AuthRepository, srepo repository.SessionRepository, roles *RoleService) *AdminService {
+	return &AdminService{
+		repo:  repo,
+		srepo: srepo,
+		roles: roles,
+	}
+}
+
+func (a *AdminService) ListUsers(ctx context.Context, filter domain.UserFilter) ([]domain.User, int, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultUsersLimit
+	}
+
+	if filter.Limit > maxUsersLimit {
+		filter.Limit = maxUsersLimit
+	}
+
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	users, total, err := a.repo.List(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	return users, total, nil
+}
+
+func (a *AdminService) GetUser(ctx context.Context, userID string) (*domain.User, []string, error) {
+	user, err := a.repo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	roles, err := a.roles.Roles(ctx, user)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, roles, nil
+}
+
+// ChangeRole replaces the primary role of the user. Additional roles are kept.
+func (a *AdminService) ChangeRole(ctx context.Context, adminID, userID, role string) error {
+	if adminID == userID {
+		return domain.ErrSelfAction
+	}
+
+	if !a.roles.Known(role) {
+		return domain.ErrUnknownRole
+	}
+
+	if err := a.repo.ChangeRole(ctx, userID, role); err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to change role: %w", err)
+	}
+
+	return nil
+}
+
+func (a *AdminService) Verify(ctx context.Context, userID string) error {
+	user, err := a.repo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if user.IsVerified {
+		return domain.ErrUserAlreadyVerified
+	}
+
+	if err := a.repo.MarkVerified(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to mark user as verified: %w", err)
+	}
+
+	return nil
+}
+
+// SetBlocked blocks or unblocks the user. Blocking also revokes every session of the user.
+func (a *AdminService) SetBlocked(ctx context.Context, adminID, userID string, blocked bool) error {
+	if adminID == userID {
+		return domain.ErrSelfAction
+	}
+
+	if _, err := a.repo.FindByID(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := a.repo.SetBlocked(ctx, userID, blocked); err != nil {
+		return fmt.Errorf("failed to update user block status: %w", err)
+	}
+
+	if blocked {
+		if err := a.srepo.DeleteAllByUserID(ctx, userID); err != nil {
+			return fmt.Errorf("failed to delete user sessions: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (a *AdminService) Logout(ctx context.Context, userID string) error {
+	if _, err := a.repo.FindByID(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := a.srepo.DeleteAllByUserID(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete user sessions: %w", err)
+	}
+
+	return nil
+}
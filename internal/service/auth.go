@@ -2,29 +2,45 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/kcthack-auth/internal/domain"
 	"github.com/kcthack-auth/internal/repository"
 	"github.com/kcthack-auth/pkg/auth"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/kcthack-auth/pkg/hasher"
+	"github.com/kcthack-auth/pkg/logger"
+	"github.com/kcthack-auth/pkg/passpolicy"
 )
 
 type AuthService struct {
 	repo       repository.AuthRepository
 	srepo      repository.SessionRepository
 	tm         auth.JWTManager
+	roles      *RoleService
+	mfa        *MFAService
+	verifier   *VerificationService
+	lockout    *LockoutService
+	passwords  *passpolicy.Policy
+	hasher     hasher.PasswordHasher
 	accessTTL  time.Duration
 	refreshTTL time.Duration
 }
 
-func NewAuthService(repo repository.AuthRepository, srepo repository.SessionRepository, tm auth.JWTManager, accessTTL, refreshTTL time.Duration) *AuthService {
+func NewAuthService(repo repository.AuthRepository, srepo repository.SessionRepository, tm auth.JWTManager, roles *RoleService, mfa *MFAService, verifier *VerificationService, lockout *LockoutService, passwords *passpolicy.Policy, hasher hasher.PasswordHasher, accessTTL, refreshTTL time.Duration) *AuthService {
 	return &AuthService{
 		repo:       repo,
 		srepo:      srepo,
 		tm:         tm,
+		roles:      roles,
+		mfa:        mfa,
+		verifier:   verifier,
+		lockout:    lockout,
+		passwords:  passwords,
+		hasher:     hasher,
 		accessTTL:  accessTTL,
 		refreshTTL: refreshTTL,
 	}
@@ -43,6 +59,10 @@ func (a *AuthService) Register(ctx context.Context, req RegisterReq) (*AuthResp,
 		return nil, fmt.Errorf("last name field cannot be empty")
 	}
 
+	if err := a.passwords.Check(req.Password, req.FirstName, req.LastName, req.Email); err != nil {
+		return nil, fmt.Errorf("%w: %w", domain.ErrValidation, err)
+	}
+
 	exists, err := a.repo.ExistsByEmail(ctx, req.Email)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check email existence: %w", err)
@@ -52,7 +72,7 @@ func (a *AuthService) Register(ctx context.Context, req RegisterReq) (*AuthResp,
 		return nil, fmt.Errorf("user with email: %s already exists", req.Email)
 	}
 
-	passHash, err := bcrypt.GenerateFromPassword([]byte(req.Password), 12)
+	passHash, err := a.hasher.Hash(req.Password)
 	if err != nil {
 		return nil, fmt.Errorf("failed to hash password – user: %v, err: %w", req.Email, err)
 	}
@@ -63,8 +83,9 @@ func (a *AuthService) Register(ctx context.Context, req RegisterReq) (*AuthResp,
 		LastName:   req.LastName,
 		Email:      req.Email,
 		Role:       domain.Participant,
-		PassHash:   string(passHash),
+		PassHash:   passHash,
 		IsVerified: false,
+		UpdatedAt:  time.Now(),
 		CreatedAt:  time.Now(),
 	}
 
@@ -72,29 +93,13 @@ func (a *AuthService) Register(ctx context.Context, req RegisterReq) (*AuthResp,
 		return nil, fmt.Errorf("failed to register – user: %v, err: %w", req.Email, err)
 	}
 
-	accessToken, err := a.tm.NewAccess(user.ID, user.Role, a.accessTTL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate access token: %w", err)
-	}
-
-	refreshToken := a.tm.NewRefresh()
-
-	authResp := AuthResp{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresAt:    time.Now().Add(a.accessTTL),
-	}
-
-	if err := a.srepo.SaveSession(ctx, &domain.Session{
-		ID:        uuid.NewString(),
-		UserID:    user.ID,
-		Token:     refreshToken,
-		ExpiresAt: time.Now().Add(a.refreshTTL),
-	}); err != nil {
-		return nil, fmt.Errorf("failed to save user session: %w", err)
+	// The account is already created at this point, so a mail failure must not fail the registration:
+	// the user can always request another email via resend.
+	if err := a.verifier.Send(ctx, &user); err != nil {
+		slog.ErrorContext(ctx, "failed to send verification email", slog.String("user_id", user.ID), logger.Err(err))
 	}
 
-	return &authResp, nil
+	return a.createSession(ctx, &user, req.Client)
 }
 
 func (a *AuthService) Login(ctx context.Context, req *LoginReq) (*AuthResp, error) {
@@ -106,76 +111,264 @@ func (a *AuthService) Login(ctx context.Context, req *LoginReq) (*AuthResp, erro
 		return nil, fmt.Errorf("password field cannot be empty")
 	}
 
+	attempt, err := a.lockout.Attempt(ctx, req.Email, req.Client.IP)
+	if err != nil {
+		return nil, err
+	}
+
 	user, err := a.repo.FindByEmail(ctx, req.Email)
 	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			a.lockout.Failure(ctx, attempt)
+		}
 		return nil, fmt.Errorf("failed to get user with email: %s, err: %w", req.Email, err)
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PassHash), []byte(req.Password)); err != nil {
-		return nil, fmt.Errorf("invalid credentials: %w", err)
+	// A hash in an unknown format cannot match any password, it counts as a failure too.
+	if match, err := a.hasher.Verify(req.Password, user.PassHash); err != nil || !match {
+		a.lockout.Failure(ctx, attempt)
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	if err := a.lockout.Success(ctx, attempt); err != nil {
+		return nil, err
 	}
 
-	accessToken, err := a.tm.NewAccess(user.ID, user.Role, a.accessTTL)
+	a.rehash(ctx, user, req.Password)
+
+	return a.completeLogin(ctx, user, req.Client)
+}
+
+// rehash replaces a hash made with an outdated algorithm or parameters while the password is known.
+// A failure is only logged, the old hash still works.
+func (a *AuthService) rehash(ctx context.Context, user *domain.User, password string) {
+	if !a.hasher.NeedsRehash(user.PassHash) {
+		return
+	}
+
+	passHash, err := a.hasher.Hash(password)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate access token: %w", err)
+		slog.ErrorContext(ctx, "failed to rehash password", slog.String("user_id", user.ID), logger.Err(err))
+		return
 	}
 
-	refreshToken := a.tm.NewRefresh()
+	// The password may have been changed since it was verified, the new hash is only stored over the verified one.
+	replaced, err := a.repo.ReplacePasswordHash(ctx, user.ID, user.PassHash, passHash)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to save rehashed password", slog.String("user_id", user.ID), logger.Err(err))
+		return
+	}
 
-	authResp := AuthResp{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresAt:    time.Now().Add(a.accessTTL),
+	if !replaced {
+		slog.InfoContext(ctx, "password changed during login, rehash skipped", slog.String("user_id", user.ID))
+		return
 	}
 
-	if err := a.srepo.SaveSession(ctx, &domain.Session{
-		ID:        uuid.NewString(),
-		UserID:    user.ID,
-		Token:     refreshToken,
-		ExpiresAt: time.Now().Add(a.refreshTTL),
-	}); err != nil {
-		return nil, fmt.Errorf("failed to save user session: %w", err)
+	user.PassHash = passHash
+}
+
+// completeLogin finishes a login after the first factor was checked: blocked users are rejected
+// and users with TOTP get an MFA challenge instead of tokens.
+func (a *AuthService) completeLogin(ctx context.Context, user *domain.User, client ClientInfo) (*AuthResp, error) {
+	if user.IsBlocked {
+		return nil, domain.ErrUserBlocked
+	}
+
+	challenge, err := a.mfa.LoginChallenge(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	if challenge != nil {
+		return &AuthResp{MFA: challenge}, nil
+	}
+
+	return a.createSession(ctx, user, client)
+}
+
+// LoginMFA finishes a login of a user with TOTP enabled.
+func (a *AuthService) LoginMFA(ctx context.Context, req *LoginMFAReq) (*AuthResp, error) {
+	challenge, err := a.mfa.Challenge(ctx, req.Token, domain.MFAChallengeVerify)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.mfa.Verify(ctx, challenge.UserID, req.Code); err != nil {
+		return nil, err
+	}
+
+	if err := a.mfa.CompleteChallenge(ctx, challenge); err != nil {
+		return nil, err
+	}
+
+	user, err := a.repo.FindByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	if user.IsBlocked {
+		return nil, domain.ErrUserBlocked
+	}
+
+	return a.createSession(ctx, user, req.Client)
+}
+
+// LoginMFAEnroll starts the mandatory TOTP enrollment of a user who is not allowed to log in without it.
+func (a *AuthService) LoginMFAEnroll(ctx context.Context, token string) (*TOTPEnrollment, error) {
+	challenge, err := a.mfa.Challenge(ctx, token, domain.MFAChallengeEnroll)
+	if err != nil {
+		return nil, err
 	}
 
-	return &authResp, nil
+	return a.mfa.Enroll(ctx, challenge.UserID)
 }
 
-func (a *AuthService) RefreshToken(ctx context.Context, token string) (*AuthResp, error) {
+// LoginMFAEnrollConfirm confirms the mandatory enrollment and finishes the login.
+// The response carries the recovery codes of the user.
+func (a *AuthService) LoginMFAEnrollConfirm(ctx context.Context, req *LoginMFAReq) (*AuthResp, error) {
+	challenge, err := a.mfa.Challenge(ctx, req.Token, domain.MFAChallengeEnroll)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := a.mfa.Confirm(ctx, challenge.UserID, req.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.mfa.CompleteChallenge(ctx, challenge); err != nil {
+		return nil, err
+	}
+
+	user, err := a.repo.FindByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	if user.IsBlocked {
+		return nil, domain.ErrUserBlocked
+	}
+
+	authResp, err := a.createSession(ctx, user, req.Client)
+	if err != nil {
+		return nil, err
+	}
+	authResp.RecoveryCodes = codes
+
+	return authResp, nil
+}
+
+func (a *AuthService) RefreshToken(ctx context.Context, token string, client ClientInfo) (*AuthResp, error) {
 	session, err := a.srepo.FindByToken(ctx, token)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find user session: %w", err)
 	}
 
+	if session.ExpiresAt.Before(time.Now()) {
+		return nil, domain.ErrSessionExpired
+	}
+
+	// A rotated token can only be presented again if it was stolen, so the whole family is revoked:
+	// both the attacker and the legitimate client have to log in again.
+	if session.RotatedAt != nil {
+		if err := a.srepo.DeleteFamily(ctx, session.FamilyID); err != nil {
+			return nil, fmt.Errorf("failed to revoke session family: %w", err)
+		}
+		return nil, domain.ErrRefreshTokenReused
+	}
+
 	user, err := a.repo.FindByID(ctx, session.UserID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find user: %w", err)
 	}
 
-	accessToken, err := a.tm.NewAccess(user.ID, user.Role, a.accessTTL)
+	if user.IsBlocked {
+		return nil, domain.ErrUserBlocked
+	}
+
+	authResp, next, err := a.issueTokens(ctx, user, session, client)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate access token: %w", err)
+		return nil, err
 	}
 
-	refreshToken := a.tm.NewRefresh()
+	if err := a.srepo.Rotate(ctx, session.ID, next); err != nil {
+		if errors.Is(err, domain.ErrRefreshTokenReused) {
+			if err := a.srepo.DeleteFamily(ctx, session.FamilyID); err != nil {
+				return nil, fmt.Errorf("failed to revoke session family: %w", err)
+			}
+			return nil, domain.ErrRefreshTokenReused
+		}
+		return nil, fmt.Errorf("failed to rotate user session: %w", err)
+	}
 
-	authResp := AuthResp{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresAt:    time.Now().Add(a.accessTTL),
+	return authResp, nil
+}
+
+func (a *AuthService) Logout(ctx context.Context, token string) error {
+	return a.srepo.DeleteByToken(ctx, token)
+}
+
+// createSession starts a new session family for the user and returns its tokens.
+func (a *AuthService) createSession(ctx context.Context, user *domain.User, client ClientInfo) (*AuthResp, error) {
+	authResp, session, err := a.issueTokens(ctx, user, nil, client)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := a.srepo.SaveSession(ctx, &domain.Session{
-		ID:        uuid.NewString(),
-		UserID:    user.ID,
-		Token:     refreshToken,
-		ExpiresAt: time.Now().Add(a.refreshTTL),
-	}); err != nil {
+	if err := a.srepo.SaveSession(ctx, session); err != nil {
 		return nil, fmt.Errorf("failed to save user session: %w", err)
 	}
 
-	return &authResp, nil
+	return authResp, nil
 }
 
-func (a *AuthService) Logout(ctx context.Context, token string) error {
-	return a.srepo.DeleteByToken(ctx, token)
+// issueTokens creates an access token and a session for a new refresh token without saving it.
+// The new session continues the family of prev, a nil prev starts a new family at the current time.
+func (a *AuthService) issueTokens(ctx context.Context, user *domain.User, prev *domain.Session, client ClientInfo) (*AuthResp, *domain.Session, error) {
+	roles, err := a.roles.Roles(ctx, user)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refreshToken := a.tm.NewRefresh()
+
+	now := time.Now()
+	session := domain.Session{
+		ID:         uuid.NewString(),
+		UserID:     user.ID,
+		Token:      refreshToken,
+		UserAgent:  truncate(client.UserAgent, 512),
+		IP:         client.IP,
+		Device:     deviceLabel(client.UserAgent),
+		AuthTime:   now,
+		ExpiresAt:  now.Add(a.refreshTTL),
+		LastUsedAt: &now,
+	}
+	session.FamilyID = session.ID
+	if prev != nil {
+		session.FamilyID = prev.FamilyID
+		session.AuthTime = prev.AuthTime
+	}
+
+	// The token is bound to the family, so introspection reports it inactive once the user logs out.
+	accessToken, err := a.tm.NewAccess(auth.TokenClaims{
+		SessionID: session.FamilyID,
+		AuthTime:  session.AuthTime,
+		UserID:    user.ID,
+		Email:     user.Email,
+		Role:      user.Role,
+		Roles:     roles,
+		Verified:  user.IsVerified,
+	}, a.accessTTL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	authResp := AuthResp{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    time.Now().Add(a.accessTTL),
+	}
+
+	return &authResp, &session, nil
 }
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/repository"
+	"github.com/kcthack-auth/pkg/oauth"
+)
+
+type IdentityService struct {
+	repo        repository.AuthRepository
+	irepo       repository.IdentityRepository
+	auth        *AuthService
+	providers   map[string]*oauth.Provider
+	stateTTL    time.Duration
+	frontendURL string
+}
+
+func NewIdentityService(repo repository.AuthRepository, irepo repository.IdentityRepository, auth *AuthService, providers map[string]*oauth.Provider, stateTTL time.Duration, frontendURL string) *IdentityService {
+	return &IdentityService{
+		repo:        repo,
+		irepo:       irepo,
+		auth:        auth,
+		providers:   providers,
+		stateTTL:    stateTTL,
+		frontendURL: frontendURL,
+	}
+}
+
+// Providers returns the names of the configured providers.
+func (i *IdentityService) Providers() []string {
+	names := make([]string, 0, len(i.providers))
+	for name := range i.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Start begins an authorization code flow. An empty userID starts a login, otherwise the
+// provider account is linked to the user when the flow completes.
+func (i *IdentityService) Start(ctx context.Context, providerName, userID string) (*OAuthStart, error) {
+	provider, ok := i.providers[providerName]
+	if !ok {
+		return nil, domain.ErrUnknownProvider
+	}
+
+	token, err := newOneTimeToken()
+	if err != nil {
+		return nil, err
+	}
+
+	state := domain.OAuthState{
+		ID:        uuid.NewString(),
+		Token:     token,
+		Provider:  providerName,
+		UserID:    userID,
+		Verifier:  oauth.GenerateVerifier(),
+		ExpiresAt: time.Now().Add(i.stateTTL),
+	}
+
+	if err := i.irepo.SaveState(ctx, &state); err != nil {
+		return nil, fmt.Errorf("failed to save oauth state: %w", err)
+	}
+
+	return &OAuthStart{
+		URL:       provider.AuthCodeURL(state.Token, state.Verifier),
+		State:     state.Token,
+		ExpiresAt: state.ExpiresAt,
+	}, nil
+}
+
+// Callback completes the flow started by Start. Logins return tokens or an MFA challenge,
+// links return the new identity.
+func (i *IdentityService) Callback(ctx context.Context, req OAuthCallbackReq) (*OAuthCallbackResp, error) {
+	provider, ok := i.providers[req.Provider]
+	if !ok {
+		return nil, domain.ErrUnknownProvider
+	}
+
+	state, err := i.irepo.ConsumeState(ctx, req.State, req.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := provider.Exchange(ctx, req.Code, state.Verifier, req.Params)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrProviderAuth, err)
+	}
+
+	if state.UserID != "" {
+		identity, err := i.link(ctx, state.UserID, req.Provider, info)
+		if err != nil {
+			return nil, err
+		}
+		return &OAuthCallbackResp{Identity: identity}, nil
+	}
+
+	var user *domain.User
+	identity, err := i.irepo.FindBySubject(ctx, req.Provider, info.Subject)
+	switch {
+	case errors.Is(err, domain.ErrIdentityNotFound):
+		user, err = i.register(ctx, req.Provider, info)
+		if err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, fmt.Errorf("failed to find identity: %w", err)
+	default:
+		user, err = i.repo.FindByID(ctx, identity.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find user: %w", err)
+		}
+	}
+
+	authResp, err := i.auth.completeLogin(ctx, user, req.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OAuthCallbackResp{Auth: authResp}, nil
+}
+
+func (i *IdentityService) List(ctx context.Context, userID string) ([]domain.Identity, error) {
+	identities, err := i.irepo.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+
+	return identities, nil
+}
+
+// Unlink removes the identity at the provider unless it is the only way for the user to log in.
+func (i *IdentityService) Unlink(ctx context.Context, userID, provider string) error {
+	methods, err := i.repo.CountLoginMethods(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if methods <= 1 {
+		identities, err := i.irepo.ListByUserID(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("failed to list identities: %w", err)
+		}
+		for _, identity := range identities {
+			if identity.Provider == provider {
+				return domain.ErrLastLoginMethod
+			}
+		}
+		return domain.ErrIdentityNotFound
+	}
+
+	return i.irepo.Unlink(ctx, userID, provider)
+}
+
+// ResultURL returns the frontend page the user is sent to after the callback.
+func (i *IdentityService) ResultURL(params url.Values) string {
+	return i.frontendURL + "?" + params.Encode()
+}
+
+func (i *IdentityService) link(ctx context.Context, userID, provider string, info *oauth.UserInfo) (*domain.Identity, error) {
+	identity := domain.Identity{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Provider:  provider,
+		Subject:   info.Subject,
+		Email:     truncate(info.Email, 100),
+		CreatedAt: time.Now(),
+	}
+
+	if err := i.irepo.Link(ctx, &identity); err != nil {
+		if errors.Is(err, domain.ErrIdentityLinked) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	return &identity, nil
+}
+
+// register creates an account for a new external user. Existing accounts with the same email are not
+// taken over automatically: the owner has to log in and link the provider explicitly.
+func (i *IdentityService) register(ctx context.Context, provider string, info *oauth.UserInfo) (*domain.User, error) {
+	if info.Email != "" {
+		exists, err := i.repo.ExistsByEmail(ctx, info.Email)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check email existence: %w", err)
+		}
+		if exists {
+			return nil, domain.ErrIdentityEmailTaken
+		}
+	}
+
+	user := domain.User{
+		ID:         uuid.NewString(),
+		FirstName:  truncate(info.FirstName, maxNameLength),
+		LastName:   truncate(info.LastName, maxNameLength),
+		Email:      info.Email,
+		Role:       domain.Participant,
+		IsVerified: info.Email != "" && info.EmailVerified,
+		UpdatedAt:  time.Now(),
+		CreatedAt:  time.Now(),
+	}
+	if user.FirstName == "" {
+		user.FirstName = "User"
+	}
+
+	identity := domain.Identity{
+		ID:        uuid.NewString(),
+		UserID:    user.ID,
+		Provider:  provider,
+		Subject:   info.Subject,
+		Email:     info.Email,
+		CreatedAt: time.Now(),
+	}
+
+	if err := i.irepo.CreateUser(ctx, &user, &identity); err != nil {
+		return nil, fmt.Errorf("failed to register %s user: %w", provider, err)
+	}
+
+	return &user, nil
+}
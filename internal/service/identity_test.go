@@ -0,0 +1,377 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/pkg/oauth"
+)
+
+// fakeProvider is an OAuth2 provider that only issues a token for a code together with the
+// verifier matching the PKCE challenge the code was issued for.
+type fakeProvider struct {
+	t          *testing.T
+	server     *httptest.Server
+	mu         sync.Mutex
+	challenges map[string]string
+	verifiers  []string
+	userInfo   map[string]any
+}
+
+func newFakeProvider(t *testing.T, userInfo map[string]any) *fakeProvider {
+	t.Helper()
+
+	p := &fakeProvider{t: t, challenges: make(map[string]string), userInfo: userInfo}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /token", p.token)
+	mux.HandleFunc("GET /userinfo", p.info)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	return p
+}
+
+// authorize plays the user consenting at the provider and returns the code of the redirect.
+func (p *fakeProvider) authorize(authURL string) string {
+	p.t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		p.t.Fatalf("parse authorization url: %v", err)
+	}
+
+	query := u.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		p.t.Fatalf("authorization url without an S256 challenge: %s", authURL)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	code := uuid.NewString()
+	p.challenges[code] = query.Get("code_challenge")
+
+	return code
+}
+
+func (p *fakeProvider) token(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	code, verifier := r.FormValue("code"), r.FormValue("code_verifier")
+	p.verifiers = append(p.verifiers, verifier)
+
+	sum := sha256.Sum256([]byte(verifier))
+	challenge, ok := p.challenges[code]
+	if !ok || challenge != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+	delete(p.challenges, code)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"access_token":"provider-token","token_type":"Bearer"}`))
+}
+
+func (p *fakeProvider) info(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer provider-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p.userInfo)
+}
+
+func (p *fakeProvider) provider(t *testing.T, claims oauth.ClaimMapping) *oauth.Provider {
+	t.Helper()
+
+	provider, err := oauth.NewProvider(context.Background(), "fake", oauth.Config{
+		ClientID:    "client",
+		AuthURL:     p.server.URL + "/authorize",
+		TokenURL:    p.server.URL + "/token",
+		UserInfoURL: p.server.URL + "/userinfo",
+		Claims:      claims,
+	}, "https://auth.example.com/api/v1/user/oauth/fake/callback")
+	if err != nil {
+		t.Fatalf("provider: %v", err)
+	}
+
+	return provider
+}
+
+// fakeIdentities keeps identities and states in memory with the semantics of IdentityRepo.
+type fakeIdentities struct {
+	mu         sync.Mutex
+	identities []domain.Identity
+	users      []domain.User
+	states     map[string]domain.OAuthState
+}
+
+func newFakeIdentities() *fakeIdentities {
+	return &fakeIdentities{states: make(map[string]domain.OAuthState)}
+}
+
+func (f *fakeIdentities) FindBySubject(ctx context.Context, provider, subject string) (*domain.Identity, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, identity := range f.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return &identity, nil
+		}
+	}
+
+	return nil, domain.ErrIdentityNotFound
+}
+
+func (f *fakeIdentities) ListByUserID(ctx context.Context, userID string) ([]domain.Identity, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var identities []domain.Identity
+	for _, identity := range f.identities {
+		if identity.UserID == userID {
+			identities = append(identities, identity)
+		}
+	}
+
+	return identities, nil
+}
+
+func (f *fakeIdentities) Link(ctx context.Context, identity *domain.Identity) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, other := range f.identities {
+		if other.Provider == identity.Provider && (other.Subject == identity.Subject || other.UserID == identity.UserID) {
+			return domain.ErrIdentityLinked
+		}
+	}
+	f.identities = append(f.identities, *identity)
+
+	return nil
+}
+
+func (f *fakeIdentities) CreateUser(ctx context.Context, user *domain.User, identity *domain.Identity) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.users = append(f.users, *user)
+	f.identities = append(f.identities, *identity)
+
+	return nil
+}
+
+func (f *fakeIdentities) Unlink(ctx context.Context, userID, provider string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, identity := range f.identities {
+		if identity.UserID == userID && identity.Provider == provider {
+			f.identities = append(f.identities[:i], f.identities[i+1:]...)
+			return nil
+		}
+	}
+
+	return domain.ErrIdentityNotFound
+}
+
+func (f *fakeIdentities) SaveState(ctx context.Context, state *domain.OAuthState) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.states[state.Token] = *state
+	return nil
+}
+
+func (f *fakeIdentities) ConsumeState(ctx context.Context, token, provider string) (*domain.OAuthState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	state, ok := f.states[token]
+	if !ok || state.Provider != provider {
+		return nil, domain.ErrInvalidToken
+	}
+	delete(f.states, token)
+
+	if state.ExpiresAt.Before(time.Now()) {
+		return nil, domain.ErrInvalidToken
+	}
+
+	return &state, nil
+}
+
+type identityTest struct {
+	service    *IdentityService
+	provider   *fakeProvider
+	identities *fakeIdentities
+	users      *fakeUsers
+	user       *domain.User
+}
+
+func newIdentityTest(t *testing.T, userInfo map[string]any, claims oauth.ClaimMapping, stateTTL time.Duration) *identityTest {
+	t.Helper()
+
+	user := &domain.User{ID: uuid.NewString(), Email: "user@example.com", FirstName: "Test", Role: domain.Participant}
+	users := &fakeUsers{users: map[string]*domain.User{user.ID: user}, loginMethods: map[string]int{}}
+	identities := newFakeIdentities()
+	provider := newFakeProvider(t, userInfo)
+
+	providers := map[string]*oauth.Provider{"fake": provider.provider(t, claims)}
+
+	return &identityTest{
+		service:    NewIdentityService(users, identities, nil, providers, stateTTL, "https://app.example.com/oauth/result"),
+		provider:   provider,
+		identities: identities,
+		users:      users,
+		user:       user,
+	}
+}
+
+// flow runs Start and the provider consent and returns the callback request.
+func (i *identityTest) flow(t *testing.T, userID string) OAuthCallbackReq {
+	t.Helper()
+
+	start, err := i.service.Start(context.Background(), "fake", userID)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	return OAuthCallbackReq{
+		Provider: "fake",
+		State:    start.State,
+		Code:     i.provider.authorize(start.URL),
+	}
+}
+
+func TestIdentityLinkNestedClaims(t *testing.T) {
+	i := newIdentityTest(t, map[string]any{
+		"user": map[string]any{"user_id": 12345, "email": "linked@example.com"},
+	}, oauth.ClaimMapping{Subject: "user.user_id", Email: "user.email"}, time.Minute)
+
+	resp, err := i.service.Callback(context.Background(), i.flow(t, i.user.ID))
+	if err != nil {
+		t.Fatalf("callback: %v", err)
+	}
+
+	if resp.Identity == nil || resp.Identity.Subject != "12345" || resp.Identity.Email != "linked@example.com" {
+		t.Fatalf("identity %+v, want subject 12345 and email linked@example.com", resp.Identity)
+	}
+	if resp.Identity.UserID != i.user.ID {
+		t.Errorf("identity linked to %s, want %s", resp.Identity.UserID, i.user.ID)
+	}
+}
+
+func TestIdentityPKCE(t *testing.T) {
+	i := newIdentityTest(t, map[string]any{"sub": "1"}, oauth.ClaimMapping{}, time.Minute)
+
+	if _, err := i.service.Callback(context.Background(), i.flow(t, i.user.ID)); err != nil {
+		t.Fatalf("callback: %v", err)
+	}
+
+	if len(i.provider.verifiers) != 1 || i.provider.verifiers[0] == "" {
+		t.Fatalf("provider received verifiers %q, want one", i.provider.verifiers)
+	}
+
+	// The code is only worth something together with the verifier kept in the state.
+	req := i.flow(t, i.user.ID)
+	for _, state := range i.identities.states {
+		state.Verifier = "wrong-verifier-wrong-verifier-wrong-verifier"
+		i.identities.states[state.Token] = state
+	}
+	if _, err := i.service.Callback(context.Background(), req); !errors.Is(err, domain.ErrProviderAuth) {
+		t.Errorf("callback with a wrong verifier: got %v, want %v", err, domain.ErrProviderAuth)
+	}
+}
+
+func TestIdentityStateSingleUse(t *testing.T) {
+	i := newIdentityTest(t, map[string]any{"sub": "1"}, oauth.ClaimMapping{}, time.Minute)
+
+	req := i.flow(t, i.user.ID)
+	if _, err := i.service.Callback(context.Background(), req); err != nil {
+		t.Fatalf("callback: %v", err)
+	}
+
+	if _, err := i.service.Callback(context.Background(), req); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("replayed callback: got %v, want %v", err, domain.ErrInvalidToken)
+	}
+}
+
+func TestIdentityStateExpiry(t *testing.T) {
+	i := newIdentityTest(t, map[string]any{"sub": "1"}, oauth.ClaimMapping{}, -time.Second)
+
+	if _, err := i.service.Callback(context.Background(), i.flow(t, i.user.ID)); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("callback with an expired state: got %v, want %v", err, domain.ErrInvalidToken)
+	}
+	if len(i.provider.verifiers) != 0 {
+		t.Errorf("code of an expired state was exchanged")
+	}
+}
+
+func TestIdentityStateOfOtherProvider(t *testing.T) {
+	i := newIdentityTest(t, map[string]any{"sub": "1"}, oauth.ClaimMapping{}, time.Minute)
+
+	req := i.flow(t, i.user.ID)
+	for token, state := range i.identities.states {
+		state.Provider = "other"
+		i.identities.states[token] = state
+	}
+
+	if _, err := i.service.Callback(context.Background(), req); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("callback with the state of another provider: got %v, want %v", err, domain.ErrInvalidToken)
+	}
+}
+
+func TestIdentityEmailTakenIsNotTakenOver(t *testing.T) {
+	i := newIdentityTest(t, map[string]any{
+		"sub":            "attacker",
+		"email":          "user@example.com",
+		"email_verified": true,
+	}, oauth.ClaimMapping{}, time.Minute)
+
+	_, err := i.service.Callback(context.Background(), i.flow(t, ""))
+	if !errors.Is(err, domain.ErrIdentityEmailTaken) {
+		t.Fatalf("login with the email of an account: got %v, want %v", err, domain.ErrIdentityEmailTaken)
+	}
+
+	if len(i.identities.identities) != 0 || len(i.identities.users) != 0 {
+		t.Errorf("identities %+v and users %+v created, want none", i.identities.identities, i.identities.users)
+	}
+}
+
+func TestIdentityUnlinkLastLoginMethod(t *testing.T) {
+	i := newIdentityTest(t, map[string]any{"sub": "1"}, oauth.ClaimMapping{}, time.Minute)
+
+	if _, err := i.service.Callback(context.Background(), i.flow(t, i.user.ID)); err != nil {
+		t.Fatalf("callback: %v", err)
+	}
+
+	i.users.loginMethods[i.user.ID] = 1
+	if err := i.service.Unlink(context.Background(), i.user.ID, "fake"); !errors.Is(err, domain.ErrLastLoginMethod) {
+		t.Fatalf("unlink of the only login method: got %v, want %v", err, domain.ErrLastLoginMethod)
+	}
+
+	i.users.loginMethods[i.user.ID] = 2
+	if err := i.service.Unlink(context.Background(), i.user.ID, "fake"); err != nil {
+		t.Fatalf("unlink: %v", err)
+	}
+
+	if identities, _ := i.identities.ListByUserID(context.Background(), i.user.ID); len(identities) != 0 {
+		t.Errorf("identities %+v left, want none", identities)
+	}
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/repository"
+	"github.com/kcthack-auth/pkg/auth"
+)
+
+// IntrospectionService tells downstream services whether a token is still valid (RFC 7662)
+// and revokes tokens (RFC 7009). Unlike a signature check it takes logouts and revocations into account.
+type IntrospectionService struct {
+	srepo repository.SessionRepository
+	drepo repository.DenylistRepository
+	orepo repository.OIDCRepository
+	mrepo repository.MachineClientRepository
+	tm    auth.TokenValidator
+}
+
+func NewIntrospectionService(srepo repository.SessionRepository, drepo repository.DenylistRepository, orepo repository.OIDCRepository, mrepo repository.MachineClientRepository, tm auth.TokenValidator) *IntrospectionService {
+	return &IntrospectionService{
+		srepo: srepo,
+		drepo: drepo,
+		orepo: orepo,
+		mrepo: mrepo,
+		tm:    tm,
+	}
+}
+
+// Introspect returns the state of an access or refresh token. Only confidential OpenID Connect
+// clients and machine clients may introspect, so tokens cannot be probed anonymously.
+func (i *IntrospectionService) Introspect(ctx context.Context, req TokenIntrospectionReq) (map[string]any, error) {
+	if _, err := i.authenticateClient(ctx, req.ClientID, req.ClientSecret, false); err != nil {
+		return nil, err
+	}
+
+	if req.Token == "" {
+		return nil, &domain.OAuthError{Code: domain.OAuthInvalidRequest, Description: "token is required"}
+	}
+
+	inactive := map[string]any{"active": false}
+
+	if claims, err := i.tm.Validate(ctx, req.Token); err == nil {
+		active, err := i.Active(ctx, claims)
+		if err != nil {
+			return nil, err
+		}
+
+		if !active {
+			return inactive, nil
+		}
+
+		return accessTokenIntrospection(claims), nil
+	}
+
+	session, err := i.srepo.FindByToken(ctx, req.Token)
+	if errors.Is(err, domain.ErrSessionNotFound) {
+		return inactive, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find session: %w", err)
+	}
+
+	if session.RotatedAt != nil || session.ExpiresAt.Before(time.Now()) {
+		return inactive, nil
+	}
+
+	return map[string]any{
+		"active":     true,
+		"token_type": "refresh_token",
+		"sub":        session.UserID,
+		"sub_type":   auth.SubjectUser,
+		"exp":        session.ExpiresAt.Unix(),
+	}, nil
+}
+
+// Revoke revokes an access or refresh token. Revoking a refresh token ends the whole session,
+// so the access tokens issued for it become inactive as well. Tokens issued to a client can
+// only be revoked by that client, tokens of the user's own sessions by anyone holding them.
+// Unknown and foreign tokens are ignored, as RFC 7009 requires.
+func (i *IntrospectionService) Revoke(ctx context.Context, req TokenIntrospectionReq) error {
+	var clientID string
+	if req.ClientID != "" || req.ClientSecret != "" {
+		var err error
+		clientID, err = i.authenticateClient(ctx, req.ClientID, req.ClientSecret, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	if req.Token == "" {
+		return &domain.OAuthError{Code: domain.OAuthInvalidRequest, Description: "token is required"}
+	}
+
+	if claims, err := i.tm.Validate(ctx, req.Token); err == nil {
+		// Tokens issued before jti was introduced cannot be denied, they expire soon anyway.
+		if claims.ClientID != clientID || claims.ID == "" {
+			return nil
+		}
+
+		if err := i.drepo.Add(ctx, claims.ID, time.Unix(int64(claims.ExpAt), 0)); err != nil {
+			return fmt.Errorf("failed to revoke access token: %w", err)
+		}
+
+		return nil
+	}
+
+	session, err := i.srepo.FindByToken(ctx, req.Token)
+	if errors.Is(err, domain.ErrSessionNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find session: %w", err)
+	}
+
+	if err := i.srepo.DeleteFamily(ctx, session.FamilyID); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	return nil
+}
+
+// Active checks a token with a valid signature against the denylist, the session it was issued for
+// and the state of its machine client. It implements auth.RevocationChecker.
+func (i *IntrospectionService) Active(ctx context.Context, claims *auth.TokenClaims) (bool, error) {
+	if claims.ID != "" {
+		denied, err := i.drepo.Contains(ctx, claims.ID)
+		if err != nil {
+			return false, fmt.Errorf("failed to check token denylist: %w", err)
+		}
+
+		if denied {
+			return false, nil
+		}
+	}
+
+	if claims.SessionID != "" {
+		active, err := i.srepo.FamilyActive(ctx, claims.SessionID)
+		if err != nil {
+			return false, fmt.Errorf("failed to check session: %w", err)
+		}
+
+		if !active {
+			return false, nil
+		}
+	}
+
+	if claims.SubjectType == auth.SubjectClient {
+		client, err := i.mrepo.Find(ctx, claims.ClientID)
+		if errors.Is(err, domain.ErrClientNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to find machine client: %w", err)
+		}
+
+		if client.RevokedAt != nil {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// authenticateClient accepts the credentials of machine clients and OpenID Connect clients
+// and returns the client id. Public clients are only accepted if allowPublic is set.
+func (i *IntrospectionService) authenticateClient(ctx context.Context, id, secret string, allowPublic bool) (string, error) {
+	invalidClient := &domain.OAuthError{Code: domain.OAuthInvalidClient, Description: "client authentication failed"}
+
+	if uuid.Validate(id) != nil {
+		return "", invalidClient
+	}
+
+	if secret != "" {
+		matches, err := i.mrepo.SecretMatches(ctx, id, secret)
+		if err != nil {
+			return "", fmt.Errorf("failed to check client secret: %w", err)
+		}
+
+		if matches {
+			return id, nil
+		}
+	}
+
+	client, err := i.orepo.FindClient(ctx, id)
+	if errors.Is(err, domain.ErrClientNotFound) {
+		return "", invalidClient
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to find client: %w", err)
+	}
+
+	if client.Public {
+		if allowPublic && secret == "" {
+			return client.ID, nil
+		}
+		return "", invalidClient
+	}
+
+	matches, err := i.orepo.ClientSecretMatches(ctx, client.ID, secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to check client secret: %w", err)
+	}
+
+	if secret == "" || !matches {
+		return "", invalidClient
+	}
+
+	return client.ID, nil
+}
+
+func accessTokenIntrospection(claims *auth.TokenClaims) map[string]any {
+	resp := map[string]any{
+		"active":     true,
+		"token_type": "Bearer",
+		"sub_type":   claims.SubjectType,
+		"exp":        int64(claims.ExpAt),
+	}
+	if claims.ID != "" {
+		resp["jti"] = claims.ID
+	}
+	if claims.ClientID != "" {
+		resp["client_id"] = claims.ClientID
+		resp["scope"] = claims.Scope
+	}
+
+	if claims.SubjectType == auth.SubjectClient {
+		resp["sub"] = auth.SubjectClient + ":" + claims.ClientID
+		return resp
+	}
+
+	resp["sub"] = claims.UserID
+	resp["role"] = claims.Role
+	resp["roles"] = claims.Roles
+	resp["verified"] = claims.Verified
+
+	return resp
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/repository"
+	"github.com/kcthack-auth/pkg/auth"
+	"github.com/kcthack-auth/pkg/logger"
+)
+
+// KeyService keeps the signing key ring of every replica in sync with the jwt_keys table.
+type KeyService struct {
+	krepo            repository.KeyRepository
+	ring             *auth.KeyRing
+	algorithm        string
+	propagationDelay time.Duration
+	overlap          time.Duration
+}
+
+// NewKeyService creates the service. New keys become active after propagationDelay, which must be
+// longer than the reload interval so that every replica knows a key before it is used.
+// Replaced keys keep validating tokens for overlap after that, which must cover the access token TTL.
+func NewKeyService(krepo repository.KeyRepository, ring *auth.KeyRing, algorithm string, propagationDelay, overlap time.Duration) *KeyService {
+	return &KeyService{
+		krepo:            krepo,
+		ring:             ring,
+		algorithm:        algorithm,
+		propagationDelay: propagationDelay,
+		overlap:          overlap,
+	}
+}
+
+// Init encrypts keys stored in plaintext, stores the bootstrap key if the table is empty and loads
+// the ring. Once the table has keys they are the source of truth and the bootstrap key is only
+// compared with them, so a changed configuration is reported instead of silently ignored.
+func (k *KeyService) Init(ctx context.Context, bootstrap *auth.Key) error {
+	sealed, err := k.krepo.SealPlaintext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt stored signing keys: %w", err)
+	}
+	if sealed > 0 {
+		slog.InfoContext(ctx, "encrypted signing keys stored in plaintext", slog.Int("count", sealed))
+	}
+
+	keys, err := k.krepo.ListValid(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list signing keys: %w", err)
+	}
+
+	alg, material, err := bootstrap.Export()
+	if err != nil {
+		return err
+	}
+
+	if len(keys) > 0 {
+		k.compareBootstrap(ctx, keys, bootstrap.ID, material)
+		return k.Reload(ctx)
+	}
+
+	if err := k.krepo.Create(ctx, &domain.SigningKey{
+		ID:          bootstrap.ID,
+		Algorithm:   alg,
+		Material:    material,
+		ActivatesAt: time.Now(),
+	}); err != nil {
+		return fmt.Errorf("failed to save bootstrap signing key: %w", err)
+	}
+
+	return k.Reload(ctx)
+}
+
+func (k *KeyService) compareBootstrap(ctx context.Context, keys []domain.SigningKey, id string, material []byte) {
+	for _, key := range keys {
+		if key.ID != id {
+			continue
+		}
+
+		if !bytes.Equal(key.Material, material) {
+			slog.WarnContext(ctx, "configured signing key differs from the stored key with the same id, the stored key is used", slog.String("kid", id))
+		}
+		return
+	}
+
+	slog.WarnContext(ctx, "configured signing key is not used, signing keys are managed in jwt_keys", slog.String("kid", id))
+}
+
+func (k *KeyService) Reload(ctx context.Context) error {
+	stored, err := k.krepo.ListValid(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list signing keys: %w", err)
+	}
+
+	keys := make([]*auth.Key, 0, len(stored))
+	for _, s := range stored {
+		key, err := auth.ImportKey(s.ID, s.Algorithm, s.Material)
+		if err != nil {
+			return fmt.Errorf("failed to import signing key %s: %w", s.ID, err)
+		}
+
+		key.NotBefore = s.ActivatesAt
+		if s.RetiresAt != nil {
+			key.NotAfter = *s.RetiresAt
+		}
+		keys = append(keys, key)
+	}
+
+	k.ring.Set(keys)
+	return nil
+}
+
+// RunReloader reloads the ring every interval until ctx is done.
+func (k *KeyService) RunReloader(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := k.Reload(ctx); err != nil {
+				slog.ErrorContext(ctx, "failed to reload signing keys", logger.Err(err))
+			}
+		}
+	}
+}
+
+// Rotate generates a new key that becomes active after the propagation delay and schedules
+// the retirement of the current keys once tokens signed by them have expired.
+func (k *KeyService) Rotate(ctx context.Context, algorithm string) (*domain.SigningKey, error) {
+	if algorithm == "" {
+		algorithm = k.algorithm
+	}
+
+	id := fmt.Sprintf("%s-%s", time.Now().Format("20060102"), uuid.NewString()[:8])
+
+	key, err := auth.GenerateKey(id, algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrValidation, err)
+	}
+
+	alg, material, err := key.Export()
+	if err != nil {
+		return nil, err
+	}
+
+	stored := domain.SigningKey{
+		ID:          id,
+		Algorithm:   alg,
+		Material:    material,
+		ActivatesAt: time.Now().Add(k.propagationDelay),
+		CreatedAt:   time.Now(),
+	}
+
+	if err := k.krepo.Rotate(ctx, &stored, stored.ActivatesAt.Add(k.overlap)); err != nil {
+		return nil, fmt.Errorf("failed to rotate signing key: %w", err)
+	}
+
+	if err := k.Reload(ctx); err != nil {
+		return nil, err
+	}
+
+	return &stored, nil
+}
+
+func (k *KeyService) List(ctx context.Context) ([]domain.SigningKey, error) {
+	keys, err := k.krepo.ListValid(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %w", err)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,290 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/repository"
+	"github.com/kcthack-auth/pkg/logger"
+	"github.com/kcthack-auth/pkg/mail"
+)
+
+// LockoutNotifier is told when an account gets locked, e.g. to warn the owner.
+type LockoutNotifier interface {
+	AccountLocked(ctx context.Context, user *domain.User, until time.Time) error
+}
+
+// MailLockoutNotifier warns the owner of a locked account by email.
+type MailLockoutNotifier struct {
+	sender mail.Sender
+}
+
+func NewMailLockoutNotifier(sender mail.Sender) *MailLockoutNotifier {
+	return &MailLockoutNotifier{sender: sender}
+}
+
+func (m *MailLockoutNotifier) AccountLocked(ctx context.Context, user *domain.User, until time.Time) error {
+	return m.sender.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Вход в аккаунт КЦТHack временно заблокирован",
+		Body:    fmt.Sprintf("Из-за большого числа неудачных попыток входа вход в аккаунт заблокирован до %s. Если это были не вы, смените пароль.", until.Format("02.01.2006 15:04 MST")),
+	})
+}
+
+// LockoutService slows down password guessing. Every failed login of an account delays the next
+// attempt progressively, after AccountThreshold failures the account is locked for LockoutDuration.
+// IP addresses are only locked after IPThreshold failures, since many users may share one.
+type LockoutService struct {
+	repo     repository.AuthRepository
+	lrepo    repository.LoginAttemptRepository
+	notifier LockoutNotifier
+	cfg      LockoutConfig
+}
+
+func NewLockoutService(repo repository.AuthRepository, lrepo repository.LoginAttemptRepository, notifier LockoutNotifier, cfg LockoutConfig) *LockoutService {
+	return &LockoutService{
+		repo:     repo,
+		lrepo:    lrepo,
+		notifier: notifier,
+		cfg:      cfg,
+	}
+}
+
+// LoginAttempt is a login reserved by Attempt or a code check reserved by CodeAttempt.
+// It has to be finished with Success or Failure.
+type LoginAttempt struct {
+	key      string
+	email    string
+	userID   string
+	ip       string
+	attempts int
+	until    time.Time
+}
+
+// Attempt reserves a login of the account from the IP address before the password is checked.
+// The attempt is counted and the next one delayed right away, in the same step that checks the
+// lock, so parallel requests cannot all pass the check before any failure is written.
+// It returns a *domain.LockoutError if logins of the account or the IP address are locked.
+// Unknown emails are counted too, so they cannot be told apart.
+func (l *LockoutService) Attempt(ctx context.Context, email, ip string) (*LoginAttempt, error) {
+	attempt := &LoginAttempt{key: accountKey(email), email: email, ip: ip}
+
+	attempts, until, err := l.reserve(ctx, attempt.key, l.accountLock)
+	if err != nil {
+		return nil, err
+	}
+	attempt.attempts, attempt.until = attempts, until
+
+	if ip == "" {
+		return attempt, nil
+	}
+
+	attempts, _, err = l.reserve(ctx, ipKey(ip), func(attempts int) time.Duration {
+		if attempts >= l.cfg.IPThreshold {
+			return l.cfg.LockoutDuration
+		}
+		return 0
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if attempts == l.cfg.IPThreshold {
+		slog.WarnContext(ctx, "logins from ip address locked", slog.String("ip", ip), slog.Int("attempts", attempts))
+	}
+
+	return attempt, nil
+}
+
+// CodeAttempt reserves a check of a second factor code of the user. Code checks are counted
+// apart from logins and limited like the logins of an account, so a known password does not
+// allow guessing the six digits of a TOTP code.
+func (l *LockoutService) CodeAttempt(ctx context.Context, userID string) (*LoginAttempt, error) {
+	attempt := &LoginAttempt{key: codeKey(userID), userID: userID}
+
+	attempts, until, err := l.reserve(ctx, attempt.key, l.accountLock)
+	if err != nil {
+		return nil, err
+	}
+	attempt.attempts, attempt.until = attempts, until
+
+	return attempt, nil
+}
+
+// accountLock delays the next attempt progressively and locks the account at AccountThreshold.
+func (l *LockoutService) accountLock(attempts int) time.Duration {
+	if attempts >= l.cfg.AccountThreshold {
+		return l.cfg.LockoutDuration
+	}
+
+	return l.delay(attempts)
+}
+
+// reserve counts an attempt of the key unless it is locked and locks the key for lockFor of the
+// new count. Counts older than Window start over. It returns the count and the end of the lock.
+func (l *LockoutService) reserve(ctx context.Context, key string, lockFor func(attempts int) time.Duration) (int, time.Time, error) {
+	var (
+		locked *time.Time
+		result domain.LoginAttempts
+	)
+	now := time.Now()
+	err := l.lrepo.Update(ctx, key, func(attempts *domain.LoginAttempts) {
+		if attempts.LockedUntil != nil && attempts.LockedUntil.After(now) {
+			locked = attempts.LockedUntil
+			return
+		}
+
+		if attempts.LastAttemptAt.Before(now.Add(-l.cfg.Window)) {
+			attempts.Attempts = 0
+		}
+
+		attempts.Attempts++
+		attempts.LastAttemptAt = now
+		attempts.LockedUntil = nil
+		if d := lockFor(attempts.Attempts); d > 0 {
+			until := now.Add(d)
+			attempts.LockedUntil = &until
+		}
+		result = *attempts
+	})
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to count login attempt: %w", err)
+	}
+
+	if locked != nil {
+		return 0, time.Time{}, &domain.LockoutError{Until: *locked}
+	}
+
+	var until time.Time
+	if result.LockedUntil != nil {
+		until = *result.LockedUntil
+	}
+
+	return result.Attempts, until, nil
+}
+
+// Failure finishes a failed attempt. The attempt is already counted, the owner is only warned
+// when it was the one that locked the account.
+func (l *LockoutService) Failure(ctx context.Context, attempt *LoginAttempt) {
+	if attempt.attempts == l.cfg.AccountThreshold {
+		l.notify(ctx, attempt, attempt.until)
+	}
+}
+
+// Success finishes a valid attempt: the counter of the account or code checks is reset and the
+// attempt is given back to the IP address. The rest of the IP counter is kept, one valid login must not hide
+// guessing against other accounts.
+func (l *LockoutService) Success(ctx context.Context, attempt *LoginAttempt) error {
+	if err := l.lrepo.Reset(ctx, attempt.key); err != nil {
+		return fmt.Errorf("failed to reset login attempts: %w", err)
+	}
+
+	if attempt.ip == "" {
+		return nil
+	}
+
+	err := l.lrepo.Update(ctx, ipKey(attempt.ip), func(attempts *domain.LoginAttempts) {
+		if attempts.Attempts > 0 {
+			attempts.Attempts--
+		}
+		if attempts.Attempts < l.cfg.IPThreshold {
+			attempts.LockedUntil = nil
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release login attempt: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpired drops the counters that are past the window and not locked.
+func (l *LockoutService) DeleteExpired(ctx context.Context) error {
+	return l.lrepo.DeleteExpired(ctx, time.Now().Add(-l.cfg.Window))
+}
+
+// Unlock lifts the lockout of the user's account and code checks.
+func (l *LockoutService) Unlock(ctx context.Context, userID string) error {
+	user, err := l.repo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	keys := []string{codeKey(user.ID)}
+	if user.Email != "" {
+		keys = append(keys, accountKey(user.Email))
+	}
+
+	for _, key := range keys {
+		if err := l.lrepo.Reset(ctx, key); err != nil {
+			return fmt.Errorf("failed to reset login attempts: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// delay is the wait before the next attempt after the given number of attempts,
+// doubling from BaseDelay up to MaxDelay.
+func (l *LockoutService) delay(attempts int) time.Duration {
+	if l.cfg.BaseDelay <= 0 || attempts <= 0 {
+		return 0
+	}
+
+	delay := l.cfg.BaseDelay
+	for i := 1; i < attempts && delay < l.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, l.cfg.MaxDelay)
+}
+
+func (l *LockoutService) notify(ctx context.Context, attempt *LoginAttempt, until time.Time) {
+	var (
+		user *domain.User
+		err  error
+	)
+	if attempt.userID != "" {
+		user, err = l.repo.FindByID(ctx, attempt.userID)
+	} else {
+		user, err = l.repo.FindByEmail(ctx, attempt.email)
+	}
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return
+	}
+
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to find locked user", slog.String("email_sha256", emailHash(attempt.email)), slog.String("user_id", attempt.userID), logger.Err(err))
+		return
+	}
+
+	// The lockout is already in place, a failed notification must not fail the login request.
+	if err := l.notifier.AccountLocked(ctx, user, until); err != nil {
+		slog.ErrorContext(ctx, "failed to notify user about lockout", slog.String("user_id", user.ID), logger.Err(err))
+	}
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func codeKey(userID string) string {
+	return "code:" + userID
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// emailHash identifies an email in logs without revealing it.
+func emailHash(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(sum[:8])
+}
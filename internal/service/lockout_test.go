@@ -0,0 +1,262 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/repository"
+)
+
+type lockoutNotifications struct {
+	locked []string
+}
+
+func (n *lockoutNotifications) AccountLocked(ctx context.Context, user *domain.User, until time.Time) error {
+	n.locked = append(n.locked, user.ID)
+	return nil
+}
+
+func newTestLockout(cfg LockoutConfig) (*LockoutService, *lockoutNotifications) {
+	users := &fakeUsers{users: map[string]*domain.User{
+		"1": {ID: "1", Email: "user@example.com"},
+	}}
+	notifier := &lockoutNotifications{}
+
+	return NewLockoutService(users, repository.NewMemoryLoginAttemptRepo(), notifier, cfg), notifier
+}
+
+func failLogin(t *testing.T, l *LockoutService, email, ip string) error {
+	t.Helper()
+
+	attempt, err := l.Attempt(context.Background(), email, ip)
+	if err != nil {
+		return err
+	}
+	l.Failure(context.Background(), attempt)
+
+	return nil
+}
+
+func TestLockoutDelay(t *testing.T) {
+	l, _ := newTestLockout(LockoutConfig{
+		AccountThreshold: 10,
+		IPThreshold:      100,
+		Window:           time.Hour,
+		LockoutDuration:  time.Hour,
+		BaseDelay:        time.Minute,
+		MaxDelay:         3 * time.Minute,
+	})
+
+	if err := failLogin(t, l, "user@example.com", "10.0.0.1"); err != nil {
+		t.Fatalf("first attempt: %v", err)
+	}
+
+	start := time.Now()
+	err := failLogin(t, l, "user@example.com", "10.0.0.1")
+
+	var lockout *domain.LockoutError
+	if !errors.As(err, &lockout) || !errors.Is(err, domain.ErrLoginLocked) {
+		t.Fatalf("second attempt: got %v, want a lockout", err)
+	}
+	if wait := lockout.Until.Sub(start); wait <= 0 || wait > time.Minute {
+		t.Errorf("second attempt delayed by %s, want up to %s", wait, time.Minute)
+	}
+
+	for attempts, want := range map[int]time.Duration{1: time.Minute, 2: 2 * time.Minute, 3: 3 * time.Minute, 5: 3 * time.Minute} {
+		if got := l.delay(attempts); got != want {
+			t.Errorf("delay(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func TestLockoutThreshold(t *testing.T) {
+	l, notifier := newTestLockout(LockoutConfig{
+		AccountThreshold: 3,
+		IPThreshold:      100,
+		Window:           time.Hour,
+		LockoutDuration:  time.Hour,
+	})
+
+	for i := range 3 {
+		if err := failLogin(t, l, "user@example.com", "10.0.0.1"); err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+	}
+
+	start := time.Now()
+	err := failLogin(t, l, "user@example.com", "10.0.0.2")
+
+	var lockout *domain.LockoutError
+	if !errors.As(err, &lockout) {
+		t.Fatalf("attempt after the threshold: got %v, want a lockout", err)
+	}
+	if wait := lockout.Until.Sub(start); wait < 59*time.Minute {
+		t.Errorf("account locked for %s, want %s", wait, time.Hour)
+	}
+
+	if len(notifier.locked) != 1 || notifier.locked[0] != "1" {
+		t.Errorf("notified %v, want the owner once", notifier.locked)
+	}
+
+	if err := failLogin(t, l, "other@example.com", "10.0.0.1"); err != nil {
+		t.Errorf("other account from the same ip: %v", err)
+	}
+}
+
+func TestLockoutThresholdConcurrent(t *testing.T) {
+	l, _ := newTestLockout(LockoutConfig{
+		AccountThreshold: 3,
+		IPThreshold:      100,
+		Window:           time.Hour,
+		LockoutDuration:  time.Hour,
+	})
+
+	attempts := make(chan error, 20)
+	for range cap(attempts) {
+		go func() {
+			_, err := l.Attempt(context.Background(), "user@example.com", "10.0.0.1")
+			attempts <- err
+		}()
+	}
+
+	allowed := 0
+	for range cap(attempts) {
+		if err := <-attempts; err == nil {
+			allowed++
+		} else if !errors.Is(err, domain.ErrLoginLocked) {
+			t.Fatalf("attempt: %v", err)
+		}
+	}
+
+	if allowed != 3 {
+		t.Errorf("%d parallel attempts allowed, want 3", allowed)
+	}
+}
+
+func TestLockoutIPThreshold(t *testing.T) {
+	l, _ := newTestLockout(LockoutConfig{
+		AccountThreshold: 100,
+		IPThreshold:      2,
+		Window:           time.Hour,
+		LockoutDuration:  time.Hour,
+	})
+
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if err := failLogin(t, l, email, "10.0.0.1"); err != nil {
+			t.Fatalf("attempt for %s: %v", email, err)
+		}
+	}
+
+	if err := failLogin(t, l, "c@example.com", "10.0.0.1"); !errors.Is(err, domain.ErrLoginLocked) {
+		t.Errorf("attempt after the ip threshold: got %v, want a lockout", err)
+	}
+}
+
+func TestLockoutWindowReset(t *testing.T) {
+	l, _ := newTestLockout(LockoutConfig{
+		AccountThreshold: 3,
+		IPThreshold:      100,
+		Window:           50 * time.Millisecond,
+		LockoutDuration:  time.Hour,
+	})
+
+	for i := range 2 {
+		if err := failLogin(t, l, "user@example.com", "10.0.0.1"); err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	// The old attempts are forgotten, so two more do not reach the threshold.
+	for i := range 2 {
+		if err := failLogin(t, l, "user@example.com", "10.0.0.1"); err != nil {
+			t.Fatalf("attempt %d after the window: %v", i+1, err)
+		}
+	}
+}
+
+func TestLockoutSuccess(t *testing.T) {
+	l, _ := newTestLockout(LockoutConfig{
+		AccountThreshold: 2,
+		IPThreshold:      2,
+		Window:           time.Hour,
+		LockoutDuration:  time.Hour,
+	})
+
+	for i := range 5 {
+		attempt, err := l.Attempt(context.Background(), "user@example.com", "10.0.0.1")
+		if err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+
+		if err := l.Success(context.Background(), attempt); err != nil {
+			t.Fatalf("success %d: %v", i+1, err)
+		}
+	}
+}
+
+func TestLockoutUnlock(t *testing.T) {
+	l, _ := newTestLockout(LockoutConfig{
+		AccountThreshold: 1,
+		IPThreshold:      100,
+		Window:           time.Hour,
+		LockoutDuration:  time.Hour,
+	})
+
+	if err := failLogin(t, l, "user@example.com", "10.0.0.1"); err != nil {
+		t.Fatalf("first attempt: %v", err)
+	}
+
+	if err := failLogin(t, l, "user@example.com", "10.0.0.1"); !errors.Is(err, domain.ErrLoginLocked) {
+		t.Fatalf("locked attempt: got %v, want a lockout", err)
+	}
+
+	if err := l.Unlock(context.Background(), "1"); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+
+	if err := failLogin(t, l, "user@example.com", "10.0.0.1"); err != nil {
+		t.Errorf("attempt after unlock: %v", err)
+	}
+}
+
+func TestLockoutCodeAttempts(t *testing.T) {
+	l, notifier := newTestLockout(LockoutConfig{
+		AccountThreshold: 2,
+		IPThreshold:      100,
+		Window:           time.Hour,
+		LockoutDuration:  time.Hour,
+	})
+
+	for i := range 2 {
+		attempt, err := l.CodeAttempt(context.Background(), "1")
+		if err != nil {
+			t.Fatalf("code attempt %d: %v", i+1, err)
+		}
+		l.Failure(context.Background(), attempt)
+	}
+
+	if _, err := l.CodeAttempt(context.Background(), "1"); !errors.Is(err, domain.ErrLoginLocked) {
+		t.Fatalf("code attempt after the threshold: got %v, want a lockout", err)
+	}
+	if len(notifier.locked) != 1 || notifier.locked[0] != "1" {
+		t.Errorf("notified %v, want the owner once", notifier.locked)
+	}
+
+	// Code checks are counted apart from password logins of the account.
+	if err := failLogin(t, l, "user@example.com", "10.0.0.1"); err != nil {
+		t.Errorf("login while code checks are locked: %v", err)
+	}
+
+	if err := l.Unlock(context.Background(), "1"); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+
+	if _, err := l.CodeAttempt(context.Background(), "1"); err != nil {
+		t.Errorf("code attempt after unlock: %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/repository"
+	"github.com/kcthack-auth/pkg/auth"
+)
+
+// Scopes of machine clients are defined by the services consuming them, e.g. "scores:write".
+var machineScopeRegexp = regexp.MustCompile(`^[a-z][a-z0-9_.:-]{0,63}$`)
+
+// MachineClientService manages internal services authenticating with the client credentials grant.
+type MachineClientService struct {
+	mrepo       repository.MachineClientRepository
+	tm          auth.JWTManager
+	tokenTTL    time.Duration
+	gracePeriod time.Duration
+}
+
+// NewMachineClientService creates the service. Rotated secrets stay valid for gracePeriod,
+// so the client can be redeployed with the new secret without downtime.
+func NewMachineClientService(mrepo repository.MachineClientRepository, tm auth.JWTManager, tokenTTL, gracePeriod time.Duration) *MachineClientService {
+	return &MachineClientService{
+		mrepo:       mrepo,
+		tm:          tm,
+		tokenTTL:    tokenTTL,
+		gracePeriod: gracePeriod,
+	}
+}
+
+// Create registers a client and returns it with its first secret.
+func (m *MachineClientService) Create(ctx context.Context, name string, scopes []string) (*domain.MachineClient, *domain.MachineClientSecret, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, nil, fmt.Errorf("%w: name cannot be empty", domain.ErrValidation)
+	}
+
+	for _, scope := range scopes {
+		if !machineScopeRegexp.MatchString(scope) {
+			return nil, nil, fmt.Errorf("%w: invalid scope %q", domain.ErrValidation, scope)
+		}
+	}
+
+	client := domain.MachineClient{
+		ID:        uuid.NewString(),
+		Name:      name,
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+
+	secret, err := m.newSecret(client.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := m.mrepo.Create(ctx, &client, secret); err != nil {
+		return nil, nil, fmt.Errorf("failed to create machine client: %w", err)
+	}
+
+	return &client, secret, nil
+}
+
+func (m *MachineClientService) List(ctx context.Context) ([]domain.MachineClient, error) {
+	clients, err := m.mrepo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list machine clients: %w", err)
+	}
+
+	return clients, nil
+}
+
+// Get returns the client with its valid secrets.
+func (m *MachineClientService) Get(ctx context.Context, id string) (*domain.MachineClient, []domain.MachineClientSecret, error) {
+	if err := uuid.Validate(id); err != nil {
+		return nil, nil, domain.ErrClientNotFound
+	}
+
+	client, err := m.mrepo.Find(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secrets, err := m.mrepo.ListSecrets(ctx, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list client secrets: %w", err)
+	}
+
+	return client, secrets, nil
+}
+
+// RotateSecret issues a new secret. The previous secrets expire after the grace period,
+// or immediately if revokeOld is set, e.g. because a secret leaked.
+func (m *MachineClientService) RotateSecret(ctx context.Context, id string, revokeOld bool) (*domain.MachineClientSecret, error) {
+	if err := uuid.Validate(id); err != nil {
+		return nil, domain.ErrClientNotFound
+	}
+
+	client, err := m.mrepo.Find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if client.RevokedAt != nil {
+		return nil, domain.ErrClientRevoked
+	}
+
+	secret, err := m.newSecret(client.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	oldExpireAt := time.Now().Add(m.gracePeriod)
+	if revokeOld {
+		oldExpireAt = time.Now()
+	}
+
+	if err := m.mrepo.RotateSecret(ctx, secret, oldExpireAt); err != nil {
+		return nil, fmt.Errorf("failed to rotate client secret: %w", err)
+	}
+
+	return secret, nil
+}
+
+// Revoke disables the client for good. Tokens issued before pass signature checks until they expire,
+// but introspection reports them inactive.
+func (m *MachineClientService) Revoke(ctx context.Context, id string) error {
+	if err := uuid.Validate(id); err != nil {
+		return domain.ErrClientNotFound
+	}
+
+	return m.mrepo.Revoke(ctx, id)
+}
+
+// Token implements the client credentials grant. An empty scope requests every scope of the client.
+func (m *MachineClientService) Token(ctx context.Context, clientID, clientSecret, scope string) (*TokenResp, error) {
+	invalidClient := &domain.OAuthError{Code: domain.OAuthInvalidClient, Description: "client authentication failed"}
+
+	if uuid.Validate(clientID) != nil || clientSecret == "" {
+		return nil, invalidClient
+	}
+
+	matches, err := m.mrepo.SecretMatches(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check client secret: %w", err)
+	}
+
+	if !matches {
+		return nil, invalidClient
+	}
+
+	client, err := m.mrepo.Find(ctx, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find machine client: %w", err)
+	}
+
+	scopes := strings.Fields(scope)
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+	for _, s := range scopes {
+		if !slices.Contains(client.Scopes, s) {
+			return nil, &domain.OAuthError{Code: domain.OAuthInvalidScope, Description: "scope " + s + " is not allowed for the client"}
+		}
+	}
+
+	accessToken, err := m.tm.NewAccess(auth.TokenClaims{
+		SubjectType: auth.SubjectClient,
+		ClientID:    client.ID,
+		Scope:       strings.Join(scopes, " "),
+	}, m.tokenTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	return &TokenResp{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(m.tokenTTL.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	}, nil
+}
+
+func (m *MachineClientService) newSecret(clientID string) (*domain.MachineClientSecret, error) {
+	secret, err := newOneTimeToken()
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.MachineClientSecret{
+		ID:        uuid.NewString(),
+		ClientID:  clientID,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}, nil
+}
@@ -0,0 +1,352 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/repository"
+	"github.com/kcthack-auth/pkg/hasher"
+	"github.com/kcthack-auth/pkg/totp"
+)
+
+const (
+	recoveryCodesCount   = 10
+	maxChallengeAttempts = 5
+	totpSkew             = 1
+)
+
+type MFAService struct {
+	repo          repository.AuthRepository
+	mrepo         repository.MFARepository
+	roles         *RoleService
+	lockout       *LockoutService
+	hasher        hasher.PasswordHasher
+	issuer        string
+	challengeTTL  time.Duration
+	requiredRoles []string
+}
+
+func NewMFAService(repo repository.AuthRepository, mrepo repository.MFARepository, roles *RoleService, lockout *LockoutService, hasher hasher.PasswordHasher, issuer string, challengeTTL time.Duration, requiredRoles []string) *MFAService {
+	return &MFAService{
+		repo:          repo,
+		mrepo:         mrepo,
+		roles:         roles,
+		lockout:       lockout,
+		hasher:        hasher,
+		issuer:        issuer,
+		challengeTTL:  challengeTTL,
+		requiredRoles: requiredRoles,
+	}
+}
+
+func (m *MFAService) Status(ctx context.Context, userID string) (*MFAStatus, error) {
+	user, err := m.repo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var status MFAStatus
+
+	status.Enforced, err = m.enforced(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	status.Enabled, err = m.enabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if status.Enabled {
+		status.RecoveryCodesLeft, err = m.mrepo.CountRecoveryCodes(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count recovery codes: %w", err)
+		}
+	}
+
+	return &status, nil
+}
+
+// Enroll creates a new unconfirmed TOTP secret. It has no effect on login until confirmed.
+func (m *MFAService) Enroll(ctx context.Context, userID string) (*TOTPEnrollment, error) {
+	user, err := m.repo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.mrepo.SaveTOTP(ctx, &domain.TOTP{
+		UserID: user.ID,
+		Secret: secret,
+	}); err != nil {
+		if errors.Is(err, domain.ErrMFAAlreadyEnabled) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to save totp secret: %w", err)
+	}
+
+	return &TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.URI(m.issuer, accountName(user), secret),
+	}, nil
+}
+
+// Confirm enables TOTP once the user proves the authenticator app works and returns fresh recovery codes.
+func (m *MFAService) Confirm(ctx context.Context, userID, code string) ([]string, error) {
+	secret, err := m.mrepo.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if secret.ConfirmedAt != nil {
+		return nil, domain.ErrMFAAlreadyEnabled
+	}
+
+	attempt, err := m.lockout.CodeAttempt(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := totp.Validate(secret.Secret, code, time.Now(), totpSkew)
+	if !ok {
+		m.lockout.Failure(ctx, attempt)
+		return nil, domain.ErrInvalidMFACode
+	}
+
+	if err := m.lockout.Success(ctx, attempt); err != nil {
+		return nil, err
+	}
+
+	if err := m.mrepo.ConfirmTOTP(ctx, userID, step); err != nil {
+		return nil, fmt.Errorf("failed to confirm totp: %w", err)
+	}
+
+	return m.newRecoveryCodes(ctx, userID)
+}
+
+// Disable turns TOTP off. Users whose role requires a second factor cannot disable it.
+func (m *MFAService) Disable(ctx context.Context, userID, password, code string) error {
+	user, err := m.repo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	enforced, err := m.enforced(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	if enforced {
+		return domain.ErrMFAEnforced
+	}
+
+	if match, err := m.hasher.Verify(password, user.PassHash); err != nil || !match {
+		return domain.ErrInvalidCredentials
+	}
+
+	if err := m.Verify(ctx, userID, code); err != nil {
+		return err
+	}
+
+	if err := m.mrepo.DeleteTOTP(ctx, userID); err != nil {
+		return fmt.Errorf("failed to disable totp: %w", err)
+	}
+
+	return nil
+}
+
+func (m *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error) {
+	if err := m.Verify(ctx, userID, code); err != nil {
+		return nil, err
+	}
+
+	return m.newRecoveryCodes(ctx, userID)
+}
+
+// Verify accepts either a TOTP code or an unused recovery code of a user with TOTP enabled.
+// Every check counts against the code attempts of the user, wrong codes lock them like wrong passwords.
+func (m *MFAService) Verify(ctx context.Context, userID, code string) error {
+	secret, err := m.mrepo.GetTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if secret.ConfirmedAt == nil {
+		return domain.ErrMFANotEnrolled
+	}
+
+	attempt, err := m.lockout.CodeAttempt(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := m.verify(ctx, userID, secret, code); err != nil {
+		if errors.Is(err, domain.ErrInvalidMFACode) {
+			m.lockout.Failure(ctx, attempt)
+		}
+		return err
+	}
+
+	return m.lockout.Success(ctx, attempt)
+}
+
+func (m *MFAService) verify(ctx context.Context, userID string, secret *domain.TOTP, code string) error {
+	if step, ok := totp.Validate(secret.Secret, code, time.Now(), totpSkew); ok {
+		fresh, err := m.mrepo.UseStep(ctx, userID, step)
+		if err != nil {
+			return fmt.Errorf("failed to record totp use: %w", err)
+		}
+
+		if !fresh {
+			return domain.ErrInvalidMFACode
+		}
+
+		return nil
+	}
+
+	return m.mrepo.ConsumeRecoveryCode(ctx, userID, normalizeRecoveryCode(code))
+}
+
+// LoginChallenge returns the challenge the user has to pass after entering the password,
+// or nil if the password is enough.
+func (m *MFAService) LoginChallenge(ctx context.Context, user *domain.User) (*MFAChallenge, error) {
+	enabled, err := m.enabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	kind := domain.MFAChallengeVerify
+	if !enabled {
+		enforced, err := m.enforced(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+
+		if !enforced {
+			return nil, nil
+		}
+
+		kind = domain.MFAChallengeEnroll
+	}
+
+	token, err := newOneTimeToken()
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := domain.MFAChallenge{
+		ID:        uuid.NewString(),
+		UserID:    user.ID,
+		Token:     token,
+		Kind:      kind,
+		ExpiresAt: time.Now().Add(m.challengeTTL),
+	}
+
+	if err := m.mrepo.SaveChallenge(ctx, &challenge); err != nil {
+		return nil, fmt.Errorf("failed to save mfa challenge: %w", err)
+	}
+
+	return &MFAChallenge{
+		Token:     token,
+		Kind:      kind,
+		ExpiresAt: challenge.ExpiresAt,
+	}, nil
+}
+
+// Challenge returns the challenge of the given kind by its token. Every call counts as an attempt,
+// a challenge is dropped after too many of them.
+func (m *MFAService) Challenge(ctx context.Context, token, kind string) (*domain.MFAChallenge, error) {
+	challenge, err := m.mrepo.FindChallenge(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if challenge.Kind != kind {
+		return nil, domain.ErrInvalidToken
+	}
+
+	if challenge.Attempts > maxChallengeAttempts {
+		if err := m.mrepo.DeleteChallenge(ctx, challenge.ID); err != nil {
+			return nil, fmt.Errorf("failed to delete mfa challenge: %w", err)
+		}
+		return nil, domain.ErrInvalidToken
+	}
+
+	return challenge, nil
+}
+
+func (m *MFAService) CompleteChallenge(ctx context.Context, challenge *domain.MFAChallenge) error {
+	if err := m.mrepo.DeleteChallenge(ctx, challenge.ID); err != nil {
+		return fmt.Errorf("failed to delete mfa challenge: %w", err)
+	}
+
+	return nil
+}
+
+func (m *MFAService) enabled(ctx context.Context, userID string) (bool, error) {
+	secret, err := m.mrepo.GetTOTP(ctx, userID)
+	if errors.Is(err, domain.ErrMFANotEnrolled) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to get totp: %w", err)
+	}
+
+	return secret.ConfirmedAt != nil, nil
+}
+
+func (m *MFAService) enforced(ctx context.Context, user *domain.User) (bool, error) {
+	roles, err := m.roles.Roles(ctx, user)
+	if err != nil {
+		return false, err
+	}
+
+	for _, role := range roles {
+		if slices.Contains(m.requiredRoles, role) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (m *MFAService) newRecoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	codes := make([]string, 0, recoveryCodesCount)
+	for range recoveryCodesCount {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		codes = append(codes, code[:4]+"-"+code[4:])
+	}
+
+	normalized := make([]string, 0, len(codes))
+	for _, code := range codes {
+		normalized = append(normalized, normalizeRecoveryCode(code))
+	}
+
+	if err := m.mrepo.ReplaceRecoveryCodes(ctx, userID, normalized); err != nil {
+		return nil, fmt.Errorf("failed to save recovery codes: %w", err)
+	}
+
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
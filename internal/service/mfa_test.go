@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/repository"
+	"github.com/kcthack-auth/pkg/totp"
+)
+
+func TestMFAVerifyCodeOnce(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatalf("secret: %v", err)
+	}
+
+	users := &fakeUsers{users: map[string]*domain.User{"1": {ID: "1", Email: "user@example.com"}}}
+	mfa := newFakeMFA()
+	confirmed := time.Now()
+	mfa.totps["1"] = &domain.TOTP{UserID: "1", Secret: secret, ConfirmedAt: &confirmed}
+
+	lockout := NewLockoutService(users, repository.NewMemoryLoginAttemptRepo(), &lockoutNotifications{}, LockoutConfig{
+		AccountThreshold: 100,
+		IPThreshold:      100,
+		Window:           time.Hour,
+		LockoutDuration:  time.Hour,
+	})
+	m := NewMFAService(users, mfa, NewRoleService(users, fakeRoles{}, nil), lockout, nil, "Test", time.Minute, nil)
+
+	// Keep the steps below from moving while the test runs.
+	if left := totp.Period - time.Now().Unix()%totp.Period; left < 2 {
+		time.Sleep(time.Duration(left) * time.Second)
+	}
+
+	step := totp.Step(time.Now())
+	code := func(step int64) string {
+		c, err := totp.Code(secret, step)
+		if err != nil {
+			t.Fatalf("code: %v", err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		code    string
+		wantErr error
+	}{
+		{name: "code of the previous step", code: code(step - 1)},
+		{name: "same code again", code: code(step - 1), wantErr: domain.ErrInvalidMFACode},
+		{name: "code of the current step", code: code(step)},
+		{name: "earlier code after a later one", code: code(step - 1), wantErr: domain.ErrInvalidMFACode},
+		{name: "current code again", code: code(step), wantErr: domain.ErrInvalidMFACode},
+		{name: "code of the next step", code: code(step + 1)},
+	}
+
+	for _, tt := range tests {
+		if err := m.Verify(context.Background(), "1", tt.code); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	if mfa.totps["1"].LastStep != step+1 {
+		t.Errorf("last step %d, want %d", mfa.totps["1"].LastStep, step+1)
+	}
+}
@@ -0,0 +1,522 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/repository"
+	"github.com/kcthack-auth/pkg/auth"
+)
+
+var supportedScopes = []string{domain.ScopeOpenID, domain.ScopeProfile, domain.ScopeEmail}
+
+// OIDCService makes this service an OpenID Connect provider for other hackathon apps.
+// Only the authorization code flow with PKCE is supported.
+type OIDCService struct {
+	repo       repository.AuthRepository
+	orepo      repository.OIDCRepository
+	tm         auth.JWTManager
+	issuer     string
+	loginURL   string
+	consentURL string
+	requestTTL time.Duration
+	codeTTL    time.Duration
+	accessTTL  time.Duration
+	idTokenTTL time.Duration
+}
+
+func NewOIDCService(repo repository.AuthRepository, orepo repository.OIDCRepository, tm auth.JWTManager, cfg OIDCConfig) *OIDCService {
+	return &OIDCService{
+		repo:       repo,
+		orepo:      orepo,
+		tm:         tm,
+		issuer:     strings.TrimSuffix(cfg.Issuer, "/"),
+		loginURL:   cfg.LoginURL,
+		consentURL: cfg.ConsentURL,
+		requestTTL: cfg.RequestTTL,
+		codeTTL:    cfg.CodeTTL,
+		accessTTL:  cfg.AccessTTL,
+		idTokenTTL: cfg.IDTokenTTL,
+	}
+}
+
+// Authorize handles an authorization request and returns where the browser goes next: the login page,
+// the consent page or back to the client. Errors are only returned when the client or its redirect uri
+// is invalid, all other errors are reported to the client via the redirect.
+func (o *OIDCService) Authorize(ctx context.Context, req AuthorizeReq) (string, error) {
+	client, err := o.findClient(ctx, req.ClientID)
+	if err != nil {
+		return "", err
+	}
+
+	if !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		return "", &domain.OAuthError{Code: domain.OAuthInvalidRequest, Description: "redirect_uri is not registered for the client"}
+	}
+
+	fail := func(code, description string) (string, error) {
+		return errorRedirect(req.RedirectURI, req.State, code, description), nil
+	}
+
+	if req.ResponseType != "code" {
+		return fail(domain.OAuthUnsupportedResponseType, "only the code response type is supported")
+	}
+
+	scopes := strings.Fields(req.Scope)
+	if !slices.Contains(scopes, domain.ScopeOpenID) {
+		return fail(domain.OAuthInvalidScope, "the openid scope is required")
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(client.Scopes, scope) {
+			return fail(domain.OAuthInvalidScope, "scope "+scope+" is not allowed for the client")
+		}
+	}
+
+	// PKCE is required for every client, plain challenges are not accepted.
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return fail(domain.OAuthInvalidRequest, "a code_challenge with the S256 method is required")
+	}
+
+	if req.UserID == "" {
+		if req.Prompt == "none" {
+			return fail(domain.OAuthLoginRequired, "the user is not logged in")
+		}
+		return o.loginURL + "?" + url.Values{"return_to": {o.issuer + req.RequestURI}}.Encode(), nil
+	}
+
+	authz := domain.OIDCAuthorization{
+		ID:            uuid.NewString(),
+		ClientID:      client.ID,
+		UserID:        req.UserID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         strings.Join(scopes, " "),
+		State:         req.State,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		AuthTime:      req.AuthTime,
+	}
+
+	consent, err := o.orepo.FindConsent(ctx, req.UserID, client.ID)
+	if err != nil && !errors.Is(err, domain.ErrConsentNotFound) {
+		return "", fmt.Errorf("failed to find consent: %w", err)
+	}
+
+	if consent != nil && coversScopes(consent.Scope, scopes) && req.Prompt != "consent" {
+		code, err := newOneTimeToken()
+		if err != nil {
+			return "", err
+		}
+		authz.Code = code
+		authz.ExpiresAt = time.Now().Add(o.codeTTL)
+
+		if err := o.orepo.SaveAuthorization(ctx, &authz); err != nil {
+			return "", fmt.Errorf("failed to save authorization: %w", err)
+		}
+
+		return codeRedirect(authz.RedirectURI, authz.State, code), nil
+	}
+
+	if req.Prompt == "none" {
+		return fail(domain.OAuthConsentRequired, "the user has not consented yet")
+	}
+
+	authz.ExpiresAt = time.Now().Add(o.requestTTL)
+	if err := o.orepo.SaveAuthorization(ctx, &authz); err != nil {
+		return "", fmt.Errorf("failed to save authorization: %w", err)
+	}
+
+	return o.consentURL + "?" + url.Values{"request_id": {authz.ID}}.Encode(), nil
+}
+
+// Consent returns what the consent screen shows for a pending request.
+func (o *OIDCService) Consent(ctx context.Context, userID, requestID string) (*ConsentInfo, error) {
+	if err := uuid.Validate(requestID); err != nil {
+		return nil, domain.ErrInvalidToken
+	}
+
+	authz, err := o.orepo.FindPendingAuthorization(ctx, requestID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := o.orepo.FindClient(ctx, authz.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	redirect, _ := url.Parse(authz.RedirectURI)
+
+	return &ConsentInfo{
+		ClientID:     client.ID,
+		ClientName:   client.Name,
+		RedirectHost: redirect.Host,
+		Scopes:       strings.Fields(authz.Scope),
+		ExpiresAt:    authz.ExpiresAt,
+	}, nil
+}
+
+// Decide finishes a pending request with the decision of the user and returns the redirect back to the client.
+func (o *OIDCService) Decide(ctx context.Context, userID, requestID string, approve bool) (string, error) {
+	if err := uuid.Validate(requestID); err != nil {
+		return "", domain.ErrInvalidToken
+	}
+
+	authz, err := o.orepo.FindPendingAuthorization(ctx, requestID, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if !approve {
+		if err := o.orepo.DeleteAuthorization(ctx, authz.ID); err != nil {
+			return "", fmt.Errorf("failed to delete authorization: %w", err)
+		}
+		return errorRedirect(authz.RedirectURI, authz.State, domain.OAuthAccessDenied, "the user denied the request"), nil
+	}
+
+	if err := o.orepo.SaveConsent(ctx, &domain.OIDCConsent{
+		UserID:    userID,
+		ClientID:  authz.ClientID,
+		Scope:     authz.Scope,
+		CreatedAt: time.Now(),
+	}); err != nil {
+		return "", fmt.Errorf("failed to save consent: %w", err)
+	}
+
+	code, err := newOneTimeToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := o.orepo.SetCode(ctx, authz.ID, code, time.Now().Add(o.codeTTL)); err != nil {
+		return "", err
+	}
+
+	return codeRedirect(authz.RedirectURI, authz.State, code), nil
+}
+
+// Token redeems an authorization code for an access token and an ID token.
+func (o *OIDCService) Token(ctx context.Context, req TokenReq) (*TokenResp, error) {
+	if req.GrantType != "authorization_code" {
+		return nil, &domain.OAuthError{Code: domain.OAuthUnsupportedGrantType, Description: "only the authorization_code grant is supported"}
+	}
+
+	client, err := o.authenticateClient(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	invalidGrant := &domain.OAuthError{Code: domain.OAuthInvalidGrant, Description: "the code is invalid, expired or was issued to another client"}
+
+	authz, err := o.orepo.ConsumeCode(ctx, req.Code)
+	if errors.Is(err, domain.ErrInvalidToken) {
+		return nil, invalidGrant
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume code: %w", err)
+	}
+
+	if authz.ClientID != client.ID || authz.RedirectURI != req.RedirectURI {
+		return nil, invalidGrant
+	}
+
+	if !verifyPKCE(req.CodeVerifier, authz.CodeChallenge) {
+		return nil, &domain.OAuthError{Code: domain.OAuthInvalidGrant, Description: "code_verifier does not match the code_challenge"}
+	}
+
+	user, err := o.repo.FindByID(ctx, authz.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	if user.IsBlocked {
+		return nil, &domain.OAuthError{Code: domain.OAuthInvalidGrant, Description: domain.ErrUserBlocked.Error()}
+	}
+
+	accessToken, err := o.tm.NewAccess(auth.TokenClaims{
+		UserID:   user.ID,
+		Role:     user.Role,
+		Roles:    []string{user.Role},
+		Verified: user.IsVerified,
+		ClientID: client.ID,
+		Scope:    authz.Scope,
+	}, o.accessTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	claims := o.userClaims(user, authz.Scope)
+	claims["iss"] = o.issuer
+	claims["aud"] = client.ID
+	claims["auth_time"] = authz.AuthTime.Unix()
+	if authz.Nonce != "" {
+		claims["nonce"] = authz.Nonce
+	}
+
+	idToken, err := o.tm.Sign(claims, o.idTokenTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate id token: %w", err)
+	}
+
+	return &TokenResp{
+		AccessToken: accessToken,
+		IDToken:     idToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(o.accessTTL.Seconds()),
+		Scope:       authz.Scope,
+	}, nil
+}
+
+// UserInfo returns the claims of the token owner allowed by the token scope.
+func (o *OIDCService) UserInfo(ctx context.Context, accessToken string) (map[string]any, error) {
+	claims, err := o.tm.Validate(ctx, accessToken)
+	if err != nil {
+		return nil, domain.ErrInvalidToken
+	}
+
+	// Tokens of the user's own sessions see every claim.
+	scope := strings.Join(supportedScopes, " ")
+	if claims.ClientID != "" {
+		scope = claims.Scope
+	}
+
+	if !slices.Contains(strings.Fields(scope), domain.ScopeOpenID) {
+		return nil, domain.ErrInvalidToken
+	}
+
+	user, err := o.repo.FindByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.userClaims(user, scope), nil
+}
+
+// Discovery returns the OpenID Provider Metadata document.
+func (o *OIDCService) Discovery() map[string]any {
+	algs := []string{}
+	for _, key := range o.tm.JWKS().Keys {
+		if !slices.Contains(algs, key.Alg) {
+			algs = append(algs, key.Alg)
+		}
+	}
+
+	return map[string]any{
+		"issuer":                                o.issuer,
+		"authorization_endpoint":                o.issuer + "/oauth/authorize",
+		"token_endpoint":                        o.issuer + "/oauth/token",
+		"userinfo_endpoint":                     o.issuer + "/oauth/userinfo",
+		"introspection_endpoint":                o.issuer + "/oauth/introspect",
+		"revocation_endpoint":                   o.issuer + "/oauth/revoke",
+		"jwks_uri":                              o.issuer + "/.well-known/jwks.json",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code", "client_credentials"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": algs,
+		"scopes_supported":                      supportedScopes,
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"prompt_values_supported":               []string{"none", "consent"},
+		"claims_supported":                      []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "name", "given_name", "family_name", "preferred_username", "birthdate", "email", "email_verified"},
+	}
+}
+
+func (o *OIDCService) CreateClient(ctx context.Context, req CreateClientReq) (*domain.OIDCClient, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, fmt.Errorf("%w: name cannot be empty", domain.ErrValidation)
+	}
+
+	if len(req.RedirectURIs) == 0 {
+		return nil, fmt.Errorf("%w: at least one redirect uri is required", domain.ErrValidation)
+	}
+
+	for _, uri := range req.RedirectURIs {
+		u, err := url.Parse(uri)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.Fragment != "" {
+			return nil, fmt.Errorf("%w: invalid redirect uri %q", domain.ErrValidation, uri)
+		}
+	}
+
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = supportedScopes
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(supportedScopes, scope) {
+			return nil, fmt.Errorf("%w: unsupported scope %q", domain.ErrValidation, scope)
+		}
+	}
+
+	client := domain.OIDCClient{
+		ID:           uuid.NewString(),
+		Name:         strings.TrimSpace(req.Name),
+		RedirectURIs: req.RedirectURIs,
+		Scopes:       scopes,
+		Public:       req.Public,
+		CreatedAt:    time.Now(),
+	}
+
+	if !client.Public {
+		secret, err := newOneTimeToken()
+		if err != nil {
+			return nil, err
+		}
+		client.Secret = secret
+	}
+
+	if err := o.orepo.CreateClient(ctx, &client); err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+
+	return &client, nil
+}
+
+func (o *OIDCService) ListClients(ctx context.Context) ([]domain.OIDCClient, error) {
+	clients, err := o.orepo.ListClients(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clients: %w", err)
+	}
+
+	return clients, nil
+}
+
+func (o *OIDCService) DeleteClient(ctx context.Context, id string) error {
+	if err := uuid.Validate(id); err != nil {
+		return domain.ErrClientNotFound
+	}
+
+	return o.orepo.DeleteClient(ctx, id)
+}
+
+func (o *OIDCService) findClient(ctx context.Context, id string) (*domain.OIDCClient, error) {
+	invalidClient := &domain.OAuthError{Code: domain.OAuthInvalidClient, Description: "unknown client"}
+
+	if err := uuid.Validate(id); err != nil {
+		return nil, invalidClient
+	}
+
+	client, err := o.orepo.FindClient(ctx, id)
+	if errors.Is(err, domain.ErrClientNotFound) {
+		return nil, invalidClient
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find client: %w", err)
+	}
+
+	return client, nil
+}
+
+// authenticateClient checks the client credentials of a token request. Public clients are
+// authenticated by PKCE alone.
+func (o *OIDCService) authenticateClient(ctx context.Context, id, secret string) (*domain.OIDCClient, error) {
+	client, err := o.findClient(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if client.Public {
+		return client, nil
+	}
+
+	matches, err := o.orepo.ClientSecretMatches(ctx, client.ID, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check client secret: %w", err)
+	}
+
+	if secret == "" || !matches {
+		return nil, &domain.OAuthError{Code: domain.OAuthInvalidClient, Description: "client authentication failed"}
+	}
+
+	return client, nil
+}
+
+// userClaims returns the standard claims of the user allowed by the scope.
+func (o *OIDCService) userClaims(user *domain.User, scope string) map[string]any {
+	scopes := strings.Fields(scope)
+	claims := map[string]any{
+		"sub": user.ID,
+	}
+
+	if slices.Contains(scopes, domain.ScopeProfile) {
+		claims["name"] = strings.TrimSpace(user.FirstName + " " + user.LastName)
+		claims["given_name"] = user.FirstName
+		claims["family_name"] = user.LastName
+		claims["updated_at"] = user.UpdatedAt.Unix()
+		if user.TgName != "" {
+			claims["preferred_username"] = user.TgName
+		}
+		if !user.BirthDate.IsZero() {
+			claims["birthdate"] = user.BirthDate.Format(time.DateOnly)
+		}
+	}
+
+	if slices.Contains(scopes, domain.ScopeEmail) && user.Email != "" {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.IsVerified
+	}
+
+	return claims
+}
+
+func coversScopes(granted string, requested []string) bool {
+	grantedScopes := strings.Fields(granted)
+	for _, scope := range requested {
+		if !slices.Contains(grantedScopes, scope) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func verifyPKCE(verifier, challenge string) bool {
+	if verifier == "" {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+func codeRedirect(redirectURI, state, code string) string {
+	params := url.Values{"code": {code}}
+	if state != "" {
+		params.Set("state", state)
+	}
+
+	return appendQuery(redirectURI, params)
+}
+
+func errorRedirect(redirectURI, state, code, description string) string {
+	params := url.Values{"error": {code}, "error_description": {description}}
+	if state != "" {
+		params.Set("state", state)
+	}
+
+	return appendQuery(redirectURI, params)
+}
+
+func appendQuery(uri string, params url.Values) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+
+	query := u.Query()
+	for k, v := range params {
+		query[k] = v
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
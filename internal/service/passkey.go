@@ -0,0 +1,317 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/repository"
+)
+
+type PasskeyService struct {
+	repo        repository.AuthRepository
+	prepo       repository.PasskeyRepository
+	auth        *AuthService
+	webAuthn    *webauthn.WebAuthn
+	ceremonyTTL time.Duration
+}
+
+func NewPasskeyService(repo repository.AuthRepository, prepo repository.PasskeyRepository, auth *AuthService, webAuthn *webauthn.WebAuthn, ceremonyTTL time.Duration) *PasskeyService {
+	return &PasskeyService{
+		repo:        repo,
+		prepo:       prepo,
+		auth:        auth,
+		webAuthn:    webAuthn,
+		ceremonyTTL: ceremonyTTL,
+	}
+}
+
+// passkeyUser adapts a user and their passkeys to the webauthn.User interface.
+// The user handle is the user id, which lets discoverable logins find the account.
+type passkeyUser struct {
+	user     *domain.User
+	passkeys []domain.Passkey
+	creds    []webauthn.Credential
+}
+
+func (u *passkeyUser) WebAuthnID() []byte {
+	return []byte(u.user.ID)
+}
+
+func (u *passkeyUser) WebAuthnName() string {
+	return accountName(u.user)
+}
+
+func (u *passkeyUser) WebAuthnDisplayName() string {
+	return u.user.FirstName + " " + u.user.LastName
+}
+
+func (u *passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.creds
+}
+
+func (p *PasskeyService) BeginRegistration(ctx context.Context, userID string) (*PasskeyCeremony, error) {
+	user, err := p.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	creation, session, err := p.webAuthn.BeginRegistration(user,
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+		webauthn.WithExclusions(webauthn.Credentials(user.creds).CredentialDescriptors()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin passkey registration: %w", err)
+	}
+
+	id, err := p.saveCeremony(ctx, user.user.ID, domain.CeremonyRegister, session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PasskeyCeremony{ID: id, Options: creation}, nil
+}
+
+func (p *PasskeyService) FinishRegistration(ctx context.Context, userID string, req FinishPasskeyReq) (*domain.Passkey, error) {
+	ceremony, session, err := p.consumeCeremony(ctx, req.CeremonyID, domain.CeremonyRegister)
+	if err != nil {
+		return nil, err
+	}
+
+	if ceremony.UserID != userID {
+		return nil, domain.ErrInvalidToken
+	}
+
+	user, err := p.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(req.Credential)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrPasskeyInvalid, err)
+	}
+
+	credential, err := p.webAuthn.CreateCredential(user, *session, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrPasskeyInvalid, err)
+	}
+
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode passkey: %w", err)
+	}
+
+	name := req.Name
+	if name == "" {
+		name = "Passkey " + time.Now().Format("2006-01-02")
+	}
+
+	passkey := domain.Passkey{
+		ID:           uuid.NewString(),
+		UserID:       userID,
+		CredentialID: base64.RawURLEncoding.EncodeToString(credential.ID),
+		Name:         name,
+		Credential:   data,
+		SignCount:    credential.Authenticator.SignCount,
+		CreatedAt:    time.Now(),
+	}
+
+	if err := p.prepo.Create(ctx, &passkey); err != nil {
+		return nil, fmt.Errorf("failed to save passkey: %w", err)
+	}
+
+	return &passkey, nil
+}
+
+func (p *PasskeyService) List(ctx context.Context, userID string) ([]domain.Passkey, error) {
+	passkeys, err := p.prepo.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list passkeys: %w", err)
+	}
+
+	return passkeys, nil
+}
+
+// Delete removes the passkey unless it is the only way for the user to log in.
+func (p *PasskeyService) Delete(ctx context.Context, userID, id string) error {
+	methods, err := p.repo.CountLoginMethods(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if methods <= 1 {
+		passkeys, err := p.prepo.ListByUserID(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("failed to list passkeys: %w", err)
+		}
+		for _, passkey := range passkeys {
+			if passkey.ID == id {
+				return domain.ErrLastLoginMethod
+			}
+		}
+		return domain.ErrPasskeyNotFound
+	}
+
+	return p.prepo.Delete(ctx, userID, id)
+}
+
+// BeginLogin starts a passwordless login. The account is not known until the authenticator answers.
+func (p *PasskeyService) BeginLogin(ctx context.Context) (*PasskeyCeremony, error) {
+	assertion, session, err := p.webAuthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin passkey login: %w", err)
+	}
+
+	id, err := p.saveCeremony(ctx, "", domain.CeremonyLogin, session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PasskeyCeremony{ID: id, Options: assertion}, nil
+}
+
+// FinishLogin verifies the assertion and issues the same token pair and session as a password login.
+// Passkeys with user verification already are two factors, so no TOTP challenge follows.
+func (p *PasskeyService) FinishLogin(ctx context.Context, req FinishPasskeyReq) (*AuthResp, error) {
+	_, session, err := p.consumeCeremony(ctx, req.CeremonyID, domain.CeremonyLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(req.Credential)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrPasskeyInvalid, err)
+	}
+
+	var user *passkeyUser
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		u, err := p.loadUser(ctx, string(userHandle))
+		if err != nil {
+			return nil, err
+		}
+		user = u
+		return u, nil
+	}
+
+	credential, err := p.webAuthn.ValidateDiscoverableLogin(handler, *session, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrPasskeyInvalid, err)
+	}
+
+	if credential.Authenticator.CloneWarning {
+		return nil, domain.ErrPasskeyCloned
+	}
+
+	if user.user.IsBlocked {
+		return nil, domain.ErrUserBlocked
+	}
+
+	passkey, err := user.passkey(credential.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode passkey: %w", err)
+	}
+
+	passkey.Credential = data
+	passkey.SignCount = credential.Authenticator.SignCount
+
+	if err := p.prepo.UpdateUsage(ctx, passkey); err != nil {
+		if errors.Is(err, domain.ErrPasskeyCloned) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to update passkey: %w", err)
+	}
+
+	// A passkey is a first factor like a password, users with TOTP or a role that requires it
+	// get the same challenge.
+	return p.auth.completeLogin(ctx, user.user, req.Client)
+}
+
+func (p *PasskeyService) loadUser(ctx context.Context, userID string) (*passkeyUser, error) {
+	if err := uuid.Validate(userID); err != nil {
+		return nil, domain.ErrUserNotFound
+	}
+
+	user, err := p.repo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	passkeys, err := p.prepo.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list passkeys: %w", err)
+	}
+
+	creds := make([]webauthn.Credential, 0, len(passkeys))
+	for _, passkey := range passkeys {
+		var cred webauthn.Credential
+		if err := json.Unmarshal(passkey.Credential, &cred); err != nil {
+			return nil, fmt.Errorf("failed to decode passkey %s: %w", passkey.ID, err)
+		}
+		creds = append(creds, cred)
+	}
+
+	return &passkeyUser{user: user, passkeys: passkeys, creds: creds}, nil
+}
+
+func (u *passkeyUser) passkey(credentialID []byte) (*domain.Passkey, error) {
+	id := base64.RawURLEncoding.EncodeToString(credentialID)
+	for i := range u.passkeys {
+		if u.passkeys[i].CredentialID == id {
+			return &u.passkeys[i], nil
+		}
+	}
+
+	return nil, domain.ErrPasskeyNotFound
+}
+
+func (p *PasskeyService) saveCeremony(ctx context.Context, userID, kind string, session *webauthn.SessionData) (string, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode webauthn session: %w", err)
+	}
+
+	ceremony := domain.WebAuthnCeremony{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Kind:      kind,
+		Session:   data,
+		ExpiresAt: time.Now().Add(p.ceremonyTTL),
+	}
+
+	if err := p.prepo.SaveCeremony(ctx, &ceremony); err != nil {
+		return "", fmt.Errorf("failed to save webauthn ceremony: %w", err)
+	}
+
+	return ceremony.ID, nil
+}
+
+func (p *PasskeyService) consumeCeremony(ctx context.Context, id, kind string) (*domain.WebAuthnCeremony, *webauthn.SessionData, error) {
+	if err := uuid.Validate(id); err != nil {
+		return nil, nil, domain.ErrInvalidToken
+	}
+
+	ceremony, err := p.prepo.ConsumeCeremony(ctx, id, kind)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal(ceremony.Session, &session); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode webauthn session: %w", err)
+	}
+
+	return ceremony, &session, nil
+}
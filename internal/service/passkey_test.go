@@ -0,0 +1,451 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/pkg/auth"
+)
+
+const (
+	testRPID   = "auth.example.com"
+	testOrigin = "https://auth.example.com"
+)
+
+// softAuthenticator is a software WebAuthn authenticator holding one discoverable ES256 credential.
+type softAuthenticator struct {
+	key        *ecdsa.PrivateKey
+	id         []byte
+	userHandle []byte
+	signCount  uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	return &softAuthenticator{key: key, id: id}
+}
+
+// create answers a registration ceremony with a "none" attestation.
+func (a *softAuthenticator) create(t *testing.T, options any, userHandle []byte) []byte {
+	t.Helper()
+
+	creation := options.(*protocol.CredentialCreation)
+	a.userHandle = userHandle
+
+	clientData := a.clientData(t, protocol.CreateCeremony, creation.Response.Challenge)
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  1,
+		XCoord: a.key.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatalf("encode public key: %v", err)
+	}
+
+	authData := a.authData(protocol.FlagUserPresent | protocol.FlagUserVerified | protocol.FlagAttestedCredentialData)
+	authData = append(authData, make([]byte, 16)...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.id)))
+	authData = append(authData, a.id...)
+	authData = append(authData, publicKey...)
+
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+	if err != nil {
+		t.Fatalf("encode attestation: %v", err)
+	}
+
+	return a.credential(t, map[string]string{
+		"clientDataJSON":    encode(clientData),
+		"attestationObject": encode(attestation),
+	})
+}
+
+// get answers a login ceremony, the counter is increased first like a real authenticator does.
+func (a *softAuthenticator) get(t *testing.T, options any) []byte {
+	t.Helper()
+
+	assertion := options.(*protocol.CredentialAssertion)
+	a.signCount++
+
+	clientData := a.clientData(t, protocol.AssertCeremony, assertion.Response.Challenge)
+	authData := a.authData(protocol.FlagUserPresent | protocol.FlagUserVerified)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatalf("sign assertion: %v", err)
+	}
+
+	return a.credential(t, map[string]string{
+		"clientDataJSON":    encode(clientData),
+		"authenticatorData": encode(authData),
+		"signature":         encode(signature),
+		"userHandle":        encode(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) clientData(t *testing.T, ceremony protocol.CeremonyType, challenge protocol.URLEncodedBase64) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]string{
+		"type":      string(ceremony),
+		"challenge": challenge.String(),
+		"origin":    testOrigin,
+	})
+	if err != nil {
+		t.Fatalf("encode client data: %v", err)
+	}
+
+	return data
+}
+
+func (a *softAuthenticator) authData(flags protocol.AuthenticatorFlags) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+
+	data := append(rpIDHash[:], byte(flags))
+	return binary.BigEndian.AppendUint32(data, a.signCount)
+}
+
+func (a *softAuthenticator) credential(t *testing.T, response map[string]string) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]any{
+		"id":       encode(a.id),
+		"rawId":    encode(a.id),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		t.Fatalf("encode credential: %v", err)
+	}
+
+	return data
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// fakePasskeys keeps passkeys and ceremonies in memory with the semantics of PasskeyRepo.
+type fakePasskeys struct {
+	mu         sync.Mutex
+	passkeys   map[string]domain.Passkey
+	ceremonies map[string]domain.WebAuthnCeremony
+}
+
+func newFakePasskeys() *fakePasskeys {
+	return &fakePasskeys{
+		passkeys:   make(map[string]domain.Passkey),
+		ceremonies: make(map[string]domain.WebAuthnCeremony),
+	}
+}
+
+func (f *fakePasskeys) Create(ctx context.Context, passkey *domain.Passkey) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.passkeys[passkey.ID] = *passkey
+	return nil
+}
+
+func (f *fakePasskeys) ListByUserID(ctx context.Context, userID string) ([]domain.Passkey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var passkeys []domain.Passkey
+	for _, passkey := range f.passkeys {
+		if passkey.UserID == userID {
+			passkeys = append(passkeys, passkey)
+		}
+	}
+
+	return passkeys, nil
+}
+
+func (f *fakePasskeys) UpdateUsage(ctx context.Context, passkey *domain.Passkey) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stored := f.passkeys[passkey.ID]
+	if stored.SignCount >= passkey.SignCount && passkey.SignCount != 0 {
+		return domain.ErrPasskeyCloned
+	}
+
+	f.passkeys[passkey.ID] = *passkey
+	return nil
+}
+
+func (f *fakePasskeys) Delete(ctx context.Context, userID, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.passkeys, id)
+	return nil
+}
+
+func (f *fakePasskeys) SaveCeremony(ctx context.Context, ceremony *domain.WebAuthnCeremony) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.ceremonies[ceremony.ID] = *ceremony
+	return nil
+}
+
+func (f *fakePasskeys) ConsumeCeremony(ctx context.Context, id, kind string) (*domain.WebAuthnCeremony, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ceremony, ok := f.ceremonies[id]
+	if !ok || ceremony.Kind != kind {
+		return nil, domain.ErrInvalidToken
+	}
+	delete(f.ceremonies, id)
+
+	if ceremony.ExpiresAt.Before(time.Now()) {
+		return nil, domain.ErrInvalidToken
+	}
+
+	return &ceremony, nil
+}
+
+type passkeyTest struct {
+	service  *PasskeyService
+	passkeys *fakePasskeys
+	sessions *fakeSessions
+	user     *domain.User
+}
+
+func newPasskeyTest(t *testing.T) *passkeyTest {
+	t.Helper()
+
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          testRPID,
+		RPDisplayName: "Test",
+		RPOrigins:     []string{testOrigin},
+	})
+	if err != nil {
+		t.Fatalf("webauthn: %v", err)
+	}
+
+	user := &domain.User{ID: uuid.NewString(), Email: "user@example.com", FirstName: "Test", LastName: "User", Role: "participant"}
+	users := &fakeUsers{users: map[string]*domain.User{user.ID: user}}
+	passkeys := newFakePasskeys()
+	sessions := &fakeSessions{}
+
+	roles := NewRoleService(users, fakeRoles{}, nil)
+	authService := &AuthService{
+		repo:       users,
+		srepo:      sessions,
+		tm:         auth.NewManager(auth.NewKeyRing(auth.NewHMACKey("test", "test-secret"))),
+		roles:      roles,
+		mfa:        NewMFAService(users, newFakeMFA(), roles, nil, nil, "Test", time.Minute, []string{"admin"}),
+		accessTTL:  time.Minute,
+		refreshTTL: time.Hour,
+	}
+
+	return &passkeyTest{
+		service:  NewPasskeyService(users, passkeys, authService, webAuthn, time.Minute),
+		passkeys: passkeys,
+		sessions: sessions,
+		user:     user,
+	}
+}
+
+func (p *passkeyTest) register(t *testing.T, authenticator *softAuthenticator) *domain.Passkey {
+	t.Helper()
+
+	ctx := context.Background()
+	ceremony, err := p.service.BeginRegistration(ctx, p.user.ID)
+	if err != nil {
+		t.Fatalf("begin registration: %v", err)
+	}
+
+	passkey, err := p.service.FinishRegistration(ctx, p.user.ID, FinishPasskeyReq{
+		CeremonyID: ceremony.ID,
+		Credential: authenticator.create(t, ceremony.Options, []byte(p.user.ID)),
+	})
+	if err != nil {
+		t.Fatalf("finish registration: %v", err)
+	}
+
+	return passkey
+}
+
+func (p *passkeyTest) login(t *testing.T, authenticator *softAuthenticator) (*AuthResp, error) {
+	t.Helper()
+
+	ctx := context.Background()
+	ceremony, err := p.service.BeginLogin(ctx)
+	if err != nil {
+		t.Fatalf("begin login: %v", err)
+	}
+
+	return p.service.FinishLogin(ctx, FinishPasskeyReq{
+		CeremonyID: ceremony.ID,
+		Credential: authenticator.get(t, ceremony.Options),
+	})
+}
+
+func TestPasskeyRegistration(t *testing.T) {
+	p := newPasskeyTest(t)
+	authenticator := newSoftAuthenticator(t)
+
+	passkey := p.register(t, authenticator)
+
+	if passkey.UserID != p.user.ID {
+		t.Errorf("passkey of user %s, want %s", passkey.UserID, p.user.ID)
+	}
+	if passkey.CredentialID != encode(authenticator.id) {
+		t.Errorf("credential id %s, want %s", passkey.CredentialID, encode(authenticator.id))
+	}
+
+	passkeys, _ := p.passkeys.ListByUserID(context.Background(), p.user.ID)
+	if len(passkeys) != 1 {
+		t.Errorf("%d passkeys stored, want 1", len(passkeys))
+	}
+}
+
+func TestPasskeyRegistrationCeremonyOfOtherUser(t *testing.T) {
+	p := newPasskeyTest(t)
+	authenticator := newSoftAuthenticator(t)
+
+	ceremony, err := p.service.BeginRegistration(context.Background(), p.user.ID)
+	if err != nil {
+		t.Fatalf("begin registration: %v", err)
+	}
+
+	_, err = p.service.FinishRegistration(context.Background(), uuid.NewString(), FinishPasskeyReq{
+		CeremonyID: ceremony.ID,
+		Credential: authenticator.create(t, ceremony.Options, []byte(p.user.ID)),
+	})
+	if !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("got %v, want %v", err, domain.ErrInvalidToken)
+	}
+}
+
+func TestPasskeyDiscoverableLogin(t *testing.T) {
+	p := newPasskeyTest(t)
+	authenticator := newSoftAuthenticator(t)
+	registered := p.register(t, authenticator)
+
+	resp, err := p.login(t, authenticator)
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	if resp.AccessToken == "" || resp.RefreshToken == "" {
+		t.Errorf("login returned no tokens: %+v", resp)
+	}
+	if len(p.sessions.saved) != 1 || p.sessions.saved[0].UserID != p.user.ID {
+		t.Errorf("sessions %+v, want one of user %s", p.sessions.saved, p.user.ID)
+	}
+
+	passkeys, _ := p.passkeys.ListByUserID(context.Background(), p.user.ID)
+	if len(passkeys) != 1 || passkeys[0].ID != registered.ID || passkeys[0].SignCount != 1 {
+		t.Errorf("passkeys %+v, want the registered one with sign count 1", passkeys)
+	}
+}
+
+func TestPasskeyLoginCeremonyIsSingleUse(t *testing.T) {
+	p := newPasskeyTest(t)
+	authenticator := newSoftAuthenticator(t)
+	p.register(t, authenticator)
+
+	ceremony, err := p.service.BeginLogin(context.Background())
+	if err != nil {
+		t.Fatalf("begin login: %v", err)
+	}
+
+	req := FinishPasskeyReq{CeremonyID: ceremony.ID, Credential: authenticator.get(t, ceremony.Options)}
+	if _, err := p.service.FinishLogin(context.Background(), req); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	if _, err := p.service.FinishLogin(context.Background(), req); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("replayed login: got %v, want %v", err, domain.ErrInvalidToken)
+	}
+}
+
+func TestPasskeyLoginSignCount(t *testing.T) {
+	p := newPasskeyTest(t)
+	authenticator := newSoftAuthenticator(t)
+	p.register(t, authenticator)
+
+	for i := range 2 {
+		if _, err := p.login(t, authenticator); err != nil {
+			t.Fatalf("login %d: %v", i+1, err)
+		}
+	}
+
+	// A copy of the credential answers with a counter the server has already seen.
+	authenticator.signCount = 0
+	if _, err := p.login(t, authenticator); !errors.Is(err, domain.ErrPasskeyCloned) {
+		t.Errorf("login with an old counter: got %v, want %v", err, domain.ErrPasskeyCloned)
+	}
+}
+
+func TestPasskeyLoginBlockedUser(t *testing.T) {
+	p := newPasskeyTest(t)
+	authenticator := newSoftAuthenticator(t)
+	p.register(t, authenticator)
+
+	p.user.IsBlocked = true
+	if _, err := p.login(t, authenticator); !errors.Is(err, domain.ErrUserBlocked) {
+		t.Errorf("got %v, want %v", err, domain.ErrUserBlocked)
+	}
+}
+
+func TestPasskeyLoginMFAEnforced(t *testing.T) {
+	p := newPasskeyTest(t)
+	authenticator := newSoftAuthenticator(t)
+	p.register(t, authenticator)
+
+	// The role requires TOTP, which the user has not enabled yet.
+	p.user.Role = "admin"
+	resp, err := p.login(t, authenticator)
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	if resp.MFA == nil || resp.MFA.Kind != domain.MFAChallengeEnroll {
+		t.Fatalf("login returned %+v, want an enrollment challenge", resp)
+	}
+	if resp.AccessToken != "" || len(p.sessions.saved) != 0 {
+		t.Errorf("login issued tokens before the second factor: %+v, sessions %+v", resp, p.sessions.saved)
+	}
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/repository"
+	"github.com/kcthack-auth/pkg/hasher"
+	"github.com/kcthack-auth/pkg/logger"
+	"github.com/kcthack-auth/pkg/mail"
+	"github.com/kcthack-auth/pkg/passpolicy"
+)
+
+type PasswordService struct {
+	repo      repository.AuthRepository
+	srepo     repository.SessionRepository
+	prepo     repository.PasswordResetRepository
+	sender    mail.Sender
+	passwords *passpolicy.Policy
+	hasher    hasher.PasswordHasher
+	tokenTTL  time.Duration
+	url       string
+}
+
+func NewPasswordService(repo repository.AuthRepository, srepo repository.SessionRepository, prepo repository.PasswordResetRepository, sender mail.Sender, passwords *passpolicy.Policy, hasher hasher.PasswordHasher, tokenTTL time.Duration, url string) *PasswordService {
+	return &PasswordService{
+		repo:      repo,
+		srepo:     srepo,
+		prepo:     prepo,
+		sender:    sender,
+		passwords: passwords,
+		hasher:    hasher,
+		tokenTTL:  tokenTTL,
+		url:       url,
+	}
+}
+
+// forgotTimeout bounds the background work of a reset request.
+const forgotTimeout = 30 * time.Second
+
+// Forgot mails a reset link to the user. The lookup and the mail run in the background and their
+// failures are only logged, so neither the result nor the response time tell whether the address is registered.
+func (p *PasswordService) Forgot(ctx context.Context, email string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), forgotTimeout)
+
+	go func() {
+		defer cancel()
+
+		if err := p.sendReset(ctx, email); err != nil {
+			slog.ErrorContext(ctx, "failed to send password reset", logger.Err(err))
+		}
+	}()
+}
+
+// sendReset replaces the reset tokens of the user with a new one and mails it. Unknown emails are ignored.
+func (p *PasswordService) sendReset(ctx context.Context, email string) error {
+	user, err := p.repo.FindByEmail(ctx, email)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if err := p.prepo.DeleteAllByUserID(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to delete old reset tokens: %w", err)
+	}
+
+	token, err := newOneTimeToken()
+	if err != nil {
+		return err
+	}
+
+	if err := p.prepo.Save(ctx, &domain.PasswordResetToken{
+		ID:        uuid.NewString(),
+		UserID:    user.ID,
+		Token:     token,
+		ExpiresAt: time.Now().Add(p.tokenTTL),
+	}); err != nil {
+		return fmt.Errorf("failed to save reset token: %w", err)
+	}
+
+	if err := p.sender.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Сброс пароля КЦТHack",
+		Body:    fmt.Sprintf("Чтобы задать новый пароль, перейдите по ссылке: %s?token=%s\nЕсли вы не запрашивали сброс, просто проигнорируйте это письмо.", p.url, token),
+	}); err != nil {
+		return fmt.Errorf("failed to send reset email – user: %v, err: %w", user.ID, err)
+	}
+
+	return nil
+}
+
+// Reset sets a new password using a reset token and logs the user out of every session.
+func (p *PasswordService) Reset(ctx context.Context, req ResetPasswordReq) error {
+	if req.Token == "" {
+		return domain.ErrInvalidToken
+	}
+
+	if req.Password == "" {
+		return fmt.Errorf("password field cannot be empty")
+	}
+
+	// The token is only looked up here, so a rejected password does not use it up.
+	userID, err := p.prepo.FindUserID(ctx, req.Token)
+	if err != nil {
+		return err
+	}
+
+	user, err := p.repo.FindByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to find user: %w", err)
+	}
+
+	if err := p.passwords.Check(req.Password, user.FirstName, user.LastName, user.Email); err != nil {
+		return fmt.Errorf("%w: %w", domain.ErrValidation, err)
+	}
+
+	if _, err := p.prepo.Consume(ctx, req.Token); err != nil {
+		return err
+	}
+
+	passHash, err := p.hasher.Hash(req.Password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password – user: %v, err: %w", userID, err)
+	}
+
+	if err := p.repo.UpdatePassword(ctx, userID, passHash); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	if err := p.prepo.DeleteAllByUserID(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete reset tokens: %w", err)
+	}
+
+	if err := p.srepo.DeleteAllByUserID(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete user sessions: %w", err)
+	}
+
+	return nil
+}
+
+// Change replaces the password of the user owning the refresh token and revokes
+// all of their sessions except the current one.
+func (p *PasswordService) Change(ctx context.Context, req ChangePasswordReq) error {
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		return fmt.Errorf("password fields cannot be empty")
+	}
+
+	session, err := p.srepo.FindByToken(ctx, req.RefreshToken)
+	if err != nil {
+		return err
+	}
+
+	if !session.IsActive() || session.UserID != req.UserID {
+		return domain.ErrSessionNotFound
+	}
+
+	user, err := p.repo.FindByID(ctx, session.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to find user: %w", err)
+	}
+
+	if match, err := p.hasher.Verify(req.CurrentPassword, user.PassHash); err != nil || !match {
+		return domain.ErrInvalidCredentials
+	}
+
+	if req.CurrentPassword == req.NewPassword {
+		return domain.ErrSamePassword
+	}
+
+	if err := p.passwords.Check(req.NewPassword, user.FirstName, user.LastName, user.Email); err != nil {
+		return fmt.Errorf("%w: %w", domain.ErrValidation, err)
+	}
+
+	passHash, err := p.hasher.Hash(req.NewPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash password – user: %v, err: %w", user.ID, err)
+	}
+
+	if err := p.repo.UpdatePassword(ctx, user.ID, passHash); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	if err := p.srepo.DeleteAllByUserIDExcept(ctx, user.ID, session.ID); err != nil {
+		return fmt.Errorf("failed to delete other user sessions: %w", err)
+	}
+
+	return nil
+}
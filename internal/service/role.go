@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/repository"
+	"github.com/kcthack-auth/pkg/rbac"
+)
+
+type RoleService struct {
+	repo   repository.AuthRepository
+	rrepo  repository.RoleRepository
+	policy *rbac.Policy
+}
+
+func NewRoleService(repo repository.AuthRepository, rrepo repository.RoleRepository, policy *rbac.Policy) *RoleService {
+	return &RoleService{
+		repo:   repo,
+		rrepo:  rrepo,
+		policy: policy,
+	}
+}
+
+// Roles returns all roles held by the user, the primary role from users.role first.
+func (r *RoleService) Roles(ctx context.Context, user *domain.User) ([]string, error) {
+	assigned, err := r.rrepo.ListByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user roles: %w", err)
+	}
+
+	roles := []string{user.Role}
+	for _, role := range assigned {
+		if !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+
+	return roles, nil
+}
+
+func (r *RoleService) ListByUserID(ctx context.Context, userID string) ([]string, error) {
+	user, err := r.repo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Roles(ctx, user)
+}
+
+func (r *RoleService) Assign(ctx context.Context, userID, role string) error {
+	if !r.policy.HasRole(role) {
+		return domain.ErrUnknownRole
+	}
+
+	if _, err := r.repo.FindByID(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := r.rrepo.Assign(ctx, userID, role); err != nil {
+		return fmt.Errorf("failed to assign role: %w", err)
+	}
+
+	return nil
+}
+
+func (r *RoleService) Revoke(ctx context.Context, userID, role string) error {
+	user, err := r.repo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if user.Role == role {
+		return domain.ErrPrimaryRole
+	}
+
+	if err := r.rrepo.Revoke(ctx, userID, role); err != nil {
+		return fmt.Errorf("failed to revoke role: %w", err)
+	}
+
+	return nil
+}
+
+// Known reports whether the role is configured.
+func (r *RoleService) Known(role string) bool {
+	return r.policy.HasRole(role)
+}
+
+// Permissions returns every permission granted by the roles.
+func (r *RoleService) Permissions(roles []string) []string {
+	return r.policy.Permissions(roles)
+}
@@ -2,15 +2,56 @@ package service
 
 import (
 	"context"
+	"net/url"
 	"time"
+
+	"github.com/kcthack-auth/internal/domain"
 )
 
 type Services struct {
-	AuthService AuthService
+	AuthService          AuthService
+	VerificationService  VerificationService
+	PasswordService      PasswordService
+	RoleService          RoleService
+	UserService          UserService
+	AdminService         AdminService
+	SessionService       SessionService
+	KeyService           KeyService
+	MFAService           MFAService
+	PasskeyService       PasskeyService
+	TelegramService      TelegramService
+	IdentityService      IdentityService
+	OIDCService          OIDCService
+	MachineClientService MachineClientService
+	IntrospectionService IntrospectionService
+	LockoutService       LockoutService
+}
+
+func NewServices(auth AuthService, verification VerificationService, password PasswordService, role RoleService, user UserService, admin AdminService, session SessionService, key KeyService, mfa MFAService, passkey PasskeyService, telegram TelegramService, identity IdentityService, oidc OIDCService, machineClient MachineClientService, introspection IntrospectionService, lockout LockoutService) *Services {
+	return &Services{
+		AuthService:          auth,
+		VerificationService:  verification,
+		PasswordService:      password,
+		RoleService:          role,
+		UserService:          user,
+		AdminService:         admin,
+		SessionService:       session,
+		KeyService:           key,
+		MFAService:           mfa,
+		PasskeyService:       passkey,
+		TelegramService:      telegram,
+		IdentityService:      identity,
+		OIDCService:          oidc,
+		MachineClientService: machineClient,
+		IntrospectionService: introspection,
+		LockoutService:       lockout,
+	}
 }
 
-func NewServices(auth AuthService) *Services {
-	return &Services{AuthService: auth}
+// ClientInfo describes the client a session is created for.
+type ClientInfo struct {
+	UserAgent string
+	IP        string
 }
 
 type RegisterReq struct {
@@ -18,22 +59,187 @@ type RegisterReq struct {
 	LastName  string
 	Email     string
 	Password  string
+	Client    ClientInfo
 }
 
 type LoginReq struct {
 	Email    string
 	Password string
+	Client   ClientInfo
 }
 
-type AuthResp struct {
-	AccessToken  string
-	RefreshToken string
+type ResetPasswordReq struct {
+	Token    string
+	Password string
+}
+
+type ChangePasswordReq struct {
+	UserID          string
+	RefreshToken    string
+	CurrentPassword string
+	NewPassword     string
+}
+
+// UpdateProfileReq holds a partial profile update: nil fields are left unchanged.
+type UpdateProfileReq struct {
+	FirstName *string
+	LastName  *string
+	TgName    *string
+	BirthDate *time.Time
+	BIO       *string
+}
+
+type LoginMFAReq struct {
+	Token  string
+	Code   string
+	Client ClientInfo
+}
+
+type MFAStatus struct {
+	Enabled           bool
+	Enforced          bool
+	RecoveryCodesLeft int
+}
+
+type TOTPEnrollment struct {
+	Secret string
+	URI    string
+}
+
+// PasskeyCeremony is the first step of a WebAuthn ceremony. Options must be passed
+// to navigator.credentials.create() or get(), the ID must be sent back with the result.
+type PasskeyCeremony struct {
+	ID      string
+	Options any
+}
+
+type FinishPasskeyReq struct {
+	CeremonyID string
+	Name       string
+	Credential []byte
+	Client     ClientInfo
+}
+
+// OAuthStart is the first step of an external login. The user is sent to URL,
+// State must be bound to the browser and compared on the callback.
+type OAuthStart struct {
+	URL       string
+	State     string
+	ExpiresAt time.Time
+}
+
+type OAuthCallbackReq struct {
+	Provider string
+	State    string
+	Code     string
+	Params   url.Values
+	Client   ClientInfo
+}
+
+// OAuthCallbackResp carries either the result of a login or the identity of a link.
+type OAuthCallbackResp struct {
+	Auth     *AuthResp
+	Identity *domain.Identity
+}
+
+type OIDCConfig struct {
+	Issuer     string
+	LoginURL   string
+	ConsentURL string
+	RequestTTL time.Duration
+	CodeTTL    time.Duration
+	AccessTTL  time.Duration
+	IDTokenTTL time.Duration
+}
+
+// LockoutConfig holds the thresholds of LockoutService. Attempts older than Window are forgotten.
+type LockoutConfig struct {
+	AccountThreshold int
+	IPThreshold      int
+	Window           time.Duration
+	LockoutDuration  time.Duration
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+}
+
+// AuthorizeReq is an OpenID Connect authorization request. UserID is empty if the user
+// is not logged in, AuthTime is when the user logged in to the session of the request.
+// RequestURI is the path and query of the request to return to after the login.
+type AuthorizeReq struct {
+	UserID              string
+	AuthTime            time.Time
+	RequestURI          string
+	ClientID            string
+	RedirectURI         string
+	ResponseType        string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Prompt              string
+}
+
+// ConsentInfo is what the consent screen shows the user.
+type ConsentInfo struct {
+	ClientID     string
+	ClientName   string
+	RedirectHost string
+	Scopes       []string
 	ExpiresAt    time.Time
 }
 
+type TokenReq struct {
+	GrantType    string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	ClientID     string
+	ClientSecret string
+}
+
+type TokenResp struct {
+	AccessToken string
+	IDToken     string
+	TokenType   string
+	ExpiresIn   int
+	Scope       string
+}
+
+type CreateClientReq struct {
+	Name         string
+	RedirectURIs []string
+	Scopes       []string
+	Public       bool
+}
+
+// MFAChallenge is returned by Login instead of tokens when a second factor is required.
+type MFAChallenge struct {
+	Token     string
+	Kind      string
+	ExpiresAt time.Time
+}
+
+// AuthResp holds the issued tokens. When MFA is set, the login is not finished yet and there are no tokens.
+type AuthResp struct {
+	AccessToken   string
+	RefreshToken  string
+	ExpiresAt     time.Time
+	MFA           *MFAChallenge
+	RecoveryCodes []string
+}
+
 type Auth interface {
 	Register(ctx context.Context, req *RegisterReq) (*AuthResp, error)
 	Login(ctx context.Context, req *LoginReq) (*AuthResp, error)
-	RefreshToken(ctx context.Context, token string) (*AuthResp, error)
+	LoginMFA(ctx context.Context, req *LoginMFAReq) (*AuthResp, error)
+	RefreshToken(ctx context.Context, token string, client ClientInfo) (*AuthResp, error)
 	Logout(ctx context.Context, token string) error
 }
+
+// TokenIntrospectionReq is a request to the introspection or revocation endpoint.
+type TokenIntrospectionReq struct {
+	Token        string
+	ClientID     string
+	ClientSecret string
+}
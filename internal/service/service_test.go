@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/repository"
+)
+
+// fakeUsers keeps users in memory. Methods a test does not need panic on the nil AuthRepository.
+type fakeUsers struct {
+	repository.AuthRepository
+	users        map[string]*domain.User
+	loginMethods map[string]int
+}
+
+func (u *fakeUsers) FindByEmail(ctx context.Context, email string) (*domain.User, error) {
+	for _, user := range u.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+
+	return nil, domain.ErrUserNotFound
+}
+
+func (u *fakeUsers) FindByID(ctx context.Context, userID string) (*domain.User, error) {
+	user, ok := u.users[userID]
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+func (u *fakeUsers) ExistsByEmail(ctx context.Context, email string) (bool, error) {
+	_, err := u.FindByEmail(ctx, email)
+	return err == nil, nil
+}
+
+func (u *fakeUsers) CountLoginMethods(ctx context.Context, userID string) (int, error) {
+	return u.loginMethods[userID], nil
+}
+
+type fakeSessions struct {
+	repository.SessionRepository
+	saved []domain.Session
+}
+
+func (f *fakeSessions) SaveSession(ctx context.Context, session *domain.Session) error {
+	f.saved = append(f.saved, *session)
+	return nil
+}
+
+type fakeRoles struct {
+	repository.RoleRepository
+}
+
+func (fakeRoles) ListByUserID(ctx context.Context, userID string) ([]string, error) {
+	return nil, nil
+}
+
+// fakeMFA keeps TOTP secrets and challenges in memory. UseStep follows the rule of MFARepo,
+// last_step < step: a step is accepted only if it is later than the last used one.
+type fakeMFA struct {
+	repository.MFARepository
+	totps      map[string]*domain.TOTP
+	challenges []domain.MFAChallenge
+}
+
+func newFakeMFA() *fakeMFA {
+	return &fakeMFA{totps: make(map[string]*domain.TOTP)}
+}
+
+func (f *fakeMFA) GetTOTP(ctx context.Context, userID string) (*domain.TOTP, error) {
+	totp, ok := f.totps[userID]
+	if !ok {
+		return nil, domain.ErrMFANotEnrolled
+	}
+
+	return totp, nil
+}
+
+func (f *fakeMFA) UseStep(ctx context.Context, userID string, step int64) (bool, error) {
+	totp, ok := f.totps[userID]
+	if !ok || totp.LastStep >= step {
+		return false, nil
+	}
+	totp.LastStep = step
+
+	return true, nil
+}
+
+func (f *fakeMFA) ConsumeRecoveryCode(ctx context.Context, userID, code string) error {
+	return domain.ErrInvalidMFACode
+}
+
+func (f *fakeMFA) SaveChallenge(ctx context.Context, challenge *domain.MFAChallenge) error {
+	f.challenges = append(f.challenges, *challenge)
+	return nil
+}
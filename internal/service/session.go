@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/repository"
+)
+
+type SessionService struct {
+	srepo repository.SessionRepository
+}
+
+func NewSessionService(srepo repository.SessionRepository) *SessionService {
+	return &SessionService{srepo: srepo}
+}
+
+// List returns the active sessions of the user and the family id of the session
+// the refresh token belongs to. The id is empty if the token is unknown.
+func (s *SessionService) List(ctx context.Context, userID, refreshToken string) ([]domain.Session, string, error) {
+	sessions, err := s.srepo.ListActiveByUserID(ctx, userID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list user sessions: %w", err)
+	}
+
+	current, err := s.current(ctx, userID, refreshToken)
+	if err != nil && !errors.Is(err, domain.ErrSessionNotFound) {
+		return nil, "", err
+	}
+
+	currentID := ""
+	if current != nil {
+		currentID = current.FamilyID
+	}
+
+	return sessions, currentID, nil
+}
+
+// Revoke logs out the session family with the given id.
+func (s *SessionService) Revoke(ctx context.Context, userID, familyID string) error {
+	return s.srepo.DeleteFamilyByUserID(ctx, userID, familyID)
+}
+
+// RevokeOthers logs out every session of the user except the one the refresh token belongs to.
+func (s *SessionService) RevokeOthers(ctx context.Context, userID, refreshToken string) error {
+	current, err := s.current(ctx, userID, refreshToken)
+	if err != nil {
+		return err
+	}
+
+	if err := s.srepo.DeleteAllByUserIDExcept(ctx, userID, current.ID); err != nil {
+		return fmt.Errorf("failed to delete other user sessions: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SessionService) current(ctx context.Context, userID, refreshToken string) (*domain.Session, error) {
+	if refreshToken == "" {
+		return nil, domain.ErrSessionNotFound
+	}
+
+	session, err := s.srepo.FindByToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if !session.IsActive() || session.UserID != userID {
+		return nil, domain.ErrSessionNotFound
+	}
+
+	return session, nil
+}
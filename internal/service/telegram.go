@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/repository"
+	"github.com/kcthack-auth/pkg/telegram"
+)
+
+type TelegramService struct {
+	repo     repository.AuthRepository
+	trepo    repository.TelegramRepository
+	auth     *AuthService
+	botToken string
+	maxAge   time.Duration
+}
+
+func NewTelegramService(repo repository.AuthRepository, trepo repository.TelegramRepository, auth *AuthService, botToken string, maxAge time.Duration) *TelegramService {
+	return &TelegramService{
+		repo:     repo,
+		trepo:    trepo,
+		auth:     auth,
+		botToken: botToken,
+		maxAge:   maxAge,
+	}
+}
+
+// Login authenticates a user by the Login Widget data. Unknown Telegram users get a new
+// participant account without email and password.
+func (t *TelegramService) Login(ctx context.Context, data telegram.LoginData, client ClientInfo) (*AuthResp, error) {
+	if err := t.verify(ctx, data); err != nil {
+		return nil, err
+	}
+
+	account, err := t.trepo.FindByTelegramID(ctx, data.ID)
+	if errors.Is(err, domain.ErrTelegramNotLinked) {
+		user, err := t.register(ctx, data)
+		if err != nil {
+			return nil, err
+		}
+		return t.auth.completeLogin(ctx, user, client)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find telegram account: %w", err)
+	}
+
+	if account.Username != data.Username {
+		if err := t.trepo.UpdateUsername(ctx, data.ID, data.Username); err != nil {
+			return nil, fmt.Errorf("failed to update telegram username: %w", err)
+		}
+	}
+
+	user, err := t.repo.FindByID(ctx, account.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	return t.auth.completeLogin(ctx, user, client)
+}
+
+// Link attaches the Telegram account to an existing user, e.g. one registered with email and password.
+func (t *TelegramService) Link(ctx context.Context, userID string, data telegram.LoginData) (*domain.TelegramAccount, error) {
+	if err := t.verify(ctx, data); err != nil {
+		return nil, err
+	}
+
+	user, err := t.repo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	account := domain.TelegramAccount{
+		UserID:     user.ID,
+		TelegramID: data.ID,
+		Username:   data.Username,
+		CreatedAt:  time.Now(),
+	}
+
+	if err := t.trepo.Link(ctx, &account); err != nil {
+		if errors.Is(err, domain.ErrTelegramLinked) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to link telegram account: %w", err)
+	}
+
+	if user.TgName == "" && tgNameRegexp.MatchString(data.Username) {
+		user.TgName = data.Username
+		user.UpdatedAt = time.Now()
+		if err := t.repo.Update(ctx, user); err != nil {
+			return nil, fmt.Errorf("failed to update user: %w", err)
+		}
+	}
+
+	return &account, nil
+}
+
+func (t *TelegramService) Get(ctx context.Context, userID string) (*domain.TelegramAccount, error) {
+	return t.trepo.FindByUserID(ctx, userID)
+}
+
+// Unlink removes the Telegram account unless it is the only way for the user to log in.
+func (t *TelegramService) Unlink(ctx context.Context, userID string) error {
+	methods, err := t.repo.CountLoginMethods(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if methods <= 1 {
+		if _, err := t.trepo.FindByUserID(ctx, userID); err != nil {
+			return err
+		}
+		return domain.ErrLastLoginMethod
+	}
+
+	return t.trepo.Unlink(ctx, userID)
+}
+
+// verify checks the signature and age of the login data and consumes it, so data intercepted
+// on the way to the server cannot be replayed while it is still fresh.
+func (t *TelegramService) verify(ctx context.Context, data telegram.LoginData) error {
+	if t.botToken == "" {
+		return domain.ErrTelegramDisabled
+	}
+
+	if err := telegram.Verify(t.botToken, data, t.maxAge, time.Now()); err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrTelegramAuth, err)
+	}
+
+	// The hash is hex and accepted in any case, so it is recorded in one.
+	fresh, err := t.trepo.ConsumeLogin(ctx, strings.ToLower(data.Hash), time.Unix(data.AuthDate, 0).Add(t.maxAge))
+	if err != nil {
+		return fmt.Errorf("failed to consume telegram login data: %w", err)
+	}
+
+	if !fresh {
+		return fmt.Errorf("%w: login data was already used", domain.ErrTelegramAuth)
+	}
+
+	return nil
+}
+
+func (t *TelegramService) register(ctx context.Context, data telegram.LoginData) (*domain.User, error) {
+	user := domain.User{
+		ID:        uuid.NewString(),
+		FirstName: truncate(data.FirstName, maxNameLength),
+		LastName:  truncate(data.LastName, maxNameLength),
+		Role:      domain.Participant,
+		UpdatedAt: time.Now(),
+		CreatedAt: time.Now(),
+	}
+	if tgNameRegexp.MatchString(data.Username) {
+		user.TgName = data.Username
+	}
+
+	account := domain.TelegramAccount{
+		UserID:     user.ID,
+		TelegramID: data.ID,
+		Username:   data.Username,
+		CreatedAt:  time.Now(),
+	}
+
+	if err := t.trepo.CreateUser(ctx, &user, &account); err != nil {
+		return nil, fmt.Errorf("failed to register telegram user %d: %w", data.ID, err)
+	}
+
+	return &user, nil
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/repository"
+)
+
+const (
+	maxNameLength = 32
+	maxBIOLength  = 500
+)
+
+// Telegram usernames are 5-32 characters long, start with a letter and contain only letters, digits and underscores.
+var tgNameRegexp = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{4,31}$`)
+
+type UserService struct {
+	repo repository.AuthRepository
+}
+
+func NewUserService(repo repository.AuthRepository) *UserService {
+	return &UserService{repo: repo}
+}
+
+func (u *UserService) Get(ctx context.Context, userID string) (*domain.User, error) {
+	return u.repo.FindByID(ctx, userID)
+}
+
+func (u *UserService) UpdateProfile(ctx context.Context, userID string, req UpdateProfileReq) (*domain.User, error) {
+	user, err := u.repo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.FirstName != nil {
+		name := strings.TrimSpace(*req.FirstName)
+		if err := validateName("first name", name); err != nil {
+			return nil, err
+		}
+		user.FirstName = name
+	}
+
+	if req.LastName != nil {
+		name := strings.TrimSpace(*req.LastName)
+		if err := validateName("last name", name); err != nil {
+			return nil, err
+		}
+		user.LastName = name
+	}
+
+	if req.TgName != nil {
+		tgName := strings.TrimPrefix(strings.TrimSpace(*req.TgName), "@")
+		if tgName != "" && !tgNameRegexp.MatchString(tgName) {
+			return nil, fmt.Errorf("%w: telegram username must be 5-32 characters long, start with a letter and contain only letters, digits and underscores", domain.ErrValidation)
+		}
+		user.TgName = tgName
+	}
+
+	if req.BirthDate != nil {
+		if req.BirthDate.After(time.Now()) {
+			return nil, fmt.Errorf("%w: birth date cannot be in the future", domain.ErrValidation)
+		}
+		if req.BirthDate.Year() < 1900 {
+			return nil, fmt.Errorf("%w: birth date is too far in the past", domain.ErrValidation)
+		}
+		user.BirthDate = *req.BirthDate
+	}
+
+	if req.BIO != nil {
+		bio := strings.TrimSpace(*req.BIO)
+		if utf8.RuneCountInString(bio) > maxBIOLength {
+			return nil, fmt.Errorf("%w: bio must be at most %d characters long", domain.ErrValidation, maxBIOLength)
+		}
+		user.BIO = bio
+	}
+
+	user.UpdatedAt = time.Now()
+
+	if err := u.repo.Update(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to update user profile: %w", err)
+	}
+
+	return user, nil
+}
+
+func validateName(field, name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: %s cannot be empty", domain.ErrValidation, field)
+	}
+
+	if utf8.RuneCountInString(name) > maxNameLength {
+		return fmt.Errorf("%w: %s must be at most %d characters long", domain.ErrValidation, field, maxNameLength)
+	}
+
+	return nil
+}
+
+// accountName returns a human readable login of the user for authenticator apps.
+// Users registered via Telegram have no email.
+func accountName(user *domain.User) string {
+	switch {
+	case user.Email != "":
+		return user.Email
+	case user.TgName != "":
+		return "@" + user.TgName
+	default:
+		return user.ID
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kcthack-auth/internal/domain"
+	"github.com/kcthack-auth/internal/repository"
+	"github.com/kcthack-auth/pkg/logger"
+	"github.com/kcthack-auth/pkg/mail"
+)
+
+type VerificationService struct {
+	repo     repository.AuthRepository
+	vrepo    repository.VerificationRepository
+	sender   mail.Sender
+	tokenTTL time.Duration
+	url      string
+}
+
+func NewVerificationService(repo repository.AuthRepository, vrepo repository.VerificationRepository, sender mail.Sender, tokenTTL time.Duration, url string) *VerificationService {
+	return &VerificationService{
+		repo:     repo,
+		vrepo:    vrepo,
+		sender:   sender,
+		tokenTTL: tokenTTL,
+		url:      url,
+	}
+}
+
+// Send replaces any pending verification tokens of the user with a new one and mails it.
+func (v *VerificationService) Send(ctx context.Context, user *domain.User) error {
+	if user.IsVerified {
+		return domain.ErrUserAlreadyVerified
+	}
+
+	if err := v.vrepo.DeleteAllByUserID(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to delete old verification tokens: %w", err)
+	}
+
+	token, err := newOneTimeToken()
+	if err != nil {
+		return err
+	}
+
+	if err := v.vrepo.Save(ctx, &domain.VerificationToken{
+		ID:        uuid.NewString(),
+		UserID:    user.ID,
+		Token:     token,
+		ExpiresAt: time.Now().Add(v.tokenTTL),
+	}); err != nil {
+		return fmt.Errorf("failed to save verification token: %w", err)
+	}
+
+	if err := v.sender.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Подтверждение почты КЦТHack",
+		Body:    fmt.Sprintf("Чтобы подтвердить почту, перейдите по ссылке: %s?token=%s", v.url, token),
+	}); err != nil {
+		return fmt.Errorf("failed to send verification email: %w", err)
+	}
+
+	return nil
+}
+
+func (v *VerificationService) Verify(ctx context.Context, token string) error {
+	if token == "" {
+		return domain.ErrInvalidToken
+	}
+
+	userID, err := v.vrepo.Consume(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if err := v.repo.MarkVerified(ctx, userID); err != nil {
+		return fmt.Errorf("failed to mark user as verified: %w", err)
+	}
+
+	return nil
+}
+
+// resendTimeout bounds the background work of a resend request.
+const resendTimeout = 30 * time.Second
+
+// Resend mails a new verification link to the user. The lookup and the mail run in the background and
+// their failures are only logged, so neither the result nor the response time tell whether the address
+// is registered.
+func (v *VerificationService) Resend(ctx context.Context, email string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), resendTimeout)
+
+	go func() {
+		defer cancel()
+
+		if err := v.resend(ctx, email); err != nil {
+			slog.ErrorContext(ctx, "failed to resend verification", logger.Err(err))
+		}
+	}()
+}
+
+// resend sends a verification link to the user of the email. Unknown and already verified emails are ignored.
+func (v *VerificationService) resend(ctx context.Context, email string) error {
+	user, err := v.repo.FindByEmail(ctx, email)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if err := v.Send(ctx, user); err != nil && !errors.Is(err, domain.ErrUserAlreadyVerified) {
+		return err
+	}
+
+	return nil
+}
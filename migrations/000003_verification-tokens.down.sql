@@ -0,0 +1 @@
+DROP TABLE users_verification_tokens;
\ No newline at end of file
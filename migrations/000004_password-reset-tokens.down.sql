@@ -0,0 +1 @@
+DROP TABLE users_password_reset_tokens;
\ No newline at end of file
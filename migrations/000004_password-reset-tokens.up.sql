@@ -0,0 +1,10 @@
+CREATE TABLE users_password_reset_tokens
+(
+    id         uuid                    not null primary key,
+    user_id    uuid                    not null references users (id) on delete cascade,
+    token_hash varchar(255) unique     not null,
+    expires_at timestamp               not null,
+    used_at    timestamp               null,
+    created_at timestamp DEFAULT NOW() not null
+);
+CREATE INDEX userPasswordResetTokensUserID_index ON users_password_reset_tokens (user_id);
@@ -0,0 +1,3 @@
+DROP INDEX userSessionsFamilyID_index;
+ALTER TABLE users_sessions DROP COLUMN rotated_at;
+ALTER TABLE users_sessions DROP COLUMN family_id;
@@ -0,0 +1,5 @@
+ALTER TABLE users_sessions ADD COLUMN family_id uuid null;
+UPDATE users_sessions SET family_id = id;
+ALTER TABLE users_sessions ALTER COLUMN family_id SET NOT NULL;
+ALTER TABLE users_sessions ADD COLUMN rotated_at timestamp null;
+CREATE INDEX userSessionsFamilyID_index ON users_sessions (family_id);
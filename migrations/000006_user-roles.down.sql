@@ -0,0 +1 @@
+DROP TABLE users_roles;
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE users_roles
+(
+    user_id    uuid                    not null references users (id) on delete cascade,
+    role       varchar(50)             not null,
+    created_at timestamp DEFAULT NOW() not null,
+    primary key (user_id, role)
+);
+INSERT INTO users_roles (user_id, role) SELECT id, role FROM users;
@@ -0,0 +1,2 @@
+DROP INDEX userRole_index;
+ALTER TABLE users DROP COLUMN is_blocked;
@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN is_blocked bool default false not null;
+CREATE INDEX userRole_index ON users (role);
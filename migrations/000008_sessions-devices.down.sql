@@ -0,0 +1,4 @@
+ALTER TABLE users_sessions DROP COLUMN last_used_at;
+ALTER TABLE users_sessions DROP COLUMN device;
+ALTER TABLE users_sessions DROP COLUMN ip;
+ALTER TABLE users_sessions DROP COLUMN user_agent;
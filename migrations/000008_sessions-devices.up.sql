@@ -0,0 +1,4 @@
+ALTER TABLE users_sessions ADD COLUMN user_agent   varchar(512) default '' not null;
+ALTER TABLE users_sessions ADD COLUMN ip           varchar(64)  default '' not null;
+ALTER TABLE users_sessions ADD COLUMN device       varchar(128) default '' not null;
+ALTER TABLE users_sessions ADD COLUMN last_used_at timestamp    null;
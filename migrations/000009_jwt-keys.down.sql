@@ -0,0 +1 @@
+DROP TABLE jwt_keys;
\ No newline at end of file
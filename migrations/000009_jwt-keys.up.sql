@@ -0,0 +1,9 @@
+CREATE TABLE jwt_keys
+(
+    id           varchar(64)             not null primary key,
+    algorithm    varchar(16)             not null,
+    material     text                    not null,
+    activates_at timestamp               not null,
+    retires_at   timestamp               null,
+    created_at   timestamp DEFAULT NOW() not null
+);
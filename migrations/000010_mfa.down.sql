@@ -0,0 +1,3 @@
+DROP TABLE users_mfa_challenges;
+DROP TABLE users_recovery_codes;
+DROP TABLE users_totp;
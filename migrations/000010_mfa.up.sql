@@ -0,0 +1,30 @@
+CREATE TABLE users_totp
+(
+    user_id      uuid                    not null primary key references users (id) on delete cascade,
+    secret       varchar(64)             not null,
+    last_step    bigint    default 0     not null,
+    confirmed_at timestamp               null,
+    created_at   timestamp DEFAULT NOW() not null
+);
+
+CREATE TABLE users_recovery_codes
+(
+    id         uuid                    not null primary key,
+    user_id    uuid                    not null references users (id) on delete cascade,
+    code_hash  varchar(255)            not null,
+    used_at    timestamp               null,
+    created_at timestamp DEFAULT NOW() not null
+);
+CREATE INDEX userRecoveryCodesUserID_index ON users_recovery_codes (user_id);
+
+CREATE TABLE users_mfa_challenges
+(
+    id         uuid                    not null primary key,
+    user_id    uuid                    not null references users (id) on delete cascade,
+    token_hash varchar(255) unique     not null,
+    kind       varchar(16)             not null,
+    attempts   int       default 0     not null,
+    expires_at timestamp               not null,
+    created_at timestamp DEFAULT NOW() not null
+);
+CREATE INDEX userMfaChallengesUserID_index ON users_mfa_challenges (user_id);
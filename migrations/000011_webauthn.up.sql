@@ -0,0 +1,22 @@
+CREATE TABLE users_passkeys
+(
+    id            uuid                    not null primary key,
+    user_id       uuid                    not null references users (id) on delete cascade,
+    credential_id varchar(1024) unique    not null,
+    name          varchar(64)             not null,
+    credential    jsonb                   not null,
+    sign_count    bigint    default 0     not null,
+    last_used_at  timestamp               null,
+    created_at    timestamp DEFAULT NOW() not null
+);
+CREATE INDEX userPasskeysUserID_index ON users_passkeys (user_id);
+
+CREATE TABLE webauthn_ceremonies
+(
+    id         uuid                    not null primary key,
+    user_id    uuid                    null references users (id) on delete cascade,
+    kind       varchar(16)             not null,
+    session    jsonb                   not null,
+    expires_at timestamp               not null,
+    created_at timestamp DEFAULT NOW() not null
+);
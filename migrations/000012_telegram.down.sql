@@ -0,0 +1,3 @@
+DROP TABLE users_telegram;
+UPDATE users SET email = id || '@telegram.invalid' WHERE email IS NULL;
+ALTER TABLE users ALTER COLUMN email SET NOT NULL;
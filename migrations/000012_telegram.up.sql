@@ -0,0 +1,9 @@
+ALTER TABLE users ALTER COLUMN email DROP NOT NULL;
+
+CREATE TABLE users_telegram
+(
+    user_id     uuid                    not null primary key references users (id) on delete cascade,
+    telegram_id bigint unique           not null,
+    username    varchar(100)            null,
+    created_at  timestamp DEFAULT NOW() not null
+);
@@ -0,0 +1,23 @@
+CREATE TABLE user_identities
+(
+    id         uuid                    not null primary key,
+    user_id    uuid                    not null references users (id) on delete cascade,
+    provider   varchar(50)             not null,
+    subject    varchar(255)            not null,
+    email      varchar(100)            null,
+    created_at timestamp DEFAULT NOW() not null,
+    UNIQUE (provider, subject),
+    UNIQUE (user_id, provider)
+);
+CREATE INDEX userIdentitiesUserID_index ON user_identities (user_id);
+
+CREATE TABLE oauth_states
+(
+    id         uuid                    not null primary key,
+    token_hash varchar(255) unique     not null,
+    provider   varchar(50)             not null,
+    user_id    uuid                    null references users (id) on delete cascade,
+    verifier   varchar(128)            not null,
+    expires_at timestamp               not null,
+    created_at timestamp DEFAULT NOW() not null
+);
@@ -0,0 +1,3 @@
+DROP TABLE oidc_consents;
+DROP TABLE oidc_authorizations;
+DROP TABLE oidc_clients;
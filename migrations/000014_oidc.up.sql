@@ -0,0 +1,35 @@
+CREATE TABLE oidc_clients
+(
+    id            uuid                    not null primary key,
+    name          varchar(100)            not null,
+    secret_hash   varchar(255)            null,
+    redirect_uris text                    not null,
+    scopes        text                    not null,
+    public        bool      default false not null,
+    created_at    timestamp DEFAULT NOW() not null
+);
+
+CREATE TABLE oidc_authorizations
+(
+    id             uuid                    not null primary key,
+    client_id      uuid                    not null references oidc_clients (id) on delete cascade,
+    user_id        uuid                    not null references users (id) on delete cascade,
+    redirect_uri   text                    not null,
+    scope          text                    not null,
+    state          text                    not null,
+    nonce          text                    not null,
+    code_challenge varchar(128)            not null,
+    code_hash      varchar(255) unique     null,
+    auth_time      timestamp               not null,
+    expires_at     timestamp               not null,
+    created_at     timestamp DEFAULT NOW() not null
+);
+
+CREATE TABLE oidc_consents
+(
+    user_id    uuid                    not null references users (id) on delete cascade,
+    client_id  uuid                    not null references oidc_clients (id) on delete cascade,
+    scope      text                    not null,
+    created_at timestamp DEFAULT NOW() not null,
+    primary key (user_id, client_id)
+);
@@ -0,0 +1,2 @@
+DROP TABLE machine_client_secrets;
+DROP TABLE machine_clients;
@@ -0,0 +1,18 @@
+CREATE TABLE machine_clients
+(
+    id         uuid                    not null primary key,
+    name       varchar(100)            not null,
+    scopes     text                    not null,
+    revoked_at timestamp               null,
+    created_at timestamp DEFAULT NOW() not null
+);
+
+CREATE TABLE machine_client_secrets
+(
+    id          uuid                    not null primary key,
+    client_id   uuid                    not null references machine_clients (id) on delete cascade,
+    secret_hash varchar(255) unique     not null,
+    expires_at  timestamp               null,
+    created_at  timestamp DEFAULT NOW() not null
+);
+CREATE INDEX machineClientSecretsClientID_index ON machine_client_secrets (client_id);
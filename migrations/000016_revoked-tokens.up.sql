@@ -0,0 +1,7 @@
+CREATE TABLE revoked_tokens
+(
+    jti        varchar(64)             not null primary key,
+    expires_at timestamp               not null,
+    created_at timestamp DEFAULT NOW() not null
+);
+CREATE INDEX revokedTokensExpiresAt_index ON revoked_tokens (expires_at);
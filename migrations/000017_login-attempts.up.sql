@@ -0,0 +1,8 @@
+CREATE TABLE login_attempts
+(
+    key             varchar(320)  not null primary key,
+    attempts        int default 0 not null,
+    last_attempt_at timestamp     not null,
+    locked_until    timestamp     null
+);
+CREATE INDEX loginAttemptsLastAttemptAt_index ON login_attempts (last_attempt_at);
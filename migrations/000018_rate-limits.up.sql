@@ -0,0 +1,9 @@
+CREATE TABLE rate_limits
+(
+    key        varchar(512)                   not null primary key,
+    value      double precision DEFAULT 0     not null,
+    prev       double precision DEFAULT 0     not null,
+    start      timestamp                      null,
+    expires_at timestamp                      not null
+);
+CREATE INDEX rateLimitsExpiresAt_index ON rate_limits (expires_at);
@@ -0,0 +1 @@
+ALTER TABLE users_sessions DROP COLUMN auth_time;
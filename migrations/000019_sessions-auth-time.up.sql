@@ -0,0 +1,3 @@
+ALTER TABLE users_sessions ADD COLUMN auth_time timestamp null;
+UPDATE users_sessions s SET auth_time = (SELECT MIN(f.created_at) FROM users_sessions f WHERE f.family_id = s.family_id);
+ALTER TABLE users_sessions ALTER COLUMN auth_time SET NOT NULL;
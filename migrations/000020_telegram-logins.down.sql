@@ -0,0 +1 @@
+DROP TABLE telegram_logins;
@@ -0,0 +1,7 @@
+CREATE TABLE telegram_logins
+(
+    hash       varchar(64)             not null primary key,
+    expires_at timestamp               not null,
+    created_at timestamp DEFAULT NOW() not null
+);
+CREATE INDEX telegramLoginsExpiresAt_index ON telegram_logins (expires_at);
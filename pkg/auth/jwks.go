@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWK is a public key in the RFC 7517 format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK returns the public part of the key. HMAC keys have no public part.
+func (k *Key) JWK() (JWK, bool) {
+	jwk := JWK{
+		Kid: k.ID,
+		Use: "sig",
+		Alg: k.Method.Alg(),
+	}
+
+	switch pub := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	default:
+		return JWK{}, false
+	}
+
+	return jwk, true
+}
+
+// publicKey converts the JWK back into a verification key.
+func (j JWK) publicKey() (jwt.SigningMethod, any, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid modulus of key %s: %w", j.Kid, err)
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid exponent of key %s: %w", j.Kid, err)
+		}
+
+		return jwt.SigningMethodRS256, &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, nil, fmt.Errorf("unsupported curve %s of key %s", j.Crv, j.Kid)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, nil, fmt.Errorf("invalid public key %s", j.Kid)
+		}
+
+		return jwt.SigningMethodEdDSA, ed25519.PublicKey(x), nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported key type %s of key %s", j.Kty, j.Kid)
+	}
+}
+
+// Verifier validates access tokens using only public keys, so services using it cannot mint tokens.
+type Verifier struct {
+	keys map[string]*Key
+}
+
+func NewVerifier(set JWKSet) (*Verifier, error) {
+	v := Verifier{keys: make(map[string]*Key, len(set.Keys))}
+
+	for _, jwk := range set.Keys {
+		method, pub, err := jwk.publicKey()
+		if err != nil {
+			return nil, err
+		}
+		v.keys[jwk.Kid] = &Key{ID: jwk.Kid, Method: method, verifyKey: pub}
+	}
+
+	if len(v.keys) == 0 {
+		return nil, errors.New("jwk set has no keys")
+	}
+
+	return &v, nil
+}
+
+// FetchJWKS downloads a JWK set, usually from the /.well-known/jwks.json endpoint of this service.
+func FetchJWKS(ctx context.Context, url string) (JWKSet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return JWKSet{}, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return JWKSet{}, fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return JWKSet{}, fmt.Errorf("failed to fetch jwks: unexpected status %d", resp.StatusCode)
+	}
+
+	var set JWKSet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return JWKSet{}, fmt.Errorf("failed to decode jwks: %w", err)
+	}
+
+	return set, nil
+}
+
+func (v *Verifier) Validate(tokenString string) (*TokenClaims, error) {
+	return parseToken(tokenString, func(kid string) (*Key, bool) {
+		key, ok := v.keys[kid]
+		return key, ok
+	})
+}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key is a signing key identified by the kid header of the tokens it signs.
+// A key signs tokens from NotBefore and is accepted for validation until NotAfter.
+// Zero times mean no limit.
+type Key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	NotBefore time.Time
+	NotAfter  time.Time
+	signKey   any
+	verifyKey any
+}
+
+// NewHMACKey creates an HS256 key from a shared secret. Tokens signed with it
+// can only be verified by services that know the secret.
+func NewHMACKey(id, secret string) *Key {
+	return &Key{
+		ID:        id,
+		Method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}
+}
+
+// NewRSAKey creates an RS256 key.
+func NewRSAKey(id string, privateKey *rsa.PrivateKey) *Key {
+	return &Key{
+		ID:        id,
+		Method:    jwt.SigningMethodRS256,
+		signKey:   privateKey,
+		verifyKey: &privateKey.PublicKey,
+	}
+}
+
+// NewEd25519Key creates an EdDSA key.
+func NewEd25519Key(id string, privateKey ed25519.PrivateKey) *Key {
+	return &Key{
+		ID:        id,
+		Method:    jwt.SigningMethodEdDSA,
+		signKey:   privateKey,
+		verifyKey: privateKey.Public(),
+	}
+}
+
+// ParsePrivateKeyPEM parses an RSA (PKCS#1 or PKCS#8) or Ed25519 (PKCS#8) private key.
+// The signing algorithm is derived from the key type.
+func ParsePrivateKeyPEM(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("failed to decode PEM block")
+	}
+
+	if block.Type == "RSA PRIVATE KEY" {
+		privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse PKCS#1 key: %w", err)
+		}
+		return NewRSAKey(id, privateKey), nil
+	}
+
+	privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse PKCS#8 key: %w", err)
+	}
+
+	switch k := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return NewRSAKey(id, k), nil
+	case ed25519.PrivateKey:
+		return NewEd25519Key(id, k), nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", privateKey)
+	}
+}
+
+// LoadPrivateKeyFile reads a private key in PEM format from path.
+func LoadPrivateKeyFile(id, path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	return ParsePrivateKeyPEM(id, data)
+}
+
+// GenerateKey creates a new key for the algorithm, RS256 or EdDSA.
+func GenerateKey(id, alg string) (*Key, error) {
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate rsa key: %w", err)
+		}
+		return NewRSAKey(id, privateKey), nil
+	case jwt.SigningMethodEdDSA.Alg():
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate ed25519 key: %w", err)
+		}
+		return NewEd25519Key(id, privateKey), nil
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", alg)
+	}
+}
+
+// Export returns the algorithm and the private material of the key:
+// the secret for HS256 and a PKCS#8 PEM block otherwise.
+func (k *Key) Export() (string, []byte, error) {
+	if secret, ok := k.signKey.([]byte); ok {
+		return k.Method.Alg(), secret, nil
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(k.signKey)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+
+	return k.Method.Alg(), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// ImportKey is the inverse of Export.
+func ImportKey(id, alg string, material []byte) (*Key, error) {
+	if alg == jwt.SigningMethodHS256.Alg() {
+		return NewHMACKey(id, string(material)), nil
+	}
+
+	key, err := ParsePrivateKeyPEM(id, material)
+	if err != nil {
+		return nil, err
+	}
+
+	if key.Method.Alg() != alg {
+		return nil, fmt.Errorf("key %s is %s, not %s", id, key.Method.Alg(), alg)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// KeyRing holds every key that is currently trusted. The active key is the most recently
+// activated one, older keys keep validating tokens until their NotAfter passes.
+// This lets a new key be published to all replicas before anyone signs with it.
+type KeyRing struct {
+	mu   sync.RWMutex
+	keys []*Key
+}
+
+func NewKeyRing(keys ...*Key) *KeyRing {
+	return &KeyRing{keys: keys}
+}
+
+// Set replaces all keys of the ring.
+func (r *KeyRing) Set(keys []*Key) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.keys = keys
+}
+
+// Active returns the key new tokens must be signed with, or nil if there is none.
+func (r *KeyRing) Active() *Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+
+	var active *Key
+	for _, key := range r.keys {
+		if key.NotBefore.After(now) || key.retired(now) {
+			continue
+		}
+
+		if active == nil || key.NotBefore.After(active.NotBefore) {
+			active = key
+		}
+	}
+
+	return active
+}
+
+// Lookup returns a non-retired key by id. Keys that are not active yet are returned as well,
+// since another replica may already have started signing with them.
+func (r *KeyRing) Lookup(kid string) (*Key, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	for _, key := range r.keys {
+		if key.ID == kid && !key.retired(now) {
+			return key, true
+		}
+	}
+
+	return nil, false
+}
+
+// Keys returns every non-retired key.
+func (r *KeyRing) Keys() []*Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+
+	keys := make([]*Key, 0, len(r.keys))
+	for _, key := range r.keys {
+		if !key.retired(now) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+func (k *Key) retired(now time.Time) bool {
+	return !k.NotAfter.IsZero() && !now.Before(k.NotAfter)
+}
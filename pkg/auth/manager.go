@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"time"
@@ -9,35 +10,122 @@ import (
 	"github.com/google/uuid"
 )
 
+// TokenValidator is all a service needs to authenticate requests with access tokens.
+type TokenValidator interface {
+	Validate(ctx context.Context, tokenString string) (*TokenClaims, error)
+}
+
 type JWTManager interface {
-	NewAccess(userID, role string, ttl time.Duration) (string, error)
+	TokenValidator
+	NewAccess(claims TokenClaims, ttl time.Duration) (string, error)
 	NewRefresh() string
-	Validate(tokenString string) (*TokenClaims, error)
+	Sign(claims map[string]any, ttl time.Duration) (string, error)
+	JWKS() JWKSet
 }
 
 type Manager struct {
-	signingKey string
+	ring *KeyRing
 }
 
+// Subject types of access tokens, carried in the sub_type claim.
+const (
+	SubjectUser   = "user"
+	SubjectClient = "client"
+)
+
+// TokenClaims are the claims of an access token. ID and ExpAt are filled only by Validate.
+// ClientID and Scope are set on tokens issued to OpenID Connect clients on behalf of the user
+// and on tokens of machine clients, which have the SubjectClient type and no user fields.
+// SessionID is the session family a token of the user's own session was issued for and AuthTime
+// the time the user logged in to it, carried in the auth_time claim.
+// Email is empty for users without one.
 type TokenClaims struct {
-	UserID string
-	Role   string
-	ExpAt  float64
+	ID          string
+	SubjectType string
+	SessionID   string
+	AuthTime    time.Time
+	UserID      string
+	Email       string
+	Role        string
+	Roles       []string
+	Verified    bool
+	ClientID    string
+	Scope       string
+	ExpAt       float64
 }
 
-func NewManager(signingKey string) *Manager {
-	return &Manager{signingKey: signingKey}
+func NewManager(ring *KeyRing) *Manager {
+	return &Manager{ring: ring}
 }
 
-func (m *Manager) NewAccess(userID, role string, ttl time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
-		"role":    role,
-		"exp":     time.Now().Add(ttl).Unix(),
-		"iat":     time.Now().Unix(),
-	})
+func (m *Manager) NewAccess(claims TokenClaims, ttl time.Duration) (string, error) {
+	key := m.ring.Active()
+	if key == nil {
+		return "", errors.New("no active signing key")
+	}
+
+	if claims.SubjectType == SubjectClient {
+		return sign(key, jwt.MapClaims{
+			"jti":       uuid.NewString(),
+			"sub":       SubjectClient + ":" + claims.ClientID,
+			"sub_type":  SubjectClient,
+			"client_id": claims.ClientID,
+			"scope":     claims.Scope,
+			"exp":       time.Now().Add(ttl).Unix(),
+			"iat":       time.Now().Unix(),
+		})
+	}
+
+	mapClaims := jwt.MapClaims{
+		"jti":      uuid.NewString(),
+		"sub":      claims.UserID,
+		"sub_type": SubjectUser,
+		"user_id":  claims.UserID,
+		"role":     claims.Role,
+		"roles":    claims.Roles,
+		"verified": claims.Verified,
+		"exp":      time.Now().Add(ttl).Unix(),
+		"iat":      time.Now().Unix(),
+	}
+	if claims.SessionID != "" {
+		mapClaims["sid"] = claims.SessionID
+	}
+	if !claims.AuthTime.IsZero() {
+		mapClaims["auth_time"] = claims.AuthTime.Unix()
+	}
+	if claims.Email != "" {
+		mapClaims["email"] = claims.Email
+	}
+	if claims.ClientID != "" {
+		mapClaims["client_id"] = claims.ClientID
+		mapClaims["scope"] = claims.Scope
+	}
+
+	return sign(key, mapClaims)
+}
+
+// Sign signs arbitrary claims, e.g. of an ID token, with the active key. exp and iat are set by Sign.
+func (m *Manager) Sign(claims map[string]any, ttl time.Duration) (string, error) {
+	key := m.ring.Active()
+	if key == nil {
+		return "", errors.New("no active signing key")
+	}
+
+	mapClaims := jwt.MapClaims{}
+	for k, v := range claims {
+		mapClaims[k] = v
+	}
+	mapClaims["exp"] = time.Now().Add(ttl).Unix()
+	mapClaims["iat"] = time.Now().Unix()
+
+	return sign(key, mapClaims)
+}
+
+func sign(key *Key, claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
 
-	tokenString, err := token.SignedString([]byte(m.signingKey))
+	tokenString, err := token.SignedString(key.signKey)
 	if err != nil {
 		return "", err
 	}
@@ -50,10 +138,46 @@ func (m *Manager) NewRefresh() string {
 	return token
 }
 
-func (m *Manager) Validate(tokenString string) (*TokenClaims, error) {
+// Validate checks the signature and claims of the token. It does not know about revocations,
+// see RevocationManager.
+func (m *Manager) Validate(_ context.Context, tokenString string) (*TokenClaims, error) {
+	return parseToken(tokenString, func(kid string) (*Key, bool) {
+		// Tokens issued before kid headers were introduced have none.
+		if kid == "" {
+			key := m.ring.Active()
+			return key, key != nil
+		}
+		return m.ring.Lookup(kid)
+	})
+}
+
+// JWKS returns the public keys of the manager. Keys with a shared secret are never published.
+func (m *Manager) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range m.ring.Keys() {
+		if jwk, ok := key.JWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+
+	return set
+}
+
+func parseToken(tokenString string, lookup func(kid string) (*Key, bool)) (*TokenClaims, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
-		return []byte(m.signingKey), nil
-	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+		kid, _ := token.Header["kid"].(string)
+
+		key, ok := lookup(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s for key %q", token.Method.Alg(), kid)
+		}
+
+		return key.verifyKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
 	}
@@ -63,6 +187,31 @@ func (m *Manager) Validate(tokenString string) (*TokenClaims, error) {
 		return nil, errors.New("failed to get claims")
 	}
 
+	expAt, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errors.New("invalid exp claim")
+	}
+
+	// Tokens issued before jti was introduced have none.
+	jti, _ := claims["jti"].(string)
+
+	if subType, _ := claims["sub_type"].(string); subType == SubjectClient {
+		clientID, ok := claims["client_id"].(string)
+		if !ok || clientID == "" {
+			return nil, errors.New("invalid client_id claim")
+		}
+
+		scope, _ := claims["scope"].(string)
+
+		return &TokenClaims{
+			ID:          jti,
+			SubjectType: SubjectClient,
+			ClientID:    clientID,
+			Scope:       scope,
+			ExpAt:       expAt,
+		}, nil
+	}
+
 	userID, ok := claims["user_id"].(string)
 	if !ok {
 		return nil, errors.New("invalid userID claim")
@@ -73,15 +222,64 @@ func (m *Manager) Validate(tokenString string) (*TokenClaims, error) {
 		return nil, errors.New("invalid role claim")
 	}
 
-	expAt, ok := claims["exp"].(float64)
+	roles, err := parseRoles(claims["roles"], role)
+	if err != nil {
+		return nil, err
+	}
+
+	verified, ok := claims["verified"].(bool)
 	if !ok {
-		return nil, errors.New("invalid exp claim")
+		return nil, errors.New("invalid verified claim")
+	}
+
+	// Tokens issued before auth_time was introduced have none.
+	var authTime time.Time
+	if value, ok := claims["auth_time"].(float64); ok {
+		authTime = time.Unix(int64(value), 0)
 	}
 
+	// Both are optional, tokens of the user's own sessions have neither.
+	clientID, _ := claims["client_id"].(string)
+	scope, _ := claims["scope"].(string)
+	sessionID, _ := claims["sid"].(string)
+	email, _ := claims["email"].(string)
+
 	tokenClaims := TokenClaims{
-		UserID: userID,
-		Role:   role,
-		ExpAt:  expAt,
+		ID:          jti,
+		SubjectType: SubjectUser,
+		SessionID:   sessionID,
+		AuthTime:    authTime,
+		UserID:      userID,
+		Email:       email,
+		Role:        role,
+		Roles:       roles,
+		Verified:    verified,
+		ClientID:    clientID,
+		Scope:       scope,
+		ExpAt:       expAt,
 	}
 	return &tokenClaims, nil
 }
+
+// parseRoles reads the roles claim. Tokens issued without it carry only the primary role.
+func parseRoles(value any, role string) ([]string, error) {
+	if value == nil {
+		return []string{role}, nil
+	}
+
+	list, ok := value.([]any)
+	if !ok {
+		return nil, errors.New("invalid roles claim")
+	}
+
+	roles := make([]string, 0, len(list))
+	for _, item := range list {
+		r, ok := item.(string)
+		if !ok {
+			return nil, errors.New("invalid roles claim")
+		}
+		roles = append(roles, r)
+	}
+
+	return roles, nil
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// RevocationChecker tells whether a token with a valid signature is still active, e.g. not
+// revoked and not issued for a session that was logged out since.
+type RevocationChecker interface {
+	Active(ctx context.Context, claims *TokenClaims) (bool, error)
+}
+
+// RevocationManager is a JWTManager whose Validate also rejects tokens the checker reports
+// inactive. Everything that authenticates requests should use it instead of the bare Manager.
+type RevocationManager struct {
+	JWTManager
+	checker RevocationChecker
+}
+
+func NewRevocationManager(manager JWTManager, checker RevocationChecker) *RevocationManager {
+	return &RevocationManager{
+		JWTManager: manager,
+		checker:    checker,
+	}
+}
+
+// Validate fails closed: a token is rejected if the checker cannot tell whether it is active.
+func (r *RevocationManager) Validate(ctx context.Context, tokenString string) (*TokenClaims, error) {
+	claims, err := r.JWTManager.Validate(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	active, err := r.checker.Active(ctx, claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+
+	if !active {
+		return nil, ErrTokenRevoked
+	}
+
+	return claims, nil
+}
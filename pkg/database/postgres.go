@@ -1,24 +1,64 @@
 package database
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"log/slog"
+	"os"
 
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5/tracelog"
 	"github.com/kcthack-auth/internal/config"
+	"github.com/kcthack-auth/pkg/logger"
 )
 
 func ConnDB(cfg *config.Config) *sql.DB {
-	db, err := sql.Open("pgx", cfg.Database.DSN)
+	connConfig, err := pgx.ParseConfig(cfg.Database.DSN)
 	if err != nil {
-		log.Fatalf("failed to connect to PostgreSQL: %s", err.Error())
+		slog.Error("failed to parse database dsn", logger.Err(err))
+		os.Exit(1)
 	}
 
+	// Every query of the repositories is logged with the fields of its request context.
+	connConfig.Tracer = &tracelog.TraceLog{
+		Logger:   tracelog.LoggerFunc(logQuery),
+		LogLevel: tracelog.LogLevelInfo,
+	}
+
+	db := stdlib.OpenDB(*connConfig)
+
 	if err = db.Ping(); err != nil {
-		log.Fatalf("failed to ping database: %s", err.Error())
+		slog.Error("failed to ping database", logger.Err(err))
+		os.Exit(1)
 	}
 
-	log.Println("Successfully connected to DB!")
+	slog.Info("connected to database")
 
 	return db
 }
+
+// logQuery logs failed queries as warnings and all others at debug level. The arguments are left
+// out, they hold password hashes and tokens that the key based redaction cannot recognize.
+func logQuery(ctx context.Context, level tracelog.LogLevel, msg string, data map[string]any) {
+	attrs := make([]slog.Attr, 0, len(data))
+	for key, value := range data {
+		switch key {
+		case "args":
+			continue
+		case "err":
+			if err, ok := value.(error); ok {
+				attrs = append(attrs, logger.Err(err))
+				continue
+			}
+		}
+		attrs = append(attrs, slog.Any(key, value))
+	}
+
+	slogLevel := slog.LevelDebug
+	if level <= tracelog.LogLevelWarn {
+		slogLevel = slog.LevelWarn
+	}
+
+	slog.LogAttrs(ctx, slogLevel, "database: "+msg, attrs...)
+}
@@ -0,0 +1,135 @@
+package hasher
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testArgon2idParams are cheap parameters, so the tests run fast.
+var testArgon2idParams = Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func newTestArgon2id(t *testing.T, params Argon2idParams) *Argon2idHasher {
+	t.Helper()
+
+	h, err := NewArgon2idHasher(params)
+	if err != nil {
+		t.Fatalf("new argon2id hasher: %v", err)
+	}
+
+	return h
+}
+
+func TestArgon2idRoundTrip(t *testing.T) {
+	h := newTestArgon2id(t, testArgon2idParams)
+
+	hash, err := h.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("hash %q is not in the PHC format with the parameters", hash)
+	}
+
+	if ok, err := h.Verify("correct horse", hash); err != nil || !ok {
+		t.Errorf("verify the password: %t, %v", ok, err)
+	}
+	if ok, err := h.Verify("wrong horse", hash); err != nil || ok {
+		t.Errorf("verify another password: %t, %v", ok, err)
+	}
+
+	other, _ := h.Hash("correct horse")
+	if other == hash {
+		t.Error("two hashes of the same password are equal, the salt is not random")
+	}
+}
+
+func TestArgon2idVerifyUsesParamsOfHash(t *testing.T) {
+	old := newTestArgon2id(t, testArgon2idParams)
+	hash, _ := old.Hash("password")
+
+	stronger := testArgon2idParams
+	stronger.Iterations = 2
+	h := newTestArgon2id(t, stronger)
+
+	if ok, err := h.Verify("password", hash); err != nil || !ok {
+		t.Errorf("verify a hash made with other parameters: %t, %v", ok, err)
+	}
+}
+
+func TestArgon2idInvalidHash(t *testing.T) {
+	h := newTestArgon2id(t, testArgon2idParams)
+
+	tests := map[string]string{
+		"empty":             "",
+		"other algorithm":   "$argon2i$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5a2V5a2V5",
+		"missing part":      "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ",
+		"bad version":       "$argon2id$version$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5a2V5a2V5",
+		"other version":     "$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5a2V5a2V5",
+		"bad params":        "$argon2id$v=19$m=64;t=1;p=1$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5a2V5a2V5",
+		"bad salt":          "$argon2id$v=19$m=64,t=1,p=1$!!!$a2V5a2V5a2V5a2V5a2V5a2V5",
+		"bad key":           "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$!!!",
+		"empty key":         "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$",
+		"padded base64 key": "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5a2V5a2V5==",
+	}
+
+	for name, hash := range tests {
+		t.Run(name, func(t *testing.T) {
+			if ok, err := h.Verify("password", hash); err == nil || ok {
+				t.Errorf("verify: %t, %v, want an error", ok, err)
+			}
+			if !h.NeedsRehash(hash) {
+				t.Error("an invalid hash does not need a rehash")
+			}
+		})
+	}
+
+	if _, err := h.Verify("password", tests["bad salt"]); !errors.Is(err, errInvalidArgon2idHash) {
+		t.Errorf("bad salt: got %v, want %v", err, errInvalidArgon2idHash)
+	}
+}
+
+func TestArgon2idNeedsRehash(t *testing.T) {
+	h := newTestArgon2id(t, testArgon2idParams)
+	hash, _ := h.Hash("password")
+
+	if h.NeedsRehash(hash) {
+		t.Error("a hash with the configured parameters needs a rehash")
+	}
+
+	changes := map[string]func(p *Argon2idParams){
+		"memory":      func(p *Argon2idParams) { p.Memory = 128 },
+		"iterations":  func(p *Argon2idParams) { p.Iterations = 2 },
+		"parallelism": func(p *Argon2idParams) { p.Parallelism = 2 },
+		"salt length": func(p *Argon2idParams) { p.SaltLength = 8 },
+		"key length":  func(p *Argon2idParams) { p.KeyLength = 16 },
+	}
+
+	for name, change := range changes {
+		t.Run(name, func(t *testing.T) {
+			params := testArgon2idParams
+			change(&params)
+
+			if !newTestArgon2id(t, params).NeedsRehash(hash) {
+				t.Errorf("hash with another %s does not need a rehash", name)
+			}
+		})
+	}
+}
+
+func TestNewArgon2idHasherParams(t *testing.T) {
+	tests := map[string]Argon2idParams{
+		"no iterations":     {Memory: 64, Iterations: 0, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		"no threads":        {Memory: 64, Iterations: 1, Parallelism: 0, SaltLength: 16, KeyLength: 32},
+		"too little memory": {Memory: 15, Iterations: 1, Parallelism: 2, SaltLength: 16, KeyLength: 32},
+		"short salt":        {Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 4, KeyLength: 32},
+		"short key":         {Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 8},
+	}
+
+	for name, params := range tests {
+		if _, err := NewArgon2idHasher(params); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package hasher
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestBcryptRoundTrip(t *testing.T) {
+	h, err := NewBcryptHasher(bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("new bcrypt hasher: %v", err)
+	}
+
+	hash, err := h.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+
+	if ok, err := h.Verify("correct horse", hash); err != nil || !ok {
+		t.Errorf("verify the password: %t, %v", ok, err)
+	}
+	if ok, err := h.Verify("wrong horse", hash); err != nil || ok {
+		t.Errorf("verify another password: %t, %v", ok, err)
+	}
+	if ok, err := h.Verify("correct horse", "$2a$04$short"); err == nil || ok {
+		t.Errorf("verify a malformed hash: %t, %v, want an error", ok, err)
+	}
+}
+
+func TestBcryptNeedsRehash(t *testing.T) {
+	h, _ := NewBcryptHasher(bcrypt.MinCost + 1)
+	hash, _ := h.Hash("password")
+
+	if h.NeedsRehash(hash) {
+		t.Error("a hash with the configured cost needs a rehash")
+	}
+
+	for _, cost := range []int{bcrypt.MinCost, bcrypt.MinCost + 2} {
+		other, _ := NewBcryptHasher(cost)
+		if !other.NeedsRehash(hash) {
+			t.Errorf("a hash of cost %d does not need a rehash at cost %d", bcrypt.MinCost+1, cost)
+		}
+	}
+
+	if !h.NeedsRehash("not a hash") {
+		t.Error("an invalid hash does not need a rehash")
+	}
+}
+
+func TestNewBcryptHasherCost(t *testing.T) {
+	for _, cost := range []int{bcrypt.MinCost - 1, bcrypt.MaxCost + 1} {
+		if _, err := NewBcryptHasher(cost); err == nil {
+			t.Errorf("cost %d: no error", cost)
+		}
+	}
+}
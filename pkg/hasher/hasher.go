@@ -0,0 +1,104 @@
+// Package hasher hashes passwords with bcrypt or argon2id. Argon2id hashes use the PHC string
+// format, bcrypt hashes the usual $2a$ one, so the algorithm and parameters are read from the hash.
+package hasher
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+// PasswordHasher hashes new passwords and checks passwords against stored hashes.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify reports whether the password matches the hash.
+	Verify(password, hash string) (bool, error)
+	// NeedsRehash reports whether the hash was made with another algorithm or other parameters
+	// than new hashes are, so it should be replaced after the next successful login.
+	NeedsRehash(hash string) bool
+}
+
+type Config struct {
+	Algorithm  string
+	BcryptCost int
+	Argon2id   Argon2idParams
+}
+
+// Hasher hashes with the configured algorithm and verifies hashes of every supported one,
+// so the algorithm can be changed without invalidating existing passwords.
+type Hasher struct {
+	algorithm string
+	bcrypt    *BcryptHasher
+	argon2id  *Argon2idHasher
+}
+
+func New(cfg Config) (*Hasher, error) {
+	if cfg.Algorithm != AlgorithmBcrypt && cfg.Algorithm != AlgorithmArgon2id {
+		return nil, fmt.Errorf("unsupported password hashing algorithm %q", cfg.Algorithm)
+	}
+
+	bcryptHasher, err := NewBcryptHasher(cfg.BcryptCost)
+	if err != nil {
+		return nil, err
+	}
+
+	argon2idHasher, err := NewArgon2idHasher(cfg.Argon2id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Hasher{
+		algorithm: cfg.Algorithm,
+		bcrypt:    bcryptHasher,
+		argon2id:  argon2idHasher,
+	}, nil
+}
+
+func (h *Hasher) Hash(password string) (string, error) {
+	return h.primary().Hash(password)
+}
+
+func (h *Hasher) Verify(password, hash string) (bool, error) {
+	hasher, ok := h.lookup(hash)
+	if !ok {
+		return false, ErrUnknownHash
+	}
+
+	return hasher.Verify(password, hash)
+}
+
+func (h *Hasher) NeedsRehash(hash string) bool {
+	hasher, ok := h.lookup(hash)
+	if !ok || hasher != h.primary() {
+		return true
+	}
+
+	return hasher.NeedsRehash(hash)
+}
+
+func (h *Hasher) primary() PasswordHasher {
+	if h.algorithm == AlgorithmArgon2id {
+		return h.argon2id
+	}
+
+	return h.bcrypt
+}
+
+// lookup returns the hasher that made the hash.
+func (h *Hasher) lookup(hash string) (PasswordHasher, bool) {
+	switch {
+	case strings.HasPrefix(hash, "$"+AlgorithmArgon2id+"$"):
+		return h.argon2id, true
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return h.bcrypt, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,79 @@
+package hasher
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestHasher(t *testing.T, algorithm string) *Hasher {
+	t.Helper()
+
+	h, err := New(Config{Algorithm: algorithm, BcryptCost: bcrypt.MinCost, Argon2id: testArgon2idParams})
+	if err != nil {
+		t.Fatalf("new hasher: %v", err)
+	}
+
+	return h
+}
+
+func TestHasherCrossAlgorithm(t *testing.T) {
+	bcryptHasher := newTestHasher(t, AlgorithmBcrypt)
+	argon2idHasher := newTestHasher(t, AlgorithmArgon2id)
+
+	bcryptHash, _ := bcryptHasher.Hash("password")
+	argon2idHash, _ := argon2idHasher.Hash("password")
+
+	if !strings.HasPrefix(bcryptHash, "$2a$") || !strings.HasPrefix(argon2idHash, "$argon2id$") {
+		t.Fatalf("hashes %q and %q are not of the configured algorithms", bcryptHash, argon2idHash)
+	}
+
+	// Either hasher verifies the hashes of both algorithms, so the algorithm can be switched.
+	for _, h := range []*Hasher{bcryptHasher, argon2idHasher} {
+		for _, hash := range []string{bcryptHash, argon2idHash} {
+			if ok, err := h.Verify("password", hash); err != nil || !ok {
+				t.Errorf("%s hasher, verify %q: %t, %v", h.algorithm, hash, ok, err)
+			}
+			if ok, err := h.Verify("other", hash); err != nil || ok {
+				t.Errorf("%s hasher, verify another password against %q: %t, %v", h.algorithm, hash, ok, err)
+			}
+		}
+	}
+
+	tests := []struct {
+		hasher *Hasher
+		hash   string
+		want   bool
+	}{
+		{hasher: bcryptHasher, hash: bcryptHash, want: false},
+		{hasher: bcryptHasher, hash: argon2idHash, want: true},
+		{hasher: argon2idHasher, hash: argon2idHash, want: false},
+		{hasher: argon2idHasher, hash: bcryptHash, want: true},
+	}
+	for _, tt := range tests {
+		if got := tt.hasher.NeedsRehash(tt.hash); got != tt.want {
+			t.Errorf("%s hasher, NeedsRehash(%q) = %t, want %t", tt.hasher.algorithm, tt.hash, got, tt.want)
+		}
+	}
+}
+
+func TestHasherUnknownHash(t *testing.T) {
+	h := newTestHasher(t, AlgorithmArgon2id)
+
+	for _, hash := range []string{"", "plain", "$scrypt$ln=15,r=8,p=1$c2FsdA$a2V5", "$1$salt$md5hash"} {
+		if _, err := h.Verify("password", hash); !errors.Is(err, ErrUnknownHash) {
+			t.Errorf("verify %q: got %v, want %v", hash, err, ErrUnknownHash)
+		}
+		if !h.NeedsRehash(hash) {
+			t.Errorf("unknown hash %q does not need a rehash", hash)
+		}
+	}
+}
+
+func TestNewUnknownAlgorithm(t *testing.T) {
+	if _, err := New(Config{Algorithm: "md5", BcryptCost: bcrypt.MinCost, Argon2id: testArgon2idParams}); err == nil {
+		t.Error("no error for an unknown algorithm")
+	}
+}
@@ -1 +1,123 @@
+// Package logger builds the structured logger of the service on log/slog. Records logged with
+// a context carry the fields added to it by With, e.g. the request id, and values of fields
+// that look like credentials are never written.
 package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// redacted replaces the values of sensitive fields.
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are parts of field names whose values are redacted, compared in lowercase.
+var sensitiveKeys = []string{"password", "pass_hash", "token", "secret", "authorization", "cookie"}
+
+type Config struct {
+	// Level is one of debug, info, warn and error.
+	Level string
+	// Format is json or text.
+	Format string
+}
+
+// New returns a logger writing to w.
+func New(cfg Config, w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", cfg.Level)
+	}
+
+	opts := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	}
+
+	var handler slog.Handler
+	switch cfg.Format {
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	case FormatText:
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q", cfg.Format)
+	}
+
+	return slog.New(&contextHandler{Handler: handler}), nil
+}
+
+type fieldsKey struct{}
+
+// With returns a copy of ctx whose records get the fields, given as in slog.Logger.With.
+// Fields added to ctx before are kept.
+func With(ctx context.Context, args ...any) context.Context {
+	record := slog.Record{}
+	record.Add(args...)
+
+	fields := append([]slog.Attr{}, contextFields(ctx)...)
+	record.Attrs(func(attr slog.Attr) bool {
+		fields = append(fields, attr)
+		return true
+	})
+
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+func contextFields(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+
+	fields, _ := ctx.Value(fieldsKey{}).([]slog.Attr)
+	return fields
+}
+
+// Err returns the field an error is logged under.
+func Err(err error) slog.Attr {
+	return slog.Any("error", err)
+}
+
+// contextHandler adds the fields of the context to every record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if fields := contextFields(ctx); len(fields) > 0 {
+		record = record.Clone()
+		record.AddAttrs(fields...)
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+func redact(_ []string, attr slog.Attr) slog.Attr {
+	if attr.Value.Kind() == slog.KindGroup {
+		return attr
+	}
+
+	key := strings.ToLower(attr.Key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return slog.String(attr.Key, redacted)
+		}
+	}
+
+	return attr
+}
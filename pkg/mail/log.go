@@ -0,0 +1,49 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LogSender writes messages, links with tokens included, to the default logger. It is meant for local development.
+type LogSender struct {
+	from string
+}
+
+func NewLogSender(from string) *LogSender {
+	return &LogSender{from: from}
+}
+
+func (s *LogSender) Send(ctx context.Context, msg Message) error {
+	slog.InfoContext(ctx, "mail", slog.String("from", s.from), slog.String("to", msg.To), slog.String("subject", msg.Subject), slog.String("body", msg.Body))
+	return nil
+}
+
+// FileSender writes every message into its own file inside dir.
+type FileSender struct {
+	from string
+	dir  string
+}
+
+func NewFileSender(from, dir string) (*FileSender, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create mail dir: %w", err)
+	}
+
+	return &FileSender{from: from, dir: dir}, nil
+}
+
+func (s *FileSender) Send(ctx context.Context, msg Message) error {
+	name := fmt.Sprintf("%d_%s.eml", time.Now().UnixNano(), strings.ReplaceAll(msg.To, "/", "_"))
+
+	if err := os.WriteFile(filepath.Join(s.dir, name), format(s.from, msg), 0o644); err != nil {
+		return fmt.Errorf("failed to write mail to file: %w", err)
+	}
+
+	return nil
+}
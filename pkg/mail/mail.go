@@ -0,0 +1,24 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"mime"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// format builds the message as sent over SMTP. The subject is encoded per RFC 2047,
+// headers must not contain raw non-ASCII bytes.
+func format(from string, msg Message) []byte {
+	return fmt.Appendf(nil, "From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		from, msg.To, mime.QEncoding.Encode("utf-8", msg.Subject), msg.Body)
+}
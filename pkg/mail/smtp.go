@@ -0,0 +1,35 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+)
+
+type SMTPSender struct {
+	from string
+	addr string
+	auth smtp.Auth
+}
+
+func NewSMTPSender(from, host, port, username, password string) *SMTPSender {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPSender{
+		from: from,
+		addr: net.JoinHostPort(host, port),
+		auth: auth,
+	}
+}
+
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	if err := smtp.SendMail(s.addr, s.auth, s.from, []string{msg.To}, format(s.from, msg)); err != nil {
+		return fmt.Errorf("failed to send mail via smtp: %w", err)
+	}
+
+	return nil
+}
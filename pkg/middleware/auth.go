@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kcthack-auth/pkg/auth"
+	"github.com/kcthack-auth/pkg/logger"
+)
+
+const (
+	AccessTokenCookie = "access_token"
+	ClaimsKey         = "auth_claims"
+)
+
+// Auth validates the access token of the request and stores its claims in the context under ClaimsKey.
+// The user is added to the log fields of the request.
+// Requests without a valid token are aborted with 401.
+func Auth(tm auth.TokenValidator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := TokenFromRequest(c.Request)
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "access token not found",
+			})
+			return
+		}
+
+		claims, err := tm.Validate(c.Request.Context(), token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "invalid access token",
+			})
+			return
+		}
+
+		// Tokens issued to OpenID Connect clients only grant what their scope allows,
+		// not access to the account itself.
+		if claims.ClientID != "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "access token was issued to a client",
+			})
+			return
+		}
+
+		c.Set(ClaimsKey, claims)
+		c.Request = c.Request.WithContext(logger.With(c.Request.Context(), "user_id", claims.UserID))
+		c.Next()
+	}
+}
+
+// TokenFromRequest returns the access token from the Authorization header or, if there is none, from the cookie.
+func TokenFromRequest(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if scheme, token, ok := strings.Cut(header, " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+
+	cookie, err := r.Cookie(AccessTokenCookie)
+	if err != nil {
+		return ""
+	}
+
+	return cookie.Value
+}
+
+// Claims returns the claims stored by Auth.
+func Claims(c *gin.Context) (*auth.TokenClaims, bool) {
+	value, ok := c.Get(ClaimsKey)
+	if !ok {
+		return nil, false
+	}
+
+	claims, ok := value.(*auth.TokenClaims)
+	return claims, ok
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/kcthack-auth/pkg/logger"
+)
+
+const RequestIDHeader = "X-Request-Id"
+
+// maxRequestIDLength limits request ids taken from the client.
+const maxRequestIDLength = 128
+
+// Logger adds the request id and route to the log fields of the request context and logs every
+// request once it is handled. The request id is taken from X-Request-Id or generated and is sent back
+// in the same header. Only the path is logged, queries may carry tokens.
+func Logger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = uuid.NewString()
+		}
+		c.Header(RequestIDHeader, requestID)
+
+		ctx := logger.With(c.Request.Context(), "request_id", requestID, "route", c.FullPath())
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		level := slog.LevelInfo
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		// Auth replaces the request with one whose context has the user, so it is read after Next.
+		slog.LogAttrs(c.Request.Context(), level, "request",
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", c.Writer.Status()),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("ip", c.ClientIP()),
+			slog.String("user_agent", c.Request.UserAgent()),
+		)
+	}
+}
+
+// Recovery answers a panicking request with 500 and logs the panic.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, err any) {
+		slog.ErrorContext(c.Request.Context(), "panic while handling request", slog.Any("panic", err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+	})
+}
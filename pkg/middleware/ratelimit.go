@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kcthack-auth/pkg/logger"
+	"github.com/kcthack-auth/pkg/ratelimit"
+)
+
+// Keys of rate limit rules: the client IP, the email of the JSON body or the authenticated user.
+const (
+	RateLimitByIP    = "ip"
+	RateLimitByEmail = "email"
+	RateLimitByUser  = "user"
+)
+
+// maxRateLimitBody is how much of the body is read to find the email.
+const maxRateLimitBody = 1 << 16
+
+// RateLimiter limits routes by the rules configured for their names.
+// A nil RateLimiter limits nothing.
+type RateLimiter struct {
+	routes map[string][]rateLimitRule
+}
+
+type rateLimitRule struct {
+	key     string
+	limiter ratelimit.Limiter
+}
+
+func NewRateLimiter(store ratelimit.Store, routes map[string][]ratelimit.Rule) (*RateLimiter, error) {
+	r := &RateLimiter{routes: make(map[string][]rateLimitRule, len(routes))}
+	for route, rules := range routes {
+		for _, rule := range rules {
+			switch rule.Key {
+			case RateLimitByIP, RateLimitByEmail, RateLimitByUser:
+			default:
+				return nil, fmt.Errorf("route %s: unknown rate limit key %q", route, rule.Key)
+			}
+
+			limiter, err := ratelimit.New(store, rule.Limit())
+			if err != nil {
+				return nil, fmt.Errorf("route %s: %w", route, err)
+			}
+
+			r.routes[route] = append(r.routes[route], rateLimitRule{key: rule.Key, limiter: limiter})
+		}
+	}
+
+	return r, nil
+}
+
+// Check counts a request against the rules of the route. value returns the value of a rule key
+// for the request, rules whose key has no value are skipped. The result is the one of the denying
+// rule with the longest wait, or else of the rule with the fewest requests left. It is nil if no
+// rule applied. If the store fails the rule is skipped, so the request is let through.
+func (r *RateLimiter) Check(ctx context.Context, route string, value func(key string) string) *ratelimit.Result {
+	if r == nil {
+		return nil
+	}
+
+	var (
+		tightest *ratelimit.Result
+		denied   *ratelimit.Result
+	)
+	for i, rule := range r.routes[route] {
+		v := value(rule.key)
+		if v == "" {
+			continue
+		}
+
+		result, err := rule.limiter.Allow(ctx, fmt.Sprintf("%s:%d:%s:%s", route, i, rule.key, v))
+		if err != nil {
+			slog.WarnContext(ctx, "failed to check rate limit", slog.String("limit", route), logger.Err(err))
+			continue
+		}
+
+		if !result.Allowed && (denied == nil || result.RetryAfter > denied.RetryAfter) {
+			denied = &result
+		}
+		if tightest == nil || result.Remaining < tightest.Remaining {
+			tightest = &result
+		}
+	}
+
+	if denied != nil {
+		return denied
+	}
+
+	return tightest
+}
+
+// Limit returns the middleware of the route. Requests over the limit are aborted with 429 and
+// Retry-After, every limited response carries the RateLimit-* headers of its tightest rule.
+// Rules keyed by user have to run after Auth.
+func (r *RateLimiter) Limit(route string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tightest := r.Check(c.Request.Context(), route, func(key string) string {
+			return rateLimitKey(c, key)
+		})
+
+		if tightest != nil {
+			c.Header("RateLimit-Limit", strconv.Itoa(tightest.Limit))
+			c.Header("RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+			c.Header("RateLimit-Reset", ceilSeconds(tightest.Reset))
+		}
+
+		if tightest != nil && !tightest.Allowed {
+			c.Header("Retry-After", ceilSeconds(tightest.RetryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": "too many requests",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func rateLimitKey(c *gin.Context, key string) string {
+	switch key {
+	case RateLimitByIP:
+		return c.ClientIP()
+	case RateLimitByEmail:
+		return emailFromBody(c)
+	case RateLimitByUser:
+		if claims, ok := Claims(c); ok {
+			return claims.UserID
+		}
+	}
+
+	return ""
+}
+
+// emailFromBody reads the email of a JSON body and puts the body back for the handler.
+func emailFromBody(c *gin.Context) string {
+	if c.Request.Body == nil {
+		return ""
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxRateLimitBody))
+	if err != nil {
+		return ""
+	}
+	c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return ""
+	}
+
+	return strings.ToLower(strings.TrimSpace(req.Email))
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kcthack-auth/pkg/ratelimit"
+)
+
+func newLimitedRouter(t *testing.T, rules []ratelimit.Rule, handler gin.HandlerFunc) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	limiter, err := NewRateLimiter(ratelimit.NewMemoryStore(), map[string][]ratelimit.Rule{"login": rules})
+	if err != nil {
+		t.Fatalf("new rate limiter: %v", err)
+	}
+
+	r := gin.New()
+	r.POST("/login", limiter.Limit("login"), handler)
+
+	return r
+}
+
+func post(r http.Handler, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+	req.RemoteAddr = "10.0.0.1:1234"
+	r.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestLimitHeaders(t *testing.T) {
+	r := newLimitedRouter(t, []ratelimit.Rule{
+		{Key: RateLimitByIP, Algorithm: ratelimit.TokenBucket, Requests: 2, Period: time.Minute},
+	}, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name           string
+		wantStatus     int
+		wantRemaining  string
+		wantReset      string
+		wantRetryAfter string
+	}{
+		{name: "first", wantStatus: http.StatusOK, wantRemaining: "1", wantReset: "30"},
+		{name: "second", wantStatus: http.StatusOK, wantRemaining: "0", wantReset: "60"},
+		{name: "over the limit", wantStatus: http.StatusTooManyRequests, wantRemaining: "0", wantReset: "60", wantRetryAfter: "30"},
+	}
+
+	for _, tt := range tests {
+		w := post(r, "")
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.wantStatus)
+		}
+
+		headers := map[string]string{
+			"RateLimit-Limit":     "2",
+			"RateLimit-Remaining": tt.wantRemaining,
+			"RateLimit-Reset":     tt.wantReset,
+			"Retry-After":         tt.wantRetryAfter,
+		}
+		for header, want := range headers {
+			if got := w.Header().Get(header); got != want {
+				t.Errorf("%s: %s = %q, want %q", tt.name, header, got, want)
+			}
+		}
+	}
+}
+
+func TestLimitByEmail(t *testing.T) {
+	var bodies []string
+	r := newLimitedRouter(t, []ratelimit.Rule{
+		{Key: RateLimitByEmail, Algorithm: ratelimit.SlidingWindow, Requests: 1, Period: time.Hour},
+	}, func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		bodies = append(bodies, string(body))
+		c.Status(http.StatusOK)
+	})
+
+	first := `{"email": "User@Example.com", "password": "secret"}`
+	if w := post(r, first); w.Code != http.StatusOK {
+		t.Fatalf("first request: status %d", w.Code)
+	}
+	if len(bodies) != 1 || bodies[0] != first {
+		t.Errorf("handler read %q, want the whole body %q", bodies, first)
+	}
+
+	if w := post(r, `{"email": " user@example.com"}`); w.Code != http.StatusTooManyRequests {
+		t.Errorf("same email in another case: status %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if w := post(r, `{"email": "other@example.com"}`); w.Code != http.StatusOK {
+		t.Errorf("other email: status %d, want %d", w.Code, http.StatusOK)
+	}
+
+	// Bodies longer than what is read for the email reach the handler unchanged, without a limit.
+	long := `{"email": "user@example.com", "padding": "` + string(bytes.Repeat([]byte("x"), maxRateLimitBody)) + `"}`
+	if w := post(r, long); w.Code != http.StatusOK {
+		t.Errorf("long body: status %d, want %d", w.Code, http.StatusOK)
+	}
+	if got := bodies[len(bodies)-1]; got != long {
+		t.Errorf("handler read %d bytes of the long body, want %d", len(got), len(long))
+	}
+}
+
+func TestLimitNil(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var limiter *RateLimiter
+	r := gin.New()
+	r.POST("/login", limiter.Limit("login"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for range 3 {
+		if w := post(r, ""); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+			t.Errorf("status %d, RateLimit-Limit %q, want %d and no header", w.Code, w.Header().Get("RateLimit-Limit"), http.StatusOK)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kcthack-auth/pkg/rbac"
+)
+
+// RequireRole allows the request if the user holds any of the roles. It must run after Auth.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := Claims(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "unauthorized",
+			})
+			return
+		}
+
+		for _, role := range roles {
+			if slices.Contains(claims.Roles, role) {
+				c.Next()
+				return
+			}
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "insufficient role",
+		})
+	}
+}
+
+// RequirePermission allows the request if the user's roles grant all of the permissions. It must run after Auth.
+func RequirePermission(policy *rbac.Policy, permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := Claims(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "unauthorized",
+			})
+			return
+		}
+
+		for _, perm := range permissions {
+			if !policy.Can(claims.Roles, perm) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"error": "missing permission: " + perm,
+				})
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,301 @@
+// Package oauth implements the authorization code flow with PKCE against any OAuth2 provider
+// exposing a user info endpoint, OpenID Connect providers are configured via discovery.
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Config describes a provider. Endpoints left empty are taken from the discovery document
+// of Issuer. Claims default to the standard OpenID Connect claim names.
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	Issuer       string
+	AuthURL      string
+	TokenURL     string
+	UserInfoURL  string
+	Scopes       []string
+	// UserInfoMethod is GET (bearer token) or POST (access_token and client_id sent as a form).
+	UserInfoMethod string
+	// AuthScheme is the Authorization header scheme of user info requests, Bearer by default.
+	AuthScheme string
+	// CallbackParams are callback query parameters the provider expects back in the token request.
+	CallbackParams []string
+	Claims         ClaimMapping
+}
+
+// ClaimMapping maps user info fields to identity fields. Nested fields are addressed with dots, e.g. "user.user_id".
+type ClaimMapping struct {
+	Subject       string
+	Email         string
+	EmailVerified string
+	FirstName     string
+	LastName      string
+	Name          string
+}
+
+// UserInfo is the identity of the user at the provider.
+type UserInfo struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+}
+
+type Provider struct {
+	name   string
+	cfg    Config
+	oauth  *oauth2.Config
+	client *http.Client
+}
+
+// NewProvider creates a provider redirecting back to redirectURL. If cfg.Issuer is set the
+// OpenID Connect discovery document is fetched for missing endpoints.
+func NewProvider(ctx context.Context, name string, cfg Config, redirectURL string) (*Provider, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	if cfg.Issuer != "" {
+		if err := discover(ctx, client, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to discover %s endpoints: %w", name, err)
+		}
+	}
+
+	if cfg.AuthURL == "" || cfg.TokenURL == "" || cfg.UserInfoURL == "" {
+		return nil, fmt.Errorf("provider %s must have auth, token and user info urls", name)
+	}
+
+	cfg.Claims = withDefaults(cfg.Claims)
+	if cfg.UserInfoMethod == "" {
+		cfg.UserInfoMethod = http.MethodGet
+	}
+	if cfg.AuthScheme == "" {
+		cfg.AuthScheme = "Bearer"
+	}
+
+	return &Provider{
+		name: name,
+		cfg:  cfg,
+		oauth: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  cfg.AuthURL,
+				TokenURL: cfg.TokenURL,
+			},
+			RedirectURL: redirectURL,
+			Scopes:      cfg.Scopes,
+		},
+		client: client,
+	}, nil
+}
+
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// GenerateVerifier returns a new PKCE code verifier.
+func GenerateVerifier() string {
+	return oauth2.GenerateVerifier()
+}
+
+// AuthCodeURL returns the url the user is sent to. The S256 challenge of verifier is attached to it.
+func (p *Provider) AuthCodeURL(state, verifier string) string {
+	return p.oauth.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier))
+}
+
+// Exchange trades the code for an access token and fetches the user with it.
+// callback holds the query parameters of the callback request.
+func (p *Provider) Exchange(ctx context.Context, code, verifier string, callback url.Values) (*UserInfo, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.client)
+
+	opts := []oauth2.AuthCodeOption{oauth2.VerifierOption(verifier)}
+	for _, param := range p.cfg.CallbackParams {
+		if v := callback.Get(param); v != "" {
+			opts = append(opts, oauth2.SetAuthURLParam(param, v))
+		}
+	}
+
+	token, err := p.oauth.Exchange(ctx, code, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+
+	claims, err := p.userInfo(ctx, token.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	info := UserInfo{
+		Subject:       claimString(claims, p.cfg.Claims.Subject),
+		Email:         claimString(claims, p.cfg.Claims.Email),
+		EmailVerified: claimBool(claims, p.cfg.Claims.EmailVerified),
+		FirstName:     claimString(claims, p.cfg.Claims.FirstName),
+		LastName:      claimString(claims, p.cfg.Claims.LastName),
+	}
+	if info.Subject == "" {
+		return nil, errors.New("user info has no subject")
+	}
+
+	if info.FirstName == "" && info.LastName == "" {
+		name := strings.TrimSpace(claimString(claims, p.cfg.Claims.Name))
+		info.FirstName, info.LastName, _ = strings.Cut(name, " ")
+	}
+
+	return &info, nil
+}
+
+func (p *Provider) userInfo(ctx context.Context, accessToken string) (map[string]any, error) {
+	var (
+		req *http.Request
+		err error
+	)
+	if p.cfg.UserInfoMethod == http.MethodPost {
+		form := url.Values{}
+		form.Set("access_token", accessToken)
+		form.Set("client_id", p.cfg.ClientID)
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.UserInfoURL, strings.NewReader(form.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	} else {
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, p.cfg.UserInfoURL, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", p.cfg.AuthScheme+" "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user info: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch user info: unexpected status %d", resp.StatusCode)
+	}
+
+	dec := json.NewDecoder(io.LimitReader(resp.Body, 1<<20))
+	dec.UseNumber()
+
+	var claims map[string]any
+	if err := dec.Decode(&claims); err != nil {
+		return nil, fmt.Errorf("failed to decode user info: %w", err)
+	}
+
+	return claims, nil
+}
+
+func discover(ctx context.Context, client *http.Client, cfg *Config) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(cfg.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var doc struct {
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return err
+	}
+
+	if cfg.AuthURL == "" {
+		cfg.AuthURL = doc.AuthorizationEndpoint
+	}
+	if cfg.TokenURL == "" {
+		cfg.TokenURL = doc.TokenEndpoint
+	}
+	if cfg.UserInfoURL == "" {
+		cfg.UserInfoURL = doc.UserInfoEndpoint
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	return nil
+}
+
+func withDefaults(m ClaimMapping) ClaimMapping {
+	if m.Subject == "" {
+		m.Subject = "sub"
+	}
+	if m.Email == "" {
+		m.Email = "email"
+	}
+	if m.EmailVerified == "" {
+		m.EmailVerified = "email_verified"
+	}
+	if m.FirstName == "" {
+		m.FirstName = "given_name"
+	}
+	if m.LastName == "" {
+		m.LastName = "family_name"
+	}
+	if m.Name == "" {
+		m.Name = "name"
+	}
+
+	return m
+}
+
+func claim(claims map[string]any, path string) any {
+	var v any = claims
+	for _, key := range strings.Split(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+
+	return v
+}
+
+func claimString(claims map[string]any, path string) string {
+	switch v := claim(claims, path).(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	default:
+		return ""
+	}
+}
+
+func claimBool(claims map[string]any, path string) bool {
+	switch v := claim(claims, path).(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true" || v == "1"
+	case json.Number:
+		return v.String() == "1"
+	default:
+		return false
+	}
+}
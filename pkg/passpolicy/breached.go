@@ -0,0 +1,156 @@
+package passpolicy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+//go:embed common.txt
+var commonPasswords string
+
+// BreachedList tells whether a password appears in a breach corpus. Only a bloom filter of the
+// SHA-1 hashes is kept, so large corpora fit into memory and lookups never leave the process.
+// A false positive rejects a password that was never breached, a false negative is impossible.
+type BreachedList struct {
+	bits []uint64
+	m    uint64
+	k    uint64
+}
+
+// LoadBreachedList builds the list from the embedded most common passwords and the file at path,
+// if set. Every line of the file is either a password or its SHA-1 hash in hex, optionally
+// followed by ":count" as in the Pwned Passwords downloads.
+func LoadBreachedList(path string, falsePositiveRate float64) (*BreachedList, error) {
+	n := strings.Count(commonPasswords, "\n") + 1
+
+	var file *os.File
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open breached password list: %w", err)
+		}
+		defer f.Close()
+
+		lines, err := countLines(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read breached password list: %w", err)
+		}
+		n += lines
+
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to read breached password list: %w", err)
+		}
+		file = f
+	}
+
+	list := newBreachedList(n, falsePositiveRate)
+	if file != nil {
+		if err := list.read(file); err != nil {
+			return nil, fmt.Errorf("failed to read breached password list: %w", err)
+		}
+	}
+
+	if err := list.read(strings.NewReader(commonPasswords)); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// newBreachedList sizes the filter for n entries at the false positive rate.
+func newBreachedList(n int, falsePositiveRate float64) *BreachedList {
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = 0.001
+	}
+
+	m := uint64(math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	m = max(m, 64)
+	k := uint64(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+
+	return &BreachedList{
+		bits: make([]uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+	}
+}
+
+func (b *BreachedList) Contains(password string) bool {
+	return b.contains(sha1.Sum([]byte(password)))
+}
+
+func (b *BreachedList) read(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		b.add(lineDigest(line))
+	}
+
+	return scanner.Err()
+}
+
+// lineDigest returns the SHA-1 of a line, which is either the hash itself or a password.
+func lineDigest(line string) [sha1.Size]byte {
+	hash, _, _ := strings.Cut(line, ":")
+
+	var digest [sha1.Size]byte
+	if len(hash) == 2*sha1.Size {
+		if _, err := hex.Decode(digest[:], []byte(hash)); err == nil {
+			return digest
+		}
+	}
+
+	return sha1.Sum([]byte(line))
+}
+
+func (b *BreachedList) add(digest [sha1.Size]byte) {
+	h1, h2 := splitDigest(digest)
+	for i := range b.k {
+		bit := (h1 + i*h2) % b.m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (b *BreachedList) contains(digest [sha1.Size]byte) bool {
+	h1, h2 := splitDigest(digest)
+	for i := range b.k {
+		bit := (h1 + i*h2) % b.m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// splitDigest derives the two hashes of double hashing from the digest, it is uniform already.
+func splitDigest(digest [sha1.Size]byte) (uint64, uint64) {
+	return binary.BigEndian.Uint64(digest[0:8]), binary.BigEndian.Uint64(digest[8:16]) | 1
+}
+
+func countLines(r io.Reader) (int, error) {
+	buf := make([]byte, 64*1024)
+	count := 0
+	for {
+		n, err := r.Read(buf)
+		count += bytes.Count(buf[:n], []byte{'\n'})
+		if err == io.EOF {
+			return count + 1, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package passpolicy
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadBreachedList(t *testing.T) {
+	hashed := sha1.Sum([]byte("correct horse battery"))
+	lower := sha1.Sum([]byte("lowercase hash"))
+
+	lines := []string{
+		"# a comment",
+		"plain-password-1",
+		"",
+		strings.ToUpper(hex.EncodeToString(hashed[:])) + ":42",
+		hex.EncodeToString(lower[:]),
+		"  spaced-password  ",
+	}
+
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := LoadBreachedList(path, 0.0001)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	for _, password := range []string{"plain-password-1", "correct horse battery", "lowercase hash", "spaced-password", "qwerty"} {
+		if !list.Contains(password) {
+			t.Errorf("%q not in the list", password)
+		}
+	}
+
+	// False positives are possible but rare at this rate.
+	for _, password := range []string{"plain-password-2", "correct horse", "# a comment", "k8#Vq2!zLp", strings.ToUpper(hex.EncodeToString(hashed[:])) + ":42"} {
+		if list.Contains(password) {
+			t.Errorf("%q in the list", password)
+		}
+	}
+}
+
+func TestLoadBreachedListMissingFile(t *testing.T) {
+	if _, err := LoadBreachedList(filepath.Join(t.TempDir(), "missing.txt"), 0.001); err == nil {
+		t.Error("no error for a missing file")
+	}
+}
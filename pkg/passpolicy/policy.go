@@ -0,0 +1,139 @@
+package passpolicy
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultMaxLength is used when Config.MaxLength is not set.
+const DefaultMaxLength = 72
+
+type Config struct {
+	MinLength int
+	// MaxLength is in bytes, bcrypt ignores everything after 72 bytes.
+	MaxLength     int
+	RequireLower  bool
+	RequireUpper  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// MinScore is the minimal strength from 0 to 4, see Score.
+	MinScore int
+}
+
+// Policy decides whether a new password is acceptable.
+type Policy struct {
+	cfg      Config
+	breached *BreachedList
+}
+
+// New returns the policy. Without a breached list passwords are not checked against breaches.
+func New(cfg Config, breached *BreachedList) *Policy {
+	if cfg.MaxLength <= 0 {
+		cfg.MaxLength = DefaultMaxLength
+	}
+
+	return &Policy{
+		cfg:      cfg,
+		breached: breached,
+	}
+}
+
+// Violation is returned by Check with every rule the password breaks.
+type Violation struct {
+	Reasons []string
+}
+
+func (v *Violation) Error() string {
+	return strings.Join(v.Reasons, "; ")
+}
+
+// Check returns a *Violation if the password breaks the policy. personal holds the name and
+// email of the user, the password must not contain them. Passwords over the maximal length are
+// rejected before anything else, so the cost of a check is bounded.
+func (p *Policy) Check(password string, personal ...string) error {
+	if len(password) > p.cfg.MaxLength {
+		return &Violation{Reasons: []string{fmt.Sprintf("password must be at most %d bytes long", p.cfg.MaxLength)}}
+	}
+
+	var reasons []string
+
+	if utf8.RuneCountInString(password) < p.cfg.MinLength {
+		reasons = append(reasons, fmt.Sprintf("password must be at least %d characters long", p.cfg.MinLength))
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	if p.cfg.RequireLower && !lower {
+		reasons = append(reasons, "password must contain a lowercase letter")
+	}
+	if p.cfg.RequireUpper && !upper {
+		reasons = append(reasons, "password must contain an uppercase letter")
+	}
+	if p.cfg.RequireDigit && !digit {
+		reasons = append(reasons, "password must contain a digit")
+	}
+	if p.cfg.RequireSymbol && !symbol {
+		reasons = append(reasons, "password must contain a symbol")
+	}
+
+	inputs := personalTokens(personal)
+	lowered := strings.ToLower(password)
+	for _, token := range inputs {
+		if strings.Contains(lowered, token) {
+			reasons = append(reasons, "password must not contain your name or email")
+			break
+		}
+	}
+
+	if p.breached != nil && p.breached.Contains(password) {
+		reasons = append(reasons, "password appears in a list of breached passwords")
+	} else if Score(password, inputs...) < p.cfg.MinScore {
+		reasons = append(reasons, "password is too easy to guess")
+	}
+
+	if len(reasons) > 0 {
+		return &Violation{Reasons: reasons}
+	}
+
+	return nil
+}
+
+// personalTokens splits names and the local parts of emails into the parts a password must not
+// contain. Parts shorter than 3 characters are too common to reject.
+func personalTokens(personal []string) []string {
+	var tokens []string
+	for _, value := range personal {
+		value = strings.ToLower(strings.TrimSpace(value))
+
+		var parts []string
+		if local, _, ok := strings.Cut(value, "@"); ok {
+			value = local
+			parts = append(parts, local)
+		}
+		parts = append(parts, strings.FieldsFunc(value, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})...)
+
+		for _, part := range parts {
+			if utf8.RuneCountInString(part) >= 3 {
+				tokens = append(tokens, part)
+			}
+		}
+	}
+
+	return tokens
+}
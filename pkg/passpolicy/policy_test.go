@@ -0,0 +1,106 @@
+package passpolicy
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func violations(t *testing.T, err error) []string {
+	t.Helper()
+
+	if err == nil {
+		return nil
+	}
+
+	var violation *Violation
+	if !errors.As(err, &violation) {
+		t.Fatalf("got %v, want a *Violation", err)
+	}
+
+	return violation.Reasons
+}
+
+func TestCheckMaxLength(t *testing.T) {
+	p := New(Config{MinLength: 8, RequireDigit: true, MinScore: 4}, nil)
+
+	// 73 bytes break every other rule too, but only the length is reported.
+	reasons := violations(t, p.Check(strings.Repeat("a", 73)))
+	if len(reasons) != 1 || reasons[0] != "password must be at most 72 bytes long" {
+		t.Errorf("reasons %q, want only the length", reasons)
+	}
+
+	// The limit is in bytes: 40 Cyrillic letters are 80 bytes.
+	if reasons := violations(t, p.Check(strings.Repeat("ж", 40))); len(reasons) != 1 || !strings.Contains(reasons[0], "72 bytes") {
+		t.Errorf("reasons %q, want only the length", reasons)
+	}
+
+	custom := New(Config{MaxLength: 10}, nil)
+	if reasons := violations(t, custom.Check("k8#Vq2!zLp3")); len(reasons) != 1 || !strings.Contains(reasons[0], "10 bytes") {
+		t.Errorf("reasons %q with MaxLength 10, want only the length", reasons)
+	}
+}
+
+func TestCheckRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      Config
+		password string
+		want     string
+	}{
+		{name: "min length", cfg: Config{MinLength: 12}, password: "k8#Vq2!zL", want: "password must be at least 12 characters long"},
+		{name: "min length in runes", cfg: Config{MinLength: 12}, password: "жжжжжж", want: "password must be at least 12 characters long"},
+		{name: "lowercase", cfg: Config{RequireLower: true}, password: "K8#VQ2!ZL", want: "password must contain a lowercase letter"},
+		{name: "uppercase", cfg: Config{RequireUpper: true}, password: "k8#vq2!zl", want: "password must contain an uppercase letter"},
+		{name: "digit", cfg: Config{RequireDigit: true}, password: "kx#Vqy!zL", want: "password must contain a digit"},
+		{name: "symbol", cfg: Config{RequireSymbol: true}, password: "k8xVq2yzL", want: "password must contain a symbol"},
+		{name: "min score", cfg: Config{MinScore: 3}, password: "aaaaaaaaaaaa", want: "password is too easy to guess"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if reasons := violations(t, New(tt.cfg, nil).Check(tt.password)); !slices.Equal(reasons, []string{tt.want}) {
+				t.Errorf("reasons %q, want %q", reasons, tt.want)
+			}
+		})
+	}
+
+	all := Config{MinLength: 8, RequireLower: true, RequireUpper: true, RequireDigit: true, RequireSymbol: true, MinScore: 3}
+	if err := New(all, nil).Check("k8#Vq2!zLp"); err != nil {
+		t.Errorf("password that follows every rule: %v", err)
+	}
+	if err := New(all, nil).Check("Пароль#Vq2!zLp"); err != nil {
+		t.Errorf("Cyrillic letters: %v", err)
+	}
+}
+
+func TestCheckPersonal(t *testing.T) {
+	p := New(Config{}, nil)
+	personal := []string{"Ivan", "Petrov-Vodkin", "ivan.petrov@example.com", "Li"}
+
+	for _, password := range []string{"xIvAnx!92kq", "vodkin#7Qz!w", "ivan.petrov2024", "k2#PETROVq"} {
+		if reasons := violations(t, p.Check(password, personal...)); !slices.Contains(reasons, "password must not contain your name or email") {
+			t.Errorf("%q: reasons %q, want the personal rule", password, reasons)
+		}
+	}
+
+	// Parts under 3 characters and the domain of the email are not personal.
+	for _, password := range []string{"li#7Qz!wk2Xp", "example#7Qz!w"} {
+		if err := p.Check(password, personal...); err != nil {
+			t.Errorf("%q: %v", password, err)
+		}
+	}
+}
+
+func TestCheckBreached(t *testing.T) {
+	list, err := LoadBreachedList("", 0.001)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	p := New(Config{MinScore: 4}, list)
+	if reasons := violations(t, p.Check("qwerty123")); !slices.Equal(reasons, []string{"password appears in a list of breached passwords"}) {
+		t.Errorf("reasons %q, want only the breach", reasons)
+	}
+}
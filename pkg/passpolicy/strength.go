@@ -0,0 +1,238 @@
+package passpolicy
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Thresholds of log10(guesses) between the scores, the same as zxcvbn uses.
+var scoreThresholds = []float64{3, 6, 8, 10}
+
+// Keyboard rows for keyboard walks, including the Russian layout.
+var keyboardRows = []string{
+	"`1234567890-=",
+	"qwertyuiop[]",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+	"йцукенгшщзхъ",
+	"фывапролджэ",
+	"ячсмитьбю",
+}
+
+// maxPatternLength bounds the parts the password is split into, so scoring stays linear in its length.
+const maxPatternLength = 32
+
+var leetSubstitutions = strings.NewReplacer("4", "a", "@", "a", "3", "e", "1", "i", "!", "i", "0", "o", "$", "s", "5", "s", "7", "t")
+
+var dictionary = rankedWords(commonPasswords)
+
+// Score estimates how hard the password is to guess, from 0 (too guessable) to 4 (very unguessable),
+// in the spirit of zxcvbn: the password is split into the cheapest sequence of patterns (common
+// passwords and inputs like the user's name, repeats, sequences, keyboard walks, years and single
+// characters) and the guesses of the patterns are multiplied.
+func Score(password string, inputs ...string) int {
+	guesses := logGuesses([]rune(password), inputs)
+
+	score := 0
+	for _, threshold := range scoreThresholds {
+		if guesses >= threshold {
+			score++
+		}
+	}
+
+	return score
+}
+
+// logGuesses returns log10 of the guesses needed for the password.
+func logGuesses(password []rune, inputs []string) float64 {
+	words := dictionary
+	if len(inputs) > 0 {
+		words = make(map[string]int, len(dictionary)+len(inputs))
+		for word, rank := range dictionary {
+			words[word] = rank
+		}
+		for _, input := range inputs {
+			words[strings.ToLower(input)] = 1
+		}
+	}
+
+	// best[i] is the cheapest way to guess the first i runes.
+	best := make([]float64, len(password)+1)
+	for i := 1; i <= len(password); i++ {
+		best[i] = math.Inf(1)
+		for j := max(0, i-maxPatternLength); j < i; j++ {
+			if cost := best[j] + patternGuesses(password[j:i], words); cost < best[i] {
+				best[i] = cost
+			}
+		}
+	}
+
+	return best[len(password)]
+}
+
+// patternGuesses returns log10 of the guesses for a part of the password, the cheapest of the
+// patterns it matches.
+func patternGuesses(part []rune, words map[string]int) float64 {
+	if len(part) == 1 {
+		return math.Log10(charsetSize(part[0]))
+	}
+
+	cost := math.Inf(1)
+	n := float64(len(part))
+
+	if rank, variations, ok := dictionaryMatch(part, words); ok {
+		cost = math.Log10(float64(rank) * variations)
+	}
+
+	if len(part) >= 3 {
+		if repeated(part) {
+			cost = math.Min(cost, math.Log10(charsetSize(part[0])*n))
+		}
+
+		if step := sequenceStep(part); step != 0 {
+			base := charsetSize(part[0])
+			if part[0] == 'a' || part[0] == '1' || part[0] == '0' || part[0] == 'z' || part[0] == '9' {
+				base = 4
+			}
+			cost = math.Min(cost, math.Log10(base*n*2))
+		}
+
+		if keyboardWalk(part) {
+			cost = math.Min(cost, math.Log10(40*n*n))
+		}
+	}
+
+	if len(part) == 4 && year(part) {
+		cost = math.Min(cost, math.Log10(120))
+	}
+
+	return cost
+}
+
+// dictionaryMatch looks the part up in the words as is, in lowercase, reversed and with
+// leet substitutions undone. variations are the extra guesses for the capitalization and substitutions.
+func dictionaryMatch(part []rune, words map[string]int) (int, float64, bool) {
+	lower := strings.ToLower(string(part))
+
+	variations := 1.0
+	if lower != string(part) {
+		variations *= 2
+		if strings.ToUpper(string(part)) != string(part) && !unicode.IsUpper(part[0]) {
+			variations *= 4
+		}
+	}
+
+	if rank, ok := words[lower]; ok {
+		return rank, variations, true
+	}
+
+	if rank, ok := words[reverse(lower)]; ok {
+		return rank, variations * 2, true
+	}
+
+	if unleet := leetSubstitutions.Replace(lower); unleet != lower {
+		if rank, ok := words[unleet]; ok {
+			return rank, variations * 4, true
+		}
+	}
+
+	return 0, 0, false
+}
+
+func repeated(part []rune) bool {
+	for _, r := range part[1:] {
+		if r != part[0] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sequenceStep returns 1 or -1 for runs like "abc" or "321", 0 otherwise.
+func sequenceStep(part []rune) int {
+	step := int(part[1] - part[0])
+	if step != 1 && step != -1 {
+		return 0
+	}
+
+	for i := 2; i < len(part); i++ {
+		if int(part[i]-part[i-1]) != step {
+			return 0
+		}
+	}
+
+	return step
+}
+
+// keyboardWalk reports whether every rune is next to the previous one on the same keyboard row.
+func keyboardWalk(part []rune) bool {
+	for i := 1; i < len(part); i++ {
+		if !adjacentKeys(unicode.ToLower(part[i-1]), unicode.ToLower(part[i])) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func adjacentKeys(a, b rune) bool {
+	for _, row := range keyboardRows {
+		keys := []rune(row)
+		for i, key := range keys {
+			if key != a {
+				continue
+			}
+			if (i > 0 && keys[i-1] == b) || (i+1 < len(keys) && keys[i+1] == b) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func year(part []rune) bool {
+	s := string(part)
+	return s >= "1900" && s <= "2039"
+}
+
+func charsetSize(r rune) float64 {
+	switch {
+	case unicode.IsDigit(r):
+		return 10
+	case unicode.IsLower(r), unicode.IsUpper(r):
+		if r < unicode.MaxASCII {
+			return 26
+		}
+		return 33
+	default:
+		return 33
+	}
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+
+	return string(runes)
+}
+
+// rankedWords maps every line of the list to its position, the most common password has rank 1.
+func rankedWords(list string) map[string]int {
+	words := make(map[string]int)
+	for i, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if _, ok := words[line]; !ok {
+			words[line] = i + 1
+		}
+	}
+
+	return words
+}
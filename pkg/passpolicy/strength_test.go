@@ -0,0 +1,47 @@
+package passpolicy
+
+import "testing"
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		password string
+		inputs   []string
+		min, max int
+	}{
+		{password: "", min: 0, max: 0},
+		{password: "password", min: 0, max: 0},
+		{password: "Password", min: 0, max: 0},
+		{password: "drowssap", min: 0, max: 0},
+		{password: "p@ssw0rd", min: 0, max: 0},
+		{password: "P4$$w0rd", min: 0, max: 1},
+		{password: "qwertyuiop", min: 0, max: 1},
+		{password: "asdfghjkl;", min: 0, max: 1},
+		{password: "йцукенгшщз", min: 0, max: 1},
+		{password: "aaaaaaaaaaaa", min: 0, max: 1},
+		{password: "abcdefghij", min: 0, max: 1},
+		{password: "9876543210", min: 0, max: 1},
+		{password: "dragon1990", min: 0, max: 2},
+		{password: "ivanovivanov", inputs: []string{"ivanov"}, min: 0, max: 1},
+		{password: "correcthorsebatterystaple", min: 4, max: 4},
+		{password: "k8#Vq2!zLp", min: 4, max: 4},
+		{password: "Zx9!mQ2$wR7&", min: 4, max: 4},
+	}
+
+	for _, tt := range tests {
+		if got := Score(tt.password, tt.inputs...); got < tt.min || got > tt.max {
+			t.Errorf("Score(%q) = %d, want %d to %d", tt.password, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestScoreLongInput(t *testing.T) {
+	// Parts are bounded, so a long password is scored quickly and stays strong.
+	password := make([]rune, 0, 4096)
+	for i := range cap(password) {
+		password = append(password, rune('a'+(i*7)%26))
+	}
+
+	if got := Score(string(password)); got != 4 {
+		t.Errorf("score of a long password %d, want 4", got)
+	}
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// TokenBucketLimiter keeps the tokens left in State.Value and the time of the last refill in State.Start.
+type TokenBucketLimiter struct {
+	store Store
+	limit Limit
+	now   func() time.Time
+}
+
+func NewTokenBucket(store Store, limit Limit) *TokenBucketLimiter {
+	return &TokenBucketLimiter{store: store, limit: limit, now: time.Now}
+}
+
+func (t *TokenBucketLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	capacity := float64(t.limit.Requests)
+	rate := capacity / t.limit.Period.Seconds()
+	now := t.now()
+
+	result := Result{Limit: t.limit.Requests}
+	err := t.store.Update(ctx, key, t.limit.Period, func(state *State) {
+		tokens := capacity
+		if !state.Start.IsZero() {
+			tokens = min(capacity, state.Value+now.Sub(state.Start).Seconds()*rate)
+		}
+
+		if tokens >= 1 {
+			tokens--
+			result.Allowed = true
+		} else {
+			result.RetryAfter = seconds((1 - tokens) / rate)
+		}
+
+		state.Value = tokens
+		state.Start = now
+
+		result.Remaining = int(math.Floor(tokens))
+		result.Reset = seconds((capacity - tokens) / rate)
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	return result, nil
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
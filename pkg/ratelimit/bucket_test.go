@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	clock := newTestClock()
+	limiter := NewTokenBucket(newTestStore(clock), Limit{Requests: 5, Period: 10 * time.Second})
+	limiter.now = clock.Now
+
+	// The bucket holds 5 tokens and refills one every 2 seconds.
+	steps := []struct {
+		name          string
+		advance       time.Duration
+		wantAllowed   bool
+		wantRemaining int
+		wantRetry     time.Duration
+		wantReset     time.Duration
+	}{
+		{name: "burst 1", wantAllowed: true, wantRemaining: 4, wantReset: 2 * time.Second},
+		{name: "burst 2", wantAllowed: true, wantRemaining: 3, wantReset: 4 * time.Second},
+		{name: "burst 3", wantAllowed: true, wantRemaining: 2, wantReset: 6 * time.Second},
+		{name: "burst 4", wantAllowed: true, wantRemaining: 1, wantReset: 8 * time.Second},
+		{name: "burst 5", wantAllowed: true, wantRemaining: 0, wantReset: 10 * time.Second},
+		{name: "empty", wantRetry: 2 * time.Second, wantReset: 10 * time.Second},
+		{name: "half a token", advance: time.Second, wantRetry: time.Second, wantReset: 9 * time.Second},
+		{name: "refilled token", advance: time.Second, wantAllowed: true, wantRemaining: 0, wantReset: 10 * time.Second},
+		{name: "full again", advance: 10 * time.Second, wantAllowed: true, wantRemaining: 4, wantReset: 2 * time.Second},
+		{name: "capped at the burst", advance: time.Hour, wantAllowed: true, wantRemaining: 4, wantReset: 2 * time.Second},
+	}
+
+	for _, step := range steps {
+		clock.Advance(step.advance)
+
+		result, err := limiter.Allow(context.Background(), "key")
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+
+		if result.Allowed != step.wantAllowed || result.Remaining != step.wantRemaining || result.RetryAfter != step.wantRetry || result.Reset != step.wantReset {
+			t.Errorf("%s: got allowed %t, remaining %d, retry after %s, reset %s; want %t, %d, %s, %s", step.name,
+				result.Allowed, result.Remaining, result.RetryAfter, result.Reset,
+				step.wantAllowed, step.wantRemaining, step.wantRetry, step.wantReset)
+		}
+		if result.Limit != 5 {
+			t.Errorf("%s: limit %d, want 5", step.name, result.Limit)
+		}
+	}
+}
+
+func TestTokenBucketKeys(t *testing.T) {
+	clock := newTestClock()
+	limiter := NewTokenBucket(newTestStore(clock), Limit{Requests: 1, Period: time.Minute})
+	limiter.now = clock.Now
+
+	for _, key := range []string{"a", "b"} {
+		if result, err := limiter.Allow(context.Background(), key); err != nil || !result.Allowed {
+			t.Errorf("first request of %s: allowed %t, error %v", key, result.Allowed, err)
+		}
+	}
+
+	if result, _ := limiter.Allow(context.Background(), "a"); result.Allowed {
+		t.Error("second request of a allowed")
+	}
+}
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the states in process memory, replicas do not share them.
+type MemoryStore struct {
+	mu     sync.Mutex
+	states map[string]*memoryState
+	sweep  time.Time
+	now    func() time.Time
+}
+
+type memoryState struct {
+	state     State
+	expiresAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: make(map[string]*memoryState), now: time.Now}
+}
+
+func (m *MemoryStore) Update(ctx context.Context, key string, ttl time.Duration, fn func(state *State)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+
+	// Expired keys are dropped once a minute, so the map does not grow with every client ever seen.
+	if now.After(m.sweep) {
+		for k, s := range m.states {
+			if now.After(s.expiresAt) {
+				delete(m.states, k)
+			}
+		}
+		m.sweep = now.Add(time.Minute)
+	}
+
+	s, ok := m.states[key]
+	if !ok || now.After(s.expiresAt) {
+		s = &memoryState{}
+		m.states[key] = s
+	}
+
+	fn(&s.state)
+	s.expiresAt = now.Add(ttl)
+
+	return nil
+}
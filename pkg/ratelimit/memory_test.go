@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	clock := newTestClock()
+	store := newTestStore(clock)
+
+	update := func(key string, ttl time.Duration) State {
+		t.Helper()
+
+		var got State
+		err := store.Update(context.Background(), key, ttl, func(state *State) {
+			got = *state
+			state.Value++
+		})
+		if err != nil {
+			t.Fatalf("update %s: %v", key, err)
+		}
+
+		return got
+	}
+
+	update("key", time.Second)
+	if got := update("key", time.Second); got.Value != 1 {
+		t.Errorf("state of a live key: value %v, want 1", got.Value)
+	}
+
+	clock.Advance(2 * time.Second)
+	if got := update("key", time.Second); got.Value != 0 {
+		t.Errorf("state of an expired key: value %v, want 0", got.Value)
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	clock := newTestClock()
+	store := newTestStore(clock)
+
+	noop := func(*State) {}
+	_ = store.Update(context.Background(), "short", time.Second, noop)
+	_ = store.Update(context.Background(), "long", time.Hour, noop)
+
+	// Expired keys stay until the next sweep, a minute after the last one.
+	clock.Advance(30 * time.Second)
+	_ = store.Update(context.Background(), "other", time.Hour, noop)
+	if _, ok := store.states["short"]; !ok {
+		t.Fatal("expired key swept before the sweep interval")
+	}
+
+	clock.Advance(31 * time.Second)
+	_ = store.Update(context.Background(), "other", time.Hour, noop)
+	if _, ok := store.states["short"]; ok {
+		t.Error("expired key not swept")
+	}
+	if len(store.states) != 2 {
+		t.Errorf("%d keys after the sweep, want 2", len(store.states))
+	}
+}
@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	TokenBucket   = "token_bucket"
+	SlidingWindow = "sliding_window"
+)
+
+// Limit allows Requests per Period. Token buckets allow bursts of up to Requests and refill
+// evenly over the period, sliding windows never allow more than Requests within any period.
+type Limit struct {
+	Algorithm string
+	Requests  int
+	Period    time.Duration
+}
+
+// Result describes the limit of a key after a request.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the limit is fully restored.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, zero if this one was.
+	RetryAfter time.Duration
+}
+
+// State is the state of one key. Its meaning depends on the algorithm.
+type State struct {
+	Value float64
+	Prev  float64
+	Start time.Time
+}
+
+// Store keeps the states of the keys. Update runs fn atomically for the key, concurrent updates
+// of the same key, also from other replicas, must not interleave. A new key is passed as a zero
+// State. The state is kept for at least ttl after the update.
+type Store interface {
+	Update(ctx context.Context, key string, ttl time.Duration, fn func(state *State)) error
+}
+
+type Limiter interface {
+	Allow(ctx context.Context, key string) (Result, error)
+}
+
+// New returns the limiter of the algorithm of the limit.
+func New(store Store, limit Limit) (Limiter, error) {
+	if limit.Requests <= 0 || limit.Period <= 0 {
+		return nil, fmt.Errorf("invalid limit of %d requests per %s", limit.Requests, limit.Period)
+	}
+
+	switch limit.Algorithm {
+	case TokenBucket, "":
+		return NewTokenBucket(store, limit), nil
+	case SlidingWindow:
+		return NewSlidingWindow(store, limit), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit algorithm %q", limit.Algorithm)
+	}
+}
+
+// Rule applies a limit per value of Key, e.g. per IP address.
+type Rule struct {
+	Key       string
+	Algorithm string
+	Requests  int
+	Period    time.Duration
+}
+
+func (r Rule) Limit() Limit {
+	return Limit{
+		Algorithm: r.Algorithm,
+		Requests:  r.Requests,
+		Period:    r.Period,
+	}
+}
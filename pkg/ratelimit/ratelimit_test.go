@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// testClock is a clock the tests move by hand.
+type testClock struct {
+	now time.Time
+}
+
+func newTestClock() *testClock {
+	// A minute boundary, so windows of up to a minute start at the clock.
+	return &testClock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestStore(clock *testClock) *MemoryStore {
+	store := NewMemoryStore()
+	store.now = clock.Now
+	return store
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   Limit
+		wantErr bool
+	}{
+		{name: "token bucket", limit: Limit{Algorithm: TokenBucket, Requests: 1, Period: time.Second}},
+		{name: "default algorithm", limit: Limit{Requests: 1, Period: time.Second}},
+		{name: "sliding window", limit: Limit{Algorithm: SlidingWindow, Requests: 1, Period: time.Second}},
+		{name: "unknown algorithm", limit: Limit{Algorithm: "leaky_bucket", Requests: 1, Period: time.Second}, wantErr: true},
+		{name: "no requests", limit: Limit{Requests: 0, Period: time.Second}, wantErr: true},
+		{name: "no period", limit: Limit{Requests: 1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(NewMemoryStore(), tt.limit)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
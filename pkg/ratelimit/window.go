@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// SlidingWindowLimiter approximates a sliding window with the counts of the current and the
+// previous fixed window: State.Value, State.Prev and the start of the current window in State.Start.
+// The previous count is weighted by the part of it the sliding window still covers.
+type SlidingWindowLimiter struct {
+	store Store
+	limit Limit
+	now   func() time.Time
+}
+
+func NewSlidingWindow(store Store, limit Limit) *SlidingWindowLimiter {
+	return &SlidingWindowLimiter{store: store, limit: limit, now: time.Now}
+}
+
+func (s *SlidingWindowLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	period := s.limit.Period
+	now := s.now()
+	start := now.Truncate(period)
+
+	result := Result{Limit: s.limit.Requests}
+	err := s.store.Update(ctx, key, 2*period, func(state *State) {
+		if !state.Start.Equal(start) {
+			prev := 0.0
+			if state.Start.Equal(start.Add(-period)) {
+				prev = state.Value
+			}
+			state.Value, state.Prev, state.Start = 0, prev, start
+		}
+
+		weight := 1 - float64(now.Sub(start))/float64(period)
+		count := state.Prev*weight + state.Value
+
+		if count+1 <= float64(s.limit.Requests) {
+			state.Value++
+			count++
+			result.Allowed = true
+		} else {
+			result.RetryAfter = s.retryAfter(state, now, start, count)
+		}
+
+		result.Remaining = max(0, s.limit.Requests-int(math.Ceil(count)))
+		result.Reset = start.Add(period).Sub(now)
+		if state.Value > 0 {
+			// The current window still counts as the previous one during the next window.
+			result.Reset += period
+		}
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	return result, nil
+}
+
+// retryAfter is the time until the weighted count drops enough for one more request.
+// The previous window decays until the current one ends, the current one during the next window.
+func (s *SlidingWindowLimiter) retryAfter(state *State, now, start time.Time, count float64) time.Duration {
+	period := float64(s.limit.Period)
+	end := start.Add(s.limit.Period).Sub(now)
+	excess := count + 1 - float64(s.limit.Requests)
+
+	if weight := float64(end) / period; state.Prev*weight >= excess {
+		return time.Duration(excess / state.Prev * period)
+	}
+
+	excess = state.Value + 1 - float64(s.limit.Requests)
+	if excess <= 0 || state.Value == 0 {
+		return end
+	}
+
+	return end + time.Duration(excess/state.Value*period)
+}
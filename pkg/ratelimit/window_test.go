@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSlidingWindow(t *testing.T) {
+	clock := newTestClock()
+	limiter := NewSlidingWindow(newTestStore(clock), Limit{Algorithm: SlidingWindow, Requests: 10, Period: time.Minute})
+	limiter.now = clock.Now
+
+	allow := func(name string, want bool) Result {
+		t.Helper()
+
+		result, err := limiter.Allow(context.Background(), "key")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if result.Allowed != want {
+			t.Fatalf("%s: allowed %t, want %t", name, result.Allowed, want)
+		}
+
+		return result
+	}
+
+	// A full current window.
+	if result := allow("first request", true); result.Remaining != 9 || result.Reset != 2*time.Minute {
+		t.Errorf("first request: remaining %d, reset %s, want 9 and %s", result.Remaining, result.Reset, 2*time.Minute)
+	}
+	for range 9 {
+		allow("request within the limit", true)
+	}
+
+	// The count drops to 9 once the full window weighs 0.9 in the next one, 6 seconds into it.
+	if result := allow("request over the limit", false); result.RetryAfter != 66*time.Second || result.Remaining != 0 {
+		t.Errorf("request over the limit: retry after %s, remaining %d, want %s and 0", result.RetryAfter, result.Remaining, 66*time.Second)
+	}
+
+	// Halfway into the next window the previous 10 requests count as 5.
+	clock.Advance(90 * time.Second)
+	for range 5 {
+		allow("request in the next window", true)
+	}
+	result := allow("request over the weighted limit", false)
+	if result.RetryAfter < 5*time.Second || result.RetryAfter > 6*time.Second {
+		t.Errorf("request over the weighted limit: retry after %s, want 6s", result.RetryAfter)
+	}
+
+	clock.Advance(5 * time.Second)
+	allow("request before the previous window decayed", false)
+
+	clock.Advance(2 * time.Second)
+	allow("request after the previous window decayed", true)
+
+	// Two periods later neither window counts.
+	clock.Advance(2 * time.Minute)
+	for range 10 {
+		allow("request after two periods", true)
+	}
+}
@@ -0,0 +1,72 @@
+package rbac
+
+import "slices"
+
+// Wildcard grants every permission to a role.
+const Wildcard = "*"
+
+// Policy maps roles to the permissions they grant.
+type Policy struct {
+	roles map[string]map[string]struct{}
+}
+
+func NewPolicy(roles map[string][]string) *Policy {
+	p := Policy{roles: make(map[string]map[string]struct{}, len(roles))}
+
+	for role, permissions := range roles {
+		set := make(map[string]struct{}, len(permissions))
+		for _, perm := range permissions {
+			set[perm] = struct{}{}
+		}
+		p.roles[role] = set
+	}
+
+	return &p
+}
+
+// HasRole reports whether the role is defined in the policy.
+func (p *Policy) HasRole(role string) bool {
+	_, ok := p.roles[role]
+	return ok
+}
+
+// Can reports whether any of the roles grants the permission.
+func (p *Policy) Can(roles []string, permission string) bool {
+	for _, role := range roles {
+		perms, ok := p.roles[role]
+		if !ok {
+			continue
+		}
+
+		if _, ok := perms[Wildcard]; ok {
+			return true
+		}
+
+		if _, ok := perms[permission]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Permissions returns every permission granted by the roles, sorted.
+func (p *Policy) Permissions(roles []string) []string {
+	seen := make(map[string]struct{})
+	var result []string
+
+	for _, role := range roles {
+		for perm := range p.roles[role] {
+			if _, ok := seen[perm]; ok {
+				continue
+			}
+			seen[perm] = struct{}{}
+			result = append(result, perm)
+		}
+	}
+
+	// The permissions of a role are kept in a map, sorting keeps responses and tokens stable.
+	slices.Sort(result)
+
+	return result
+}
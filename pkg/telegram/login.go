@@ -0,0 +1,83 @@
+// Package telegram verifies data sent by the Telegram Login Widget,
+// see https://core.telegram.org/widgets/login#checking-authorization.
+package telegram
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidHash = errors.New("telegram login data hash mismatch")
+	ErrExpired     = errors.New("telegram login data is too old")
+)
+
+// LoginData is the user object the widget passes to the callback or the redirect url.
+type LoginData struct {
+	ID        int64
+	FirstName string
+	LastName  string
+	Username  string
+	PhotoURL  string
+	AuthDate  int64
+	Hash      string
+}
+
+// Verify checks that data was signed by Telegram for the bot and is not older than maxAge.
+func Verify(botToken string, data LoginData, maxAge time.Duration, now time.Time) error {
+	secret := sha256.Sum256([]byte(botToken))
+
+	mac := hmac.New(sha256.New, secret[:])
+	mac.Write([]byte(data.checkString()))
+
+	hash, err := hex.DecodeString(data.Hash)
+	if err != nil || !hmac.Equal(hash, mac.Sum(nil)) {
+		return ErrInvalidHash
+	}
+
+	if now.Sub(time.Unix(data.AuthDate, 0)) > maxAge {
+		return ErrExpired
+	}
+
+	return nil
+}
+
+// checkString builds the data-check-string: all received fields except hash,
+// sorted by name and joined with line feeds. Fields Telegram did not send are omitted.
+func (d LoginData) checkString() string {
+	fields := map[string]string{
+		"id":        strconv.FormatInt(d.ID, 10),
+		"auth_date": strconv.FormatInt(d.AuthDate, 10),
+	}
+	if d.FirstName != "" {
+		fields["first_name"] = d.FirstName
+	}
+	if d.LastName != "" {
+		fields["last_name"] = d.LastName
+	}
+	if d.Username != "" {
+		fields["username"] = d.Username
+	}
+	if d.PhotoURL != "" {
+		fields["photo_url"] = d.PhotoURL
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	lines := make([]string, 0, len(keys))
+	for _, k := range keys {
+		lines = append(lines, k+"="+fields[k])
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,99 @@
+package telegram
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testBotToken = "123456:TEST-token"
+
+// The hashes are HMAC-SHA256 of the data-check-strings keyed with SHA-256 of testBotToken,
+// computed independently of this package.
+var (
+	signedFull = LoginData{
+		ID:        42,
+		FirstName: "Ivan",
+		LastName:  "Petrov",
+		Username:  "ivan_tg",
+		PhotoURL:  "https://t.me/i/userpic/320/ivan.jpg",
+		AuthDate:  1700000000,
+		Hash:      "fbf1116d199d33625d345ed0beb866d78fd561bcd6f392c0fadb39b88b641d3d",
+	}
+	signedMinimal = LoginData{
+		ID:        42,
+		FirstName: "Ivan",
+		AuthDate:  1700000000,
+		Hash:      "fc82b6366da2e6a7e2639f36dff64dafbc49c6f80c7a028509a359bdb81be131",
+	}
+)
+
+var signedAt = time.Unix(1700000000, 0)
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    func() LoginData
+		now     time.Time
+		wantErr error
+	}{
+		{name: "all fields", data: func() LoginData { return signedFull }, now: signedAt.Add(time.Minute)},
+		{name: "optional fields omitted", data: func() LoginData { return signedMinimal }, now: signedAt},
+		{name: "upper case hash", data: func() LoginData {
+			d := signedFull
+			d.Hash = strings.ToUpper(d.Hash)
+			return d
+		}, now: signedAt},
+		{name: "tampered id", data: func() LoginData {
+			d := signedFull
+			d.ID = 43
+			return d
+		}, now: signedAt, wantErr: ErrInvalidHash},
+		{name: "tampered username", data: func() LoginData {
+			d := signedFull
+			d.Username = "admin"
+			return d
+		}, now: signedAt, wantErr: ErrInvalidHash},
+		{name: "tampered auth date", data: func() LoginData {
+			d := signedFull
+			d.AuthDate++
+			return d
+		}, now: signedAt, wantErr: ErrInvalidHash},
+		{name: "field dropped", data: func() LoginData {
+			d := signedFull
+			d.PhotoURL = ""
+			return d
+		}, now: signedAt, wantErr: ErrInvalidHash},
+		{name: "hash not hex", data: func() LoginData {
+			d := signedFull
+			d.Hash = "not hex"
+			return d
+		}, now: signedAt, wantErr: ErrInvalidHash},
+		{name: "expired", data: func() LoginData { return signedFull }, now: signedAt.Add(5*time.Minute + time.Second), wantErr: ErrExpired},
+		{name: "at the max age", data: func() LoginData { return signedFull }, now: signedAt.Add(5 * time.Minute)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify(testBotToken, tt.data(), 5*time.Minute, tt.now); !errors.Is(err, tt.wantErr) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := Verify("654321:OTHER-token", signedFull, 5*time.Minute, signedAt); !errors.Is(err, ErrInvalidHash) {
+		t.Errorf("other bot: got %v, want %v", err, ErrInvalidHash)
+	}
+}
+
+func TestCheckString(t *testing.T) {
+	if got, want := signedMinimal.checkString(), "auth_date=1700000000\nfirst_name=Ivan\nid=42"; got != want {
+		t.Errorf("check string %q, want %q", got, want)
+	}
+
+	want := "auth_date=1700000000\nfirst_name=Ivan\nid=42\nlast_name=Petrov\nphoto_url=https://t.me/i/userpic/320/ivan.jpg\nusername=ivan_tg"
+	if got := signedFull.checkString(); got != want {
+		t.Errorf("check string %q, want %q", got, want)
+	}
+}
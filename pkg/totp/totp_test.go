@@ -0,0 +1,126 @@
+package totp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 seed of RFC 6238 appendix B, "12345678901234567890", in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeRFC6238(t *testing.T) {
+	// The 8 digit codes of appendix B cut to the last 6 digits.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("code at %d: %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+
+	if got, _ := Code(strings.ToLower(rfcSecret), Step(time.Unix(59, 0))); got != "287082" {
+		t.Errorf("code of the lower case secret = %s, want 287082", got)
+	}
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("no error for an invalid secret")
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+
+	codes := make(map[int64]string)
+	for i := int64(-2); i <= 2; i++ {
+		codes[step+i], _ = Code(rfcSecret, step+i)
+	}
+
+	tests := []struct {
+		name   string
+		step   int64
+		wantOK bool
+	}{
+		{name: "current step", step: step, wantOK: true},
+		{name: "previous step", step: step - 1, wantOK: true},
+		{name: "next step", step: step + 1, wantOK: true},
+		{name: "two steps back", step: step - 2},
+		{name: "two steps ahead", step: step + 2},
+	}
+
+	for _, tt := range tests {
+		got, ok := Validate(rfcSecret, codes[tt.step], now, 1)
+		if ok != tt.wantOK || (ok && got != tt.step) {
+			t.Errorf("%s: got step %d, %t, want %d, %t", tt.name, got, ok, tt.step, tt.wantOK)
+		}
+	}
+
+	if _, ok := Validate(rfcSecret, codes[step][:3]+" "+codes[step][3:], now, 1); !ok {
+		t.Error("code with a space rejected")
+	}
+	for _, code := range []string{"", "12345", "1234567"} {
+		if _, ok := Validate(rfcSecret, code, now, 1); ok {
+			t.Errorf("code %q accepted", code)
+		}
+	}
+	if _, ok := Validate(rfcSecret, codes[step-1], now, 0); ok {
+		t.Error("code of the previous step accepted without skew")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("KCT Hack", "user@example.com", rfcSecret)
+
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("parse %q: %v", uri, err)
+	}
+
+	if parsed.Scheme != "otpauth" || parsed.Host != "totp" {
+		t.Errorf("uri %q is not an otpauth://totp uri", uri)
+	}
+	if parsed.Path != "/KCT Hack:user@example.com" {
+		t.Errorf("label %q, want issuer:account", parsed.Path)
+	}
+	if !strings.HasPrefix(uri, "otpauth://totp/KCT%20Hack:user@example.com?") {
+		t.Errorf("uri %q does not escape the label", uri)
+	}
+
+	want := url.Values{
+		"secret":    {rfcSecret},
+		"issuer":    {"KCT Hack"},
+		"algorithm": {"SHA1"},
+		"digits":    {"6"},
+		"period":    {"30"},
+	}
+	if got := parsed.Query(); got.Encode() != want.Encode() {
+		t.Errorf("parameters %v, want %v", got, want)
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	key, err := encoding.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Errorf("secret %q decodes to %d bytes, %v, want 20", secret, len(key), err)
+	}
+}